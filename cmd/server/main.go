@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"path/filepath"
 	"time"
 
 	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/api"
@@ -10,7 +12,9 @@ import (
 	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/db"
 	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/handlers"
 	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/repository"
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/search"
 	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/services"
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/storage"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 
@@ -46,41 +50,147 @@ func main() {
 		}
 	}()
 
+	indexCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	if err := repository.EnsureMongoIndexes(indexCtx, mongoClient); err != nil {
+		cancel()
+		log.Fatalf("MongoDB indexes: %v", err)
+	}
+	cancel()
+
 	productCol := mongoClient.Collection("products")
 	productRepo := repository.NewProductRepositoryMongo(productCol)
+	migrateCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	if err := productRepo.BackfillTotalStock(migrateCtx); err != nil {
+		cancel()
+		log.Fatalf("products backfill: %v", err)
+	}
+	if err := productRepo.BackfillCurrentPrice(migrateCtx); err != nil {
+		cancel()
+		log.Fatalf("products price backfill: %v", err)
+	}
+	uow := repository.NewMongoUnitOfWork(mongoClient)
 	productService := services.NewProductService(productRepo)
-	productHandler := handlers.NewProductHandler(productService)
+	productService.EnableTransactions(uow)
+	movementRepo := repository.NewStockMovementRepositoryMongo(mongoClient.Collection("stock_movements"))
+	productService.EnableLedger(movementRepo)
+	productService.EnableRevisions(repository.NewProductRevisionRepositoryMongo(mongoClient.Collection("product_revisions")))
+	categoryRepo := repository.NewCategoryRepositoryMongo(mongoClient.Collection("categories"))
+	productService.EnableCategories(categoryRepo)
+	migrated, err := productService.MigrateVariants(migrateCtx)
+	if err != nil {
+		cancel()
+		log.Fatalf("products variant migration: %v", err)
+	}
+	if migrated > 0 {
+		log.Printf("migrated %d products to variants", migrated)
+	}
+	categorized, err := productService.MigrateCategories(migrateCtx)
+	if err != nil {
+		cancel()
+		log.Fatalf("products category migration: %v", err)
+	}
+	if categorized > 0 {
+		log.Printf("assigned %d products to categories", categorized)
+	}
+	inventoryService := services.NewInventoryService(productService, movementRepo, uow)
+	discrepancies, err := inventoryService.Discrepancies(migrateCtx)
+	if err != nil {
+		cancel()
+		log.Fatalf("stock ledger check: %v", err)
+	}
+	if len(discrepancies) > 0 {
+		log.Printf("stock ledger does not match stock for %d variants; review GET /api/inventory/reconcile and post with POST /api/inventory/reconcile", len(discrepancies))
+	}
+	cancel()
+	blobs, err := newBlobStore(cfg)
+	if err != nil {
+		log.Fatalf("blob store: %v", err)
+	}
+	imageService := services.NewImageService(blobs, productRepo)
+	productService.OnChange(imageService.ProductChanged)
+	productService.EnableImageCheck(imageService.Stored)
+	productHandler := handlers.NewProductHandler(productService, imageService)
+	inventoryHandler := handlers.NewInventoryHandler(inventoryService)
+
+	searchCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	if err := productService.EnableSearch(searchCtx, search.NewIndex()); err != nil {
+		cancel()
+		log.Fatalf("search index: %v", err)
+	}
+	suggester := search.NewSuggester(productRepo.FindAll)
+	if err := productService.EnableSuggestions(searchCtx, suggester); err != nil {
+		cancel()
+		log.Fatalf("search suggestions: %v", err)
+	}
+	cancel()
+	go suggester.Run(context.Background())
+	searchHandler := handlers.NewSearchHandler(productService)
+	categoryService := services.NewCategoryService(categoryRepo, productService)
+	categoryHandler := handlers.NewCategoryHandler(categoryService)
 
 	userCol := mongoClient.Collection("users")
 	userRepo := repository.NewUserRepository(userCol)
 	authService := services.NewAuthService(userRepo)
-	authHandler := handlers.NewAuthHandler(authService)
 
 	orderItemCol := mongoClient.Collection("order_items")
 	orderCol := mongoClient.Collection("orders")
-	indexCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	if err := repository.EnsureMongoIndexes(indexCtx, orderCol, orderItemCol); err != nil {
-		cancel()
-		log.Fatalf("MongoDB indexes: %v", err)
-	}
-	cancel()
 	orderItemRepo := repository.NewOrderItemRepositoryMongo(orderItemCol)
 	orderRepo := repository.NewOrderRepositoryMongo(orderCol, orderItemRepo)
-	orderService := services.NewOrderService(orderRepo, productRepo, userRepo)
+	orderService := services.NewOrderService(orderRepo, productRepo, userRepo, movementRepo, uow)
 	orderHandler := handlers.NewOrderHandler(orderService)
+	promotionRepo := repository.NewPromotionRepositoryMongo(mongoClient.Collection("promotions"), mongoClient.Collection("promotion_redemptions"))
+	promotionService := services.NewPromotionService(promotionRepo, productRepo, categoryService)
+	orderService.EnablePromotions(promotionService)
+	orderService.EnableDeliveryFee(cfg.DeliveryFee, cfg.FreeDeliveryOver)
+	promotionHandler := handlers.NewPromotionHandler(promotionService)
+	reviewRepo := repository.NewReviewRepositoryMongo(mongoClient.Collection("reviews"))
+	reviewService := services.NewReviewService(reviewRepo, orderRepo, productRepo)
+	reviewHandler := handlers.NewReviewHandler(reviewService, productService)
+	recommendationService := services.NewRecommendationService(repository.NewRecommendationRepositoryMongo(mongoClient.Collection("recommendations")), orderRepo, productRepo)
+	go recommendationService.Run(context.Background(), time.Hour)
+	recommendationHandler := handlers.NewRecommendationHandler(recommendationService)
+	rankingService := services.NewRankingService(repository.NewRankingRepositoryMongo(mongoClient.Collection("ranking_settings")), orderRepo, productRepo)
+	productService.EnableRanking(rankingService)
+	go rankingService.Run(context.Background(), 5*time.Minute)
+	rankingHandler := handlers.NewRankingHandler(rankingService)
+	inventoryCollector := services.NewInventoryCollector(productRepo, orderRepo, cfg.LowStockThreshold)
+	go inventoryCollector.Run(context.Background(), time.Minute)
+	go services.NewSaleScheduler(productRepo).Run(context.Background(), time.Minute)
+
+	cartRepo := repository.NewCartRepositoryMongo(mongoClient.Collection("carts"))
+	cartService := services.NewCartService(cartRepo, productRepo, orderService, cfg.JWTSecret)
+	cartHandler := handlers.NewCartHandler(cartService)
+
+	wishlistRepo := repository.NewWishlistRepositoryMongo(mongoClient.Collection("wishlists"))
+	notificationRepo := repository.NewNotificationRepositoryMongo(mongoClient.Collection("wishlist_notifications"))
+	wishlistService := services.NewWishlistService(wishlistRepo, notificationRepo, productRepo)
+	productService.OnChange(wishlistService.ProductChanged)
+	wishlistHandler := handlers.NewWishlistHandler(wishlistService)
+
+	authHandler := handlers.NewAuthHandler(authService, cartService)
 
 	analyticsService := services.NewAnalyticsService(orderRepo, productRepo, userRepo)
 	analyticsHandler := handlers.NewAnalyticsHandler(analyticsService)
 
-	pageHandler, err := handlers.NewPageHandler(productService, orderService, authService, analyticsService, "templates")
+	pageHandler, err := handlers.NewPageHandler(productService, categoryService, reviewService, recommendationService, orderService, authService, analyticsService, wishlistService, "templates")
 	if err != nil {
 		log.Fatalf("templates: %v", err)
 	}
 
-	api.SetUpRouters(server, orderHandler, productHandler, authHandler, pageHandler, analyticsHandler, authService)
+	api.SetUpRouters(server, orderHandler, productHandler, authHandler, pageHandler, analyticsHandler, cartHandler, wishlistHandler, searchHandler, inventoryHandler, categoryHandler, reviewHandler, recommendationHandler, rankingHandler, promotionHandler, authService)
 
 	addr := ":" + cfg.Port
 	if err := server.Run(addr); err != nil {
 		log.Fatal(err)
 	}
 }
+
+func newBlobStore(cfg *config.Config) (storage.BlobStore, error) {
+	switch cfg.BlobStore {
+	case "local":
+		return storage.NewLocalStore(filepath.Join("static", "assets"), "/static/assets/"), nil
+	case "s3":
+		return storage.NewS3Store(storage.S3Config(cfg.S3))
+	}
+	return nil, fmt.Errorf("unknown BLOB_STORE %q", cfg.BlobStore)
+}
@@ -7,12 +7,14 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func SetUpRouters(r *gin.Engine, orderHandler *handlers.OrderHandler, productHandler *handlers.ProductHandler, authHandler *handlers.AuthHandler, pageHandler *handlers.PageHandler, analyticsHandler *handlers.AnalyticsHandler, authSvc *services.AuthService) {
+func SetUpRouters(r *gin.Engine, orderHandler *handlers.OrderHandler, productHandler *handlers.ProductHandler, authHandler *handlers.AuthHandler, pageHandler *handlers.PageHandler, analyticsHandler *handlers.AnalyticsHandler, cartHandler *handlers.CartHandler, wishlistHandler *handlers.WishlistHandler, searchHandler *handlers.SearchHandler, inventoryHandler *handlers.InventoryHandler, categoryHandler *handlers.CategoryHandler, reviewHandler *handlers.ReviewHandler, recommendationHandler *handlers.RecommendationHandler, rankingHandler *handlers.RankingHandler, promotionHandler *handlers.PromotionHandler, authSvc *services.AuthService) {
 	r.Use(middleware.Metrics(), middleware.Logger(), middleware.CORS(), middleware.Auth(authSvc))
 
 	r.GET("/", pageHandler.Index)
 	r.GET("/shop", pageHandler.Shop)
+	r.GET("/shop/c/:slug", pageHandler.CategoryPage)
 	r.GET("/product/:id", pageHandler.Product)
+	r.GET("/media/*key", productHandler.Media)
 	r.GET("/account", pageHandler.Account)
 	r.GET("/wishlist", pageHandler.Wishlist)
 	r.GET("/cart", pageHandler.Cart)
@@ -30,6 +32,7 @@ func SetUpRouters(r *gin.Engine, orderHandler *handlers.OrderHandler, productHan
 		admin.GET("/users", pageHandler.AdminUsers)
 		admin.GET("/users/:userId/orders", pageHandler.AdminUserOrders)
 		admin.GET("/analytics", pageHandler.AdminAnalytics)
+		admin.GET("/reviews", pageHandler.AdminReviews)
 	}
 
 	auth := r.Group("/auth")
@@ -41,6 +44,7 @@ func SetUpRouters(r *gin.Engine, orderHandler *handlers.OrderHandler, productHan
 	}
 
 	orders := r.Group("/orders")
+	orders.Use(middleware.RequireAuthJSON)
 	{
 		orders.GET("", orderHandler.ListOrdersByUser)
 		orders.POST("", orderHandler.CreateOrder)
@@ -52,9 +56,38 @@ func SetUpRouters(r *gin.Engine, orderHandler *handlers.OrderHandler, productHan
 	{
 		api.GET("/product", productHandler.GetProducts)
 		api.GET("/product/:id", productHandler.GetProductByID)
+		api.GET("/product/:id/reviews", reviewHandler.List)
+		api.POST("/product/:id/reviews", middleware.RequireAuthJSON, reviewHandler.Submit)
+		api.GET("/product/:id/recommendations", recommendationHandler.ForProduct)
+		api.GET("/recommendations", recommendationHandler.ForProducts)
+		api.GET("/search", searchHandler.Search)
+		api.GET("/search/suggest", searchHandler.Suggest)
+		api.GET("/categories", categoryHandler.Tree)
+		api.GET("/categories/:id", categoryHandler.Get)
+
+		cart := api.Group("/cart")
+		{
+			cart.GET("/items", cartHandler.GetCart)
+			cart.POST("/items", cartHandler.AddItem)
+			cart.DELETE("/items", cartHandler.Clear)
+			cart.PATCH("/items/:itemId", cartHandler.UpdateItem)
+			cart.DELETE("/items/:itemId", cartHandler.RemoveItem)
+			cart.POST("/apply-code", cartHandler.ApplyCode)
+			cart.POST("/checkout", middleware.RequireAuthJSON, cartHandler.Checkout)
+		}
+
+		wishlist := api.Group("/wishlist")
+		wishlist.Use(middleware.RequireAuthJSON)
+		{
+			wishlist.GET("", wishlistHandler.List)
+			wishlist.POST("/items", wishlistHandler.AddItem)
+			wishlist.DELETE("/items/:productId", wishlistHandler.RemoveItem)
+			wishlist.GET("/notifications", wishlistHandler.Notifications)
+			wishlist.POST("/notifications/read", wishlistHandler.MarkNotificationsRead)
+		}
 
 		analytics := api.Group("/analytics")
-		analytics.Use(middleware.RequireAuth, middleware.RequireAdmin)
+		analytics.Use(middleware.RequireAuthJSON, middleware.RequireAdminJSON)
 		{
 			analytics.GET("/stats", analyticsHandler.DashboardStatsHandler())
 			analytics.GET("/top-products", analyticsHandler.TopProductsHandler())
@@ -63,9 +96,37 @@ func SetUpRouters(r *gin.Engine, orderHandler *handlers.OrderHandler, productHan
 		}
 
 		adminAPI := api.Group("")
-		adminAPI.Use(middleware.RequireAuth, middleware.RequireAdmin)
+		adminAPI.Use(middleware.RequireAuthJSON, middleware.RequireAdminJSON)
 		adminAPI.POST("/product", productHandler.CreateProduct)
 		adminAPI.PUT("/product/:id", productHandler.UpdateProduct)
+		adminAPI.PATCH("/product/:id", productHandler.PatchProduct)
 		adminAPI.DELETE("/product/:id", productHandler.DeleteProduct)
+		adminAPI.POST("/product/:id/restore", productHandler.RestoreProduct)
+		adminAPI.DELETE("/product/:id/permanent", productHandler.PurgeProduct)
+		adminAPI.GET("/product/:id/revisions", productHandler.ListRevisions)
+		adminAPI.GET("/product/:id/revisions/diff", productHandler.DiffRevisions)
+		adminAPI.POST("/product/:id/revisions/:version/rollback", productHandler.RollbackRevision)
+		adminAPI.POST("/product/:id/images", productHandler.UploadImages)
+		adminAPI.PUT("/product/:id/images/order", productHandler.ReorderImages)
+		adminAPI.DELETE("/product/:id/images/:key", productHandler.RemoveImage)
+		adminAPI.POST("/admin/products/import", productHandler.ImportProducts)
+		adminAPI.GET("/admin/products/export", productHandler.ExportProducts)
+		adminAPI.POST("/categories", categoryHandler.Create)
+		adminAPI.PUT("/categories/:id", categoryHandler.Update)
+		adminAPI.DELETE("/categories/:id", categoryHandler.Delete)
+		adminAPI.GET("/admin/reviews", reviewHandler.Queue)
+		adminAPI.PATCH("/admin/reviews/:id", reviewHandler.Moderate)
+		adminAPI.DELETE("/admin/reviews/:id", reviewHandler.Delete)
+		adminAPI.GET("/admin/ranking", rankingHandler.Weights)
+		adminAPI.PUT("/admin/ranking", rankingHandler.SetWeights)
+		adminAPI.GET("/admin/promotions", promotionHandler.List)
+		adminAPI.GET("/admin/promotions/:id", promotionHandler.Get)
+		adminAPI.POST("/admin/promotions", promotionHandler.Create)
+		adminAPI.PUT("/admin/promotions/:id", promotionHandler.Update)
+		adminAPI.DELETE("/admin/promotions/:id", promotionHandler.Delete)
+		adminAPI.GET("/inventory/reconcile", inventoryHandler.Reconciliation)
+		adminAPI.POST("/inventory/reconcile", inventoryHandler.Reconcile)
+		adminAPI.GET("/inventory/:productId", inventoryHandler.History)
+		adminAPI.POST("/inventory/:productId/movements", inventoryHandler.PostMovement)
 	}
 }
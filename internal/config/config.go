@@ -1,11 +1,33 @@
 package config
 
-import "os"
+import (
+	"os"
+	"strconv"
+)
 
 type Config struct {
 	MongoURI  string
 	Port      string
 	JWTSecret string
+	// LowStockThreshold applies to products without their own threshold.
+	LowStockThreshold int
+	// DeliveryFee is charged for courier and post delivery, unless the
+	// subtotal reaches FreeDeliveryOver; both default to 0.
+	DeliveryFee      float64
+	FreeDeliveryOver float64
+	// BlobStore selects where media is kept: "local" (default) or "s3".
+	BlobStore string
+	S3        S3Config
+}
+
+type S3Config struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool
+	PublicURL string
 }
 
 func Load() *Config {
@@ -19,9 +41,35 @@ func Load() *Config {
 		secret = "dev_secret_change_me"
 	}
 
+	// Unset or invalid falls back to the services default.
+	lowStock, _ := strconv.Atoi(os.Getenv("LOW_STOCK_THRESHOLD"))
+
+	// Unset, invalid or negative leaves delivery free.
+	deliveryFee, _ := strconv.ParseFloat(os.Getenv("DELIVERY_FEE"), 64)
+	freeDeliveryOver, _ := strconv.ParseFloat(os.Getenv("FREE_DELIVERY_THRESHOLD"), 64)
+
+	blobStore := os.Getenv("BLOB_STORE")
+	if blobStore == "" {
+		blobStore = "local"
+	}
+	pathStyle, _ := strconv.ParseBool(os.Getenv("S3_PATH_STYLE"))
+
 	return &Config{
-		MongoURI:  os.Getenv("MONGODB_URI"),
-		Port:      port,
-		JWTSecret: secret,
+		MongoURI:          os.Getenv("MONGODB_URI"),
+		Port:              port,
+		JWTSecret:         secret,
+		LowStockThreshold: lowStock,
+		DeliveryFee:       max(deliveryFee, 0),
+		FreeDeliveryOver:  max(freeDeliveryOver, 0),
+		BlobStore:         blobStore,
+		S3: S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			PathStyle: pathStyle,
+			PublicURL: os.Getenv("S3_PUBLIC_URL"),
+		},
 	}
 }
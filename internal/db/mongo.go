@@ -30,6 +30,25 @@ func (c *MongoDBClient) Collection(name string) *mongo.Collection {
 	return c.client.Database(c.database).Collection(name)
 }
 
+// WithTransaction runs fn inside a multi-document transaction, retrying it on
+// transient errors. Operations join the transaction only when they use the
+// context passed to fn. Called with a context that already carries one, fn
+// joins the outer transaction.
+func (c *MongoDBClient) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if mongo.SessionFromContext(ctx) != nil {
+		return fn(ctx)
+	}
+	session, err := c.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
+
 func (c *MongoDBClient) Close(ctx context.Context) error {
 	return c.client.Disconnect(ctx)
 }
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"log"
 	"net/http"
 
 	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/services"
@@ -8,11 +9,12 @@ import (
 )
 
 type AuthHandler struct {
-	auth *services.AuthService
+	auth  *services.AuthService
+	carts *services.CartService
 }
 
-func NewAuthHandler(auth *services.AuthService) *AuthHandler {
-	return &AuthHandler{auth: auth}
+func NewAuthHandler(auth *services.AuthService, carts *services.CartService) *AuthHandler {
+	return &AuthHandler{auth: auth, carts: carts}
 }
 
 func (h *AuthHandler) Register(c *gin.Context) {
@@ -83,6 +85,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 	c.SetCookie("auth_token", token, 24*3600, "/", "", false, true)
+	h.mergeGuestCart(c, token)
 	if c.GetHeader("Content-Type") == "application/json" {
 		c.JSON(http.StatusOK, gin.H{"token": token})
 		return
@@ -90,6 +93,28 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	c.Redirect(http.StatusFound, "/account")
 }
 
+// mergeGuestCart moves whatever the visitor put in their cart before logging
+// in into their account cart. A failed merge must not fail the login.
+func (h *AuthHandler) mergeGuestCart(c *gin.Context, token string) {
+	cookie, err := c.Cookie(cartCookieName)
+	if err != nil || cookie == "" {
+		return
+	}
+	sessionID, ok := h.carts.ParseSession(cookie)
+	if !ok {
+		return
+	}
+	user, err := h.auth.ParseToken(c.Request.Context(), token)
+	if err != nil {
+		return
+	}
+	if err := h.carts.Merge(c.Request.Context(), sessionID, user["id"]); err != nil {
+		log.Printf("merge guest cart: %v", err)
+		return
+	}
+	c.SetCookie(cartCookieName, "", -1, "/", "", false, true)
+}
+
 func (h *AuthHandler) Logout(c *gin.Context) {
 	c.SetCookie("auth_token", "", -1, "/", "", false, true)
 	c.Redirect(http.StatusFound, "/")
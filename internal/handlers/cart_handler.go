@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	cartCookieName   = "cart_session"
+	cartCookieMaxAge = 30 * 24 * 3600
+)
+
+type CartHandler struct {
+	carts *services.CartService
+}
+
+func NewCartHandler(carts *services.CartService) *CartHandler {
+	return &CartHandler{carts: carts}
+}
+
+func (h *CartHandler) GetCart(c *gin.Context) {
+	view, err := h.carts.Get(c.Request.Context(), h.owner(c, false))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, view)
+}
+
+func (h *CartHandler) AddItem(c *gin.Context) {
+	var req models.AddCartItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	view, err := h.carts.AddItem(c.Request.Context(), h.owner(c, true), &req)
+	if err != nil {
+		writeCartError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, view)
+}
+
+func (h *CartHandler) UpdateItem(c *gin.Context) {
+	var body struct {
+		Quantity int `json:"quantity"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	view, err := h.carts.UpdateItem(c.Request.Context(), h.owner(c, false), c.Param("itemId"), body.Quantity)
+	if err != nil {
+		writeCartError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, view)
+}
+
+func (h *CartHandler) RemoveItem(c *gin.Context) {
+	view, err := h.carts.RemoveItem(c.Request.Context(), h.owner(c, false), c.Param("itemId"))
+	if err != nil {
+		writeCartError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, view)
+}
+
+func (h *CartHandler) Clear(c *gin.Context) {
+	if err := h.carts.Clear(c.Request.Context(), h.owner(c, false)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.CartView{Items: []models.CartLine{}})
+}
+
+func (h *CartHandler) Checkout(c *gin.Context) {
+	var req models.CheckoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	order, err := h.carts.Checkout(c.Request.Context(), c.GetString("user_id"), &req)
+	if err != nil {
+		if writeOrderValidationError(c, err) {
+			return
+		}
+		writeCartError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, order)
+}
+
+// ApplyCode previews the cart's totals with a promo code; the code is only
+// used up when the order is placed.
+func (h *CartHandler) ApplyCode(c *gin.Context) {
+	var req models.ApplyCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	quote, err := h.carts.ApplyCode(c.Request.Context(), h.owner(c, false), &req)
+	if err != nil {
+		if writeOrderValidationError(c, err) {
+			return
+		}
+		writeCartError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, quote)
+}
+
+// owner resolves whose cart the request addresses. Anonymous visitors get a
+// signed session cookie the first time they add something.
+func (h *CartHandler) owner(c *gin.Context, create bool) services.CartOwner {
+	if id := c.GetString("user_id"); id != "" {
+		return services.CartOwner{UserID: id}
+	}
+	if token, err := c.Cookie(cartCookieName); err == nil {
+		if sid, ok := h.carts.ParseSession(token); ok {
+			return services.CartOwner{SessionID: sid}
+		}
+	}
+	if !create {
+		return services.CartOwner{}
+	}
+	sid, token, err := h.carts.NewSession()
+	if err != nil {
+		return services.CartOwner{}
+	}
+	c.SetCookie(cartCookieName, token, cartCookieMaxAge, "/", "", false, true)
+	return services.CartOwner{SessionID: sid}
+}
+
+func writeCartError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrCartItemNotFound), errors.Is(err, services.ErrProductNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidCartItem), errors.Is(err, services.ErrCartEmpty),
+		errors.Is(err, services.ErrPromotionNotApplicable):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrCartStock), errors.Is(err, services.ErrPromotionUsedUp):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type CategoryHandler struct {
+	categories *services.CategoryService
+}
+
+func NewCategoryHandler(categories *services.CategoryService) *CategoryHandler {
+	return &CategoryHandler{categories: categories}
+}
+
+// Tree returns every category nested under its parent, with product counts.
+func (h *CategoryHandler) Tree(c *gin.Context) {
+	tree, err := h.categories.Tree(c.Request.Context())
+	if err != nil {
+		writeCategoryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, tree)
+}
+
+func (h *CategoryHandler) Get(c *gin.Context) {
+	category, err := h.categories.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		writeCategoryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, category)
+}
+
+func (h *CategoryHandler) Create(c *gin.Context) {
+	var req models.Category
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	category, err := h.categories.Create(c.Request.Context(), &req)
+	if err != nil {
+		writeCategoryError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, category)
+}
+
+func (h *CategoryHandler) Update(c *gin.Context) {
+	var req models.Category
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	category, err := h.categories.Update(c.Request.Context(), c.Param("id"), &req)
+	if err != nil {
+		writeCategoryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, category)
+}
+
+func (h *CategoryHandler) Delete(c *gin.Context) {
+	if err := h.categories.Delete(c.Request.Context(), c.Param("id")); err != nil {
+		writeCategoryError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func writeCategoryError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidCategory):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrCategoryNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrCategoryInUse), errors.Is(err, services.ErrDuplicateCategorySlug):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type InventoryHandler struct {
+	inventory *services.InventoryService
+}
+
+func NewInventoryHandler(inventory *services.InventoryService) *InventoryHandler {
+	return &InventoryHandler{inventory: inventory}
+}
+
+// History returns a product's per-variant levels and its latest movements.
+func (h *InventoryHandler) History(c *gin.Context) {
+	ctx := c.Request.Context()
+	productID := c.Param("productId")
+	levels, err := h.inventory.Levels(ctx, productID)
+	if err != nil {
+		writeInventoryError(c, err)
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	movements, err := h.inventory.History(ctx, productID, limit)
+	if err != nil {
+		writeInventoryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"product_id": productID, "levels": levels, "movements": movements})
+}
+
+func (h *InventoryHandler) PostMovement(c *gin.Context) {
+	var req models.StockAdjustmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	movement, err := h.inventory.Adjust(c.Request.Context(), c.Param("productId"), &req, getStr(c, "user_id"))
+	if err != nil {
+		writeInventoryError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, movement)
+}
+
+// Reconciliation previews the adjustments Reconcile would post.
+func (h *InventoryHandler) Reconciliation(c *gin.Context) {
+	adjustments, err := h.inventory.Discrepancies(c.Request.Context())
+	if err != nil {
+		writeInventoryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"adjustments": adjustments})
+}
+
+// Reconcile posts an adjustment for every variant whose ledger does not add
+// up to its stock.
+func (h *InventoryHandler) Reconcile(c *gin.Context) {
+	adjustments, err := h.inventory.Reconcile(c.Request.Context(), getStr(c, "user_id"))
+	if err != nil {
+		writeInventoryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"posted": len(adjustments), "adjustments": adjustments})
+}
+
+func writeInventoryError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidMovement):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrProductNotFound), errors.Is(err, services.ErrVariantNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInsufficientStock):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
@@ -22,12 +23,26 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if req.UserID == "" || !isAdmin(c) {
+		req.UserID = c.GetString("user_id")
+	}
 	order, err := h.svc.Create(c.Request.Context(), &req)
 	if err != nil {
-		if err == services.ErrUserNotFound || err == services.ErrProductNotFound {
+		if writeOrderValidationError(c, err) {
+			return
+		}
+		if err == services.ErrUserNotFound || err == services.ErrProductNotFound || err == services.ErrEmptyOrder {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		if errors.Is(err, services.ErrPromotionNotApplicable) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrPromotionUsedUp) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -49,6 +64,10 @@ func (h *OrderHandler) GetOrderStatus(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
 		return
 	}
+	if !canAccessOrder(c, order) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+		return
+	}
 	c.JSON(http.StatusOK, order)
 }
 
@@ -60,26 +79,52 @@ func (h *OrderHandler) UpdateOrderStatus(c *gin.Context) {
 	}
 	var body struct {
 		Status string `json:"status" binding:"required"`
+		Note   string `json:"note"`
 	}
 	if err := c.ShouldBindJSON(&body); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if err := h.svc.UpdateStatus(c.Request.Context(), id, body.Status); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if !isAdmin(c) {
+		existing, err := h.svc.GetByID(c.Request.Context(), id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if existing == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "order not found"})
+			return
+		}
+		if !canAccessOrder(c, existing) || body.Status != models.OrderStatusCancelled {
+			c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+			return
+		}
+	}
+	order, err := h.svc.UpdateStatus(c.Request.Context(), id, body.Status, c.GetString("user_id"), body.Note)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidStatus):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrOrderNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrInvalidStatusTransition):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"id": id, "status": body.Status})
+	c.JSON(http.StatusOK, gin.H{"id": order.ID, "status": order.Status, "status_history": order.StatusHistory})
 }
 
 func (h *OrderHandler) ListOrdersByUser(c *gin.Context) {
-	userID := c.Param("userId")
-	if userID == "" {
-		userID = c.Query("user_id")
-	}
-	if userID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id required (query or path)"})
-		return
+	userID := c.GetString("user_id")
+	if requested := c.Query("user_id"); requested != "" && requested != userID {
+		if !isAdmin(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+			return
+		}
+		userID = requested
 	}
 	orders, err := h.svc.ListByUser(c.Request.Context(), userID)
 	if err != nil {
@@ -88,3 +133,28 @@ func (h *OrderHandler) ListOrdersByUser(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, orders)
 }
+
+// writeOrderValidationError answers 409 when only stock blocked the order and
+// 422 for any other rejected line. It reports whether err was handled.
+func writeOrderValidationError(c *gin.Context, err error) bool {
+	var verr *services.OrderValidationError
+	if !errors.As(err, &verr) {
+		return false
+	}
+	status := http.StatusUnprocessableEntity
+	if verr.StockOnly() {
+		status = http.StatusConflict
+	}
+	c.JSON(status, gin.H{"error": verr.Error(), "lines": verr.Lines})
+	return true
+}
+
+func isAdmin(c *gin.Context) bool {
+	return getStr(c, "user_role") == "admin"
+}
+
+// canAccessOrder allows the order's owner and admins; customers only ever see
+// their own orders.
+func canAccessOrder(c *gin.Context, order *models.Order) bool {
+	return isAdmin(c) || order.UserID == c.GetString("user_id")
+}
@@ -1,12 +1,11 @@
 package handlers
 
 import (
+	"errors"
 	"html/template"
 	"net/http"
 	"net/url"
 	"path/filepath"
-	"sort"
-	"strings"
 
 	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
 	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/services"
@@ -15,19 +14,24 @@ import (
 
 type PageHandler struct {
 	productService   *services.ProductService
+	categoryService  *services.CategoryService
+	reviewService    *services.ReviewService
+	recommendations  *services.RecommendationService
 	orderService     *services.OrderService
 	authService      *services.AuthService
 	analyticsService *services.AnalyticsService
+	wishlistService  *services.WishlistService
 	templates        map[string]*template.Template
 }
 
-func NewPageHandler(productService *services.ProductService, orderService *services.OrderService, authService *services.AuthService, analyticsService *services.AnalyticsService, templateDir string) (*PageHandler, error) {
+func NewPageHandler(productService *services.ProductService, categoryService *services.CategoryService, reviewService *services.ReviewService, recommendations *services.RecommendationService, orderService *services.OrderService, authService *services.AuthService, analyticsService *services.AnalyticsService, wishlistService *services.WishlistService, templateDir string) (*PageHandler, error) {
 	basePath := filepath.Join(templateDir, "base.html")
 	pages := []string{
 		"shop", "index", "account", "login", "register",
 		"admin_orders", "admin_products", "admin_dashboard",
 		"admin_users", "admin_analytics", "account_orders",
 		"product", "wishlist", "cart", "checkout",
+		"admin_reviews",
 	}
 
 	templates := make(map[string]*template.Template)
@@ -38,9 +42,13 @@ func NewPageHandler(productService *services.ProductService, orderService *servi
 
 	return &PageHandler{
 		productService:   productService,
+		categoryService:  categoryService,
+		reviewService:    reviewService,
+		recommendations:  recommendations,
 		orderService:     orderService,
 		authService:      authService,
 		analyticsService: analyticsService,
+		wishlistService:  wishlistService,
 		templates:        templates,
 	}, nil
 }
@@ -66,47 +74,104 @@ func (h *PageHandler) Index(c *gin.Context) {
 }
 
 func (h *PageHandler) Shop(c *gin.Context) {
-	products, err := h.productService.List(c.Request.Context())
+	h.renderShop(c, nil)
+}
+
+// CategoryPage is the shop narrowed to a category and its subcategories.
+func (h *PageHandler) CategoryPage(c *gin.Context) {
+	category, err := h.categoryService.GetBySlug(c.Request.Context(), c.Param("slug"))
+	if errors.Is(err, services.ErrCategoryNotFound) {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	q := c.Query("q")
-	sort := c.Query("sort")
-	if sort == "" {
-		sort = "recommended"
-	}
-	categories := c.QueryArray("category")
-	genders := c.QueryArray("gender")
-	colors := c.QueryArray("color")
-	sizes := c.QueryArray("size")
-	selectedCategories := toSelectionMap(categories)
-	selectedGenders := toSelectionMap(genders)
-	selectedColors := toSelectionMap(colors)
-	selectedSizes := toSelectionMap(sizes)
-	categoryCounts := buildCategoryCounts(products)
-	colorCounts := buildColorCounts(products)
-	filtered := filterProducts(products, q, categories, colors, sizes, genders)
-	ordered := sortProducts(filtered, sort)
-	chips, clearURL := buildFilterChips(c.Request.URL.Query())
+	h.renderShop(c, category)
+}
+
+func (h *PageHandler) renderShop(c *gin.Context, category *models.Category) {
+	ctx := c.Request.Context()
+	base := "/shop"
+	query := productQueryFromRequest(c)
+	if query.Sort == "" {
+		query.Sort = "recommended"
+	}
+	var path []*models.Category
+	if category != nil {
+		base = categoryURL(category)
+		ids, err := h.categoryService.Subtree(ctx, category.ID)
+		if err == nil {
+			path, err = h.categoryService.Path(ctx, category.ID)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		query.CategoryIDs = ids
+	}
+	page, err := h.productService.Search(ctx, query)
+	if errors.Is(err, services.ErrInvalidCursor) {
+		c.Redirect(http.StatusFound, buildShopURL(base, removeQueryKey(c.Request.URL.Query(), "cursor")))
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	facets, err := h.productService.Facets(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	tree, err := h.categoryService.Tree(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	values := c.Request.URL.Query()
+	chips, clearURL := buildFilterChips(base, removeQueryKey(values, "cursor"))
 
 	data := h.getUserData(c)
-	data["Products"] = ordered
-	data["SearchQuery"] = q
-	data["Sort"] = sort
+	data["ShopPath"] = base
+	data["Category"] = category
+	data["CategoryPath"] = path
+	data["CategoryLinks"] = categoryLinks(tree, category, 0, nil)
+	data["Products"] = page.Items
+	data["Total"] = page.Total
+	data["SearchQuery"] = query.Text
+	data["Sort"] = query.Sort
 	data["ShowSidebar"] = true
-	data["SelectedCategoryList"] = categories
-	data["SelectedGenderList"] = genders
-	data["SelectedColorList"] = colors
-	data["SelectedSizeList"] = sizes
-	data["SelectedCategories"] = selectedCategories
-	data["SelectedGenders"] = selectedGenders
-	data["Categories"] = categoryCounts
-	data["Colors"] = colorCounts
-	data["SelectedColors"] = selectedColors
-	data["SelectedSizes"] = selectedSizes
+	data["SelectedCategoryList"] = query.Categories
+	data["SelectedGenderList"] = query.Genders
+	data["SelectedColorList"] = query.Colors
+	data["SelectedSizeList"] = query.Sizes
+	data["SelectedCategories"] = toSelectionMap(query.Categories)
+	data["SelectedGenders"] = toSelectionMap(query.Genders)
+	data["Categories"] = facets.Categories
+	data["Colors"] = facets.Colors
+	data["SelectedColors"] = toSelectionMap(query.Colors)
+	data["SelectedSizes"] = toSelectionMap(query.Sizes)
+	data["MinPrice"] = c.Query("min_price")
+	data["MaxPrice"] = c.Query("max_price")
+	data["InStockOnly"] = query.InStockOnly
 	data["FilterChips"] = chips
 	data["ClearFiltersURL"] = clearURL
+	snippets := make(map[string]template.HTML, len(page.Snippets))
+	for id, snippet := range page.Snippets {
+		// Snippets are escaped by the search index apart from <mark> tags.
+		snippets[id] = template.HTML(snippet)
+	}
+	data["Snippets"] = snippets
+	if query.Cursor != "" {
+		data["FirstPageURL"] = buildShopURL(base, removeQueryKey(values, "cursor"))
+	}
+	if page.NextCursor != "" {
+		next := removeQueryKey(values, "cursor")
+		next.Set("cursor", page.NextCursor)
+		data["NextPageURL"] = buildShopURL(base, next)
+	}
 
 	c.Header("Content-Type", "text/html; charset=utf-8")
 	if err := h.templates["shop"].ExecuteTemplate(c.Writer, "base.html", data); err != nil {
@@ -161,6 +226,7 @@ func (h *PageHandler) AdminOrders(c *gin.Context) {
 	}
 	data := h.getUserData(c)
 	data["Orders"] = orders
+	data["Transitions"] = statusTransitions(orders)
 
 	c.Header("Content-Type", "text/html; charset=utf-8")
 	if err := h.templates["admin_orders"].ExecuteTemplate(c.Writer, "base.html", data); err != nil {
@@ -213,6 +279,37 @@ func (h *PageHandler) AdminUsers(c *gin.Context) {
 	}
 }
 
+func (h *PageHandler) AdminReviews(c *gin.Context) {
+	status := c.DefaultQuery("status", models.ReviewPending)
+	reviews, err := h.reviewService.Queue(c.Request.Context(), status, services.MaxReviewQueue)
+	if errors.Is(err, services.ErrInvalidReview) {
+		c.Redirect(http.StatusFound, "/admin/reviews")
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	names := make(map[string]string, len(reviews))
+	for _, r := range reviews {
+		if _, ok := names[r.ProductID]; ok {
+			continue
+		}
+		if p, err := h.productService.GetByID(c.Request.Context(), r.ProductID); err == nil && p != nil {
+			names[r.ProductID] = p.Name
+		}
+	}
+	data := h.getUserData(c)
+	data["Reviews"] = reviews
+	data["Status"] = status
+	data["ProductNames"] = names
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	if err := h.templates["admin_reviews"].ExecuteTemplate(c.Writer, "base.html", data); err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+	}
+}
+
 func (h *PageHandler) AdminUserOrders(c *gin.Context) {
 	userID := c.Param("userId")
 	if userID == "" {
@@ -231,6 +328,7 @@ func (h *PageHandler) AdminUserOrders(c *gin.Context) {
 	}
 	data := h.getUserData(c)
 	data["Orders"] = orders
+	data["Transitions"] = statusTransitions(orders)
 	data["FilterUser"] = user
 
 	c.Header("Content-Type", "text/html; charset=utf-8")
@@ -281,12 +379,54 @@ func (h *PageHandler) Product(c *gin.Context) {
 		return
 	}
 	product, err := h.productService.GetByID(c.Request.Context(), id)
-	if err != nil {
+	if err != nil || product == nil || product.Archived() {
 		c.AbortWithStatus(http.StatusNotFound)
 		return
 	}
 	data := h.getUserData(c)
 	data["Product"] = product
+	if product.CategoryID != "" {
+		// A category deleted since leaves the plain name in the breadcrumb.
+		if path, err := h.categoryService.Path(c.Request.Context(), product.CategoryID); err == nil {
+			data["CategoryPath"] = path
+		}
+	}
+	ctx := c.Request.Context()
+	reviews, err := h.reviewService.Approved(ctx, product.ID)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	data["Reviews"] = reviews
+	recs, err := h.recommendations.ForProduct(ctx, product.ID, services.DefaultRecommendations)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	var together, similar []*models.Product
+	for _, r := range recs {
+		if r.Reason == models.RecommendBoughtTogether {
+			together = append(together, r.Product)
+		} else {
+			similar = append(similar, r.Product)
+		}
+	}
+	data["BoughtTogether"] = together
+	data["Similar"] = similar
+	if userID := getStr(c, "user_id"); userID != "" {
+		mine, err := h.reviewService.Mine(ctx, userID, product.ID)
+		if err != nil {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		orderID, err := h.reviewService.VerifiedPurchase(ctx, userID, product.ID)
+		if err != nil {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		data["MyReview"] = mine
+		data["CanReview"] = orderID != ""
+	}
 
 	c.Header("Content-Type", "text/html; charset=utf-8")
 	if err := h.templates["product"].ExecuteTemplate(c.Writer, "base.html", data); err != nil {
@@ -295,8 +435,24 @@ func (h *PageHandler) Product(c *gin.Context) {
 }
 
 func (h *PageHandler) Wishlist(c *gin.Context) {
+	data := h.getUserData(c)
+	if userID := getStr(c, "user_id"); userID != "" {
+		entries, err := h.wishlistService.List(c.Request.Context(), userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		notifications, err := h.wishlistService.Notifications(c.Request.Context(), userID, true)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		data["Items"] = entries
+		data["Notifications"] = notifications
+	}
+
 	c.Header("Content-Type", "text/html; charset=utf-8")
-	if err := h.templates["wishlist"].ExecuteTemplate(c.Writer, "base.html", h.getUserData(c)); err != nil {
+	if err := h.templates["wishlist"].ExecuteTemplate(c.Writer, "base.html", data); err != nil {
 		c.AbortWithStatus(http.StatusInternalServerError)
 	}
 }
@@ -310,11 +466,23 @@ func (h *PageHandler) Cart(c *gin.Context) {
 
 func (h *PageHandler) Checkout(c *gin.Context) {
 	c.Header("Content-Type", "text/html; charset=utf-8")
-	if err := h.templates["checkout"].ExecuteTemplate(c.Writer, "base.html", h.getUserData(c)); err != nil {
+	data := h.getUserData(c)
+	data["DeliveryFee"], data["FreeDeliveryOver"] = h.orderService.DeliveryPricing()
+	if err := h.templates["checkout"].ExecuteTemplate(c.Writer, "base.html", data); err != nil {
 		c.AbortWithStatus(http.StatusInternalServerError)
 	}
 }
 
+func statusTransitions(orders []*models.Order) map[string][]string {
+	out := make(map[string][]string)
+	for _, o := range orders {
+		if _, ok := out[o.Status]; !ok {
+			out[o.Status] = services.NextStatuses(o.Status)
+		}
+	}
+	return out
+}
+
 func getStr(c *gin.Context, key string) string {
 	if v, ok := c.Get(key); ok && v != nil {
 		if s, ok := v.(string); ok {
@@ -324,115 +492,11 @@ func getStr(c *gin.Context, key string) string {
 	return ""
 }
 
-func filterProducts(products []*models.Product, q string, categories, colors, sizes, genders []string) []*models.Product {
-	var out []*models.Product
-	for _, p := range products {
-		if q != "" && !strings.Contains(strings.ToLower(p.Name), strings.ToLower(q)) && !strings.Contains(strings.ToLower(p.Category), strings.ToLower(q)) {
-			continue
-		}
-		if len(categories) > 0 {
-			found := false
-			for _, c := range categories {
-				if strings.EqualFold(p.Category, c) {
-					found = true
-					break
-				}
-			}
-			if !found {
-				continue
-			}
-		}
-		if len(genders) > 0 {
-			found := false
-			for _, g := range genders {
-				if isUniversalGender(g) {
-					if strings.TrimSpace(p.Gender) == "" {
-						found = true
-						break
-					}
-					continue
-				}
-				if strings.EqualFold(p.Gender, g) {
-					found = true
-					break
-				}
-			}
-			if !found {
-				continue
-			}
-		}
-		if len(colors) > 0 {
-			found := false
-			for _, col := range colors {
-				for _, pc := range p.Colors {
-					if strings.EqualFold(pc, col) {
-						found = true
-						break
-					}
-				}
-				if found {
-					break
-				}
-			}
-			if !found {
-				continue
-			}
-		}
-		if len(sizes) > 0 {
-			found := false
-			for _, size := range sizes {
-				for _, ps := range p.Sizes {
-					if strings.EqualFold(ps, size) {
-						found = true
-						break
-					}
-				}
-				if found {
-					break
-				}
-			}
-			if !found {
-				continue
-			}
-		}
-		out = append(out, p)
-	}
-	return out
-}
-
-func isUniversalGender(value string) bool {
-	return strings.EqualFold(strings.TrimSpace(value), "universal")
-}
-
-func sortProducts(products []*models.Product, order string) []*models.Product {
-	out := make([]*models.Product, len(products))
-	copy(out, products)
-	switch order {
-	case "price_asc":
-		sort.Slice(out, func(i, j int) bool { return out[i].Price < out[j].Price })
-	case "price_desc":
-		sort.Slice(out, func(i, j int) bool { return out[i].Price > out[j].Price })
-	case "name":
-		sort.Slice(out, func(i, j int) bool { return strings.ToLower(out[i].Name) < strings.ToLower(out[j].Name) })
-	}
-	return out
-}
-
 type FilterChip struct {
 	Label string
 	URL   string
 }
 
-type CategoryCount struct {
-	Name  string
-	Count int
-}
-
-type ColorCount struct {
-	Name  string
-	Count int
-}
-
 func toSelectionMap(values []string) map[string]bool {
 	out := make(map[string]bool, len(values))
 	for _, v := range values {
@@ -443,57 +507,7 @@ func toSelectionMap(values []string) map[string]bool {
 	return out
 }
 
-func buildCategoryCounts(products []*models.Product) []CategoryCount {
-	counts := make(map[string]int)
-	for _, p := range products {
-		name := strings.TrimSpace(p.Category)
-		if name == "" {
-			continue
-		}
-		counts[name]++
-	}
-	out := make([]CategoryCount, 0, len(counts))
-	for name, count := range counts {
-		out = append(out, CategoryCount{Name: name, Count: count})
-	}
-	sort.Slice(out, func(i, j int) bool {
-		return strings.ToLower(out[i].Name) < strings.ToLower(out[j].Name)
-	})
-	return out
-}
-
-func buildColorCounts(products []*models.Product) []ColorCount {
-	counts := make(map[string]int)
-	display := make(map[string]string)
-	for _, p := range products {
-		seen := make(map[string]struct{})
-		for _, color := range p.Colors {
-			name := strings.TrimSpace(color)
-			if name == "" {
-				continue
-			}
-			key := strings.ToLower(name)
-			if _, ok := seen[key]; ok {
-				continue
-			}
-			seen[key] = struct{}{}
-			counts[key]++
-			if _, ok := display[key]; !ok {
-				display[key] = name
-			}
-		}
-	}
-	out := make([]ColorCount, 0, len(counts))
-	for key, count := range counts {
-		out = append(out, ColorCount{Name: display[key], Count: count})
-	}
-	sort.Slice(out, func(i, j int) bool {
-		return strings.ToLower(out[i].Name) < strings.ToLower(out[j].Name)
-	})
-	return out
-}
-
-func buildFilterChips(values url.Values) ([]FilterChip, string) {
+func buildFilterChips(base string, values url.Values) ([]FilterChip, string) {
 	var chips []FilterChip
 	for _, v := range values["category"] {
 		if v == "" {
@@ -501,7 +515,7 @@ func buildFilterChips(values url.Values) ([]FilterChip, string) {
 		}
 		chips = append(chips, FilterChip{
 			Label: "Category: " + v,
-			URL:   buildShopURL(removeQueryValue(values, "category", v)),
+			URL:   buildShopURL(base, removeQueryValue(values, "category", v)),
 		})
 	}
 	for _, v := range values["gender"] {
@@ -510,7 +524,7 @@ func buildFilterChips(values url.Values) ([]FilterChip, string) {
 		}
 		chips = append(chips, FilterChip{
 			Label: "Gender: " + v,
-			URL:   buildShopURL(removeQueryValue(values, "gender", v)),
+			URL:   buildShopURL(base, removeQueryValue(values, "gender", v)),
 		})
 	}
 	for _, v := range values["color"] {
@@ -519,7 +533,7 @@ func buildFilterChips(values url.Values) ([]FilterChip, string) {
 		}
 		chips = append(chips, FilterChip{
 			Label: "Color: " + v,
-			URL:   buildShopURL(removeQueryValue(values, "color", v)),
+			URL:   buildShopURL(base, removeQueryValue(values, "color", v)),
 		})
 	}
 	for _, v := range values["size"] {
@@ -528,15 +542,23 @@ func buildFilterChips(values url.Values) ([]FilterChip, string) {
 		}
 		chips = append(chips, FilterChip{
 			Label: "Size: " + v,
-			URL:   buildShopURL(removeQueryValue(values, "size", v)),
+			URL:   buildShopURL(base, removeQueryValue(values, "size", v)),
 		})
 	}
+	if v := values.Get("min_price"); v != "" {
+		chips = append(chips, FilterChip{Label: "Min price: $" + v, URL: buildShopURL(base, removeQueryKey(values, "min_price"))})
+	}
+	if v := values.Get("max_price"); v != "" {
+		chips = append(chips, FilterChip{Label: "Max price: $" + v, URL: buildShopURL(base, removeQueryKey(values, "max_price"))})
+	}
+	if values.Get("in_stock") != "" {
+		chips = append(chips, FilterChip{Label: "In stock only", URL: buildShopURL(base, removeQueryKey(values, "in_stock"))})
+	}
 	clearValues := cloneValues(values)
-	clearValues.Del("category")
-	clearValues.Del("gender")
-	clearValues.Del("color")
-	clearValues.Del("size")
-	return chips, buildShopURL(clearValues)
+	for _, key := range []string{"category", "gender", "color", "size", "min_price", "max_price", "in_stock"} {
+		clearValues.Del(key)
+	}
+	return chips, buildShopURL(base, clearValues)
 }
 
 func cloneValues(values url.Values) url.Values {
@@ -561,9 +583,42 @@ func removeQueryValue(values url.Values, key, value string) url.Values {
 	return out
 }
 
-func buildShopURL(values url.Values) string {
+func removeQueryKey(values url.Values, key string) url.Values {
+	out := cloneValues(values)
+	out.Del(key)
+	return out
+}
+
+func buildShopURL(base string, values url.Values) string {
 	if len(values) == 0 {
-		return "/shop"
+		return base
 	}
-	return "/shop?" + values.Encode()
+	return base + "?" + values.Encode()
+}
+
+func categoryURL(c *models.Category) string {
+	return "/shop/c/" + url.PathEscape(c.Slug)
+}
+
+// CategoryLink is one row of the sidebar's category tree.
+type CategoryLink struct {
+	Name    string
+	URL     string
+	Count   int
+	Indent  int // pixels; subcategories are indented under their parent
+	Current bool
+}
+
+func categoryLinks(nodes []*models.CategoryNode, current *models.Category, depth int, out []CategoryLink) []CategoryLink {
+	for _, n := range nodes {
+		out = append(out, CategoryLink{
+			Name:    n.Name,
+			URL:     categoryURL(n.Category),
+			Count:   n.ProductCount,
+			Indent:  depth * 12,
+			Current: current != nil && current.ID == n.ID,
+		})
+		out = categoryLinks(n.Children, current, depth+1, out)
+	}
+	return out
 }
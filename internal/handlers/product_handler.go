@@ -1,31 +1,70 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
-	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
 	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/services"
 	"github.com/gin-gonic/gin"
 )
 
+const maxPatchBytes = 1 << 20
+
 type ProductHandler struct {
 	productService *services.ProductService
+	images         *services.ImageService
 }
 
-func NewProductHandler(svc *services.ProductService) *ProductHandler {
-	return &ProductHandler{productService: svc}
+func NewProductHandler(svc *services.ProductService, images *services.ImageService) *ProductHandler {
+	return &ProductHandler{productService: svc, images: images}
 }
 
 func (h *ProductHandler) GetProducts(c *gin.Context) {
-	products, err := h.productService.List(c.Request.Context())
+	page, err := h.productService.Search(c.Request.Context(), productQueryFromRequest(c))
+	if errors.Is(err, services.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, products)
+	c.JSON(http.StatusOK, page)
+}
+
+func productQueryFromRequest(c *gin.Context) *models.ProductQuery {
+	q := &models.ProductQuery{
+		Text:       strings.TrimSpace(c.Query("q")),
+		Categories: nonEmpty(c.QueryArray("category")),
+		Genders:    nonEmpty(c.QueryArray("gender")),
+		Colors:     nonEmpty(c.QueryArray("color")),
+		Sizes:      nonEmpty(c.QueryArray("size")),
+		Sort:       c.Query("sort"),
+		Cursor:     c.Query("cursor"),
+		UserID:     getStr(c, "user_id"),
+	}
+	q.MinPrice, _ = strconv.ParseFloat(c.Query("min_price"), 64)
+	q.MaxPrice, _ = strconv.ParseFloat(c.Query("max_price"), 64)
+	q.InStockOnly, _ = strconv.ParseBool(c.Query("in_stock"))
+	q.Limit, _ = strconv.Atoi(c.Query("limit"))
+	return q
+}
+
+func nonEmpty(values []string) []string {
+	var out []string
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
 }
 
 func (h *ProductHandler) GetProductByID(c *gin.Context) {
@@ -43,10 +82,16 @@ func (h *ProductHandler) GetProductByID(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
 		return
 	}
+	setETag(c, p)
 	c.JSON(http.StatusOK, p)
 }
 
 func (h *ProductHandler) CreateProduct(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxUploadBytes)
+	if err := parseUploadForm(c); err != nil {
+		writeImageError(c, err)
+		return
+	}
 	name := strings.TrimSpace(c.PostForm("name"))
 	priceStr := strings.TrimSpace(c.PostForm("price"))
 	category := strings.TrimSpace(c.PostForm("category"))
@@ -67,39 +112,89 @@ func (h *ProductHandler) CreateProduct(c *gin.Context) {
 		return
 	}
 
-	file, err := c.FormFile("image")
-	var imagePath string
-	if err == nil {
-		path := filepath.Join("static", "assets", "products", file.Filename)
-		if err := c.SaveUploadedFile(file, path); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save image"})
-			return
-		}
-		imagePath = "/static/assets/products/" + file.Filename
-	}
-
 	req := models.CreateProductRequest{
+		SKU:         strings.TrimSpace(c.PostForm("sku")),
 		Name:        name,
 		Description: description,
 		Category:    category,
+		CategoryID:  strings.TrimSpace(c.PostForm("category_id")),
 		Gender:      gender,
 		Price:       price,
 		Sizes:       parseCommaString(sizesStr),
 		Colors:      parseCommaString(colorsStr),
 		StockBySize: parseStockString(stockStr),
 	}
-	if imagePath != "" {
-		req.Images = []string{imagePath}
+	if thresholdStr := strings.TrimSpace(c.PostForm("low_stock_threshold")); thresholdStr != "" {
+		threshold, err := strconv.Atoi(thresholdStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "low_stock_threshold must be a whole number"})
+			return
+		}
+		req.LowStockThreshold = threshold
+	}
+	if err := parseSaleForm(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if variantsStr := strings.TrimSpace(c.PostForm("variants")); variantsStr != "" {
+		if err := json.Unmarshal([]byte(variantsStr), &req.Variants); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "variants must be a JSON array"})
+			return
+		}
 	}
 
+	images, err := h.storeUploads(c)
+	if err != nil {
+		writeImageError(c, err)
+		return
+	}
+	for _, img := range images {
+		req.Images = append(req.Images, img.Large)
+		req.Media = append(req.Media, *img)
+	}
+	req.Images = append(req.Images, parseCommaString(c.PostForm("images"))...)
+
 	p, err := h.productService.Create(c.Request.Context(), &req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		h.releaseUploads(c, images)
+		writeProductError(c, err)
 		return
 	}
+	h.images.Finish(uploadKeys(images)...)
+	setETag(c, p)
 	c.JSON(http.StatusCreated, p)
 }
 
+// parseSaleForm reads the optional sale_price, compare_at_price,
+// sale_starts_at and sale_ends_at fields; times are RFC 3339.
+func parseSaleForm(c *gin.Context, req *models.CreateProductRequest) error {
+	for _, f := range []struct {
+		name string
+		dst  **float64
+	}{{"sale_price", &req.SalePrice}, {"compare_at_price", &req.CompareAtPrice}} {
+		if v := strings.TrimSpace(c.PostForm(f.name)); v != "" {
+			price, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return fmt.Errorf("%s must be a number", f.name)
+			}
+			*f.dst = &price
+		}
+	}
+	for _, f := range []struct {
+		name string
+		dst  **time.Time
+	}{{"sale_starts_at", &req.SaleStartsAt}, {"sale_ends_at", &req.SaleEndsAt}} {
+		if v := strings.TrimSpace(c.PostForm(f.name)); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return fmt.Errorf("%s must be an RFC 3339 time", f.name)
+			}
+			*f.dst = &t
+		}
+	}
+	return nil
+}
+
 func parseCommaString(s string) []string {
 	if s == "" {
 		return []string{}
@@ -140,19 +235,98 @@ func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "id required"})
 		return
 	}
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		writeProductError(c, services.ErrVersionConflict)
+		return
+	}
 	var p models.Product
 	if err := c.ShouldBindJSON(&p); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 	p.ID = id
+	if version != 0 {
+		// A body version that disagrees with If-Match is a stale copy.
+		if p.Version != 0 && p.Version != version {
+			writeProductError(c, services.ErrVersionConflict)
+			return
+		}
+		p.Version = version
+	}
+	if p.Version <= 0 {
+		writeProductError(c, services.ErrVersionRequired)
+		return
+	}
 	if err := h.productService.Update(c.Request.Context(), id, &p); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeProductError(c, err)
 		return
 	}
+	setETag(c, &p)
 	c.JSON(http.StatusOK, p)
 }
 
+// PatchProduct applies a JSON Merge Patch. If-Match with the ETag from GET
+// is required, so a change to a product edited in between is rejected.
+func (h *ProductHandler) PatchProduct(c *gin.Context) {
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		writeProductError(c, services.ErrVersionConflict)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxPatchBytes))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	p, err := h.productService.Patch(c.Request.Context(), c.Param("id"), body, version)
+	if err != nil {
+		writeProductError(c, err)
+		return
+	}
+	setETag(c, p)
+	c.JSON(http.StatusOK, p)
+}
+
+func setETag(c *gin.Context, p *models.Product) {
+	c.Header("ETag", `"`+strconv.FormatInt(p.Version, 10)+`"`)
+}
+
+// ifMatchVersion reads the product version from If-Match. A missing header or
+// "*" yields 0, which is not a precondition on its own; ok is false when the
+// tag cannot match.
+func ifMatchVersion(c *gin.Context) (version int64, ok bool) {
+	tag := strings.TrimSpace(c.GetHeader("If-Match"))
+	if tag == "" || tag == "*" {
+		return 0, true
+	}
+	tag = strings.Trim(strings.TrimPrefix(tag, "W/"), `"`)
+	version, err := strconv.ParseInt(tag, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, false
+	}
+	return version, true
+}
+
+func writeProductError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidProduct), errors.Is(err, services.ErrInvalidVariant),
+		errors.Is(err, services.ErrInvalidThreshold), errors.Is(err, services.ErrTooManyImages):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrProductNotFound), errors.Is(err, services.ErrRevisionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrDuplicateSKU):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrVersionConflict):
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrVersionRequired):
+		c.JSON(http.StatusPreconditionRequired, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// DeleteProduct archives the product; see PurgeProduct for removal.
 func (h *ProductHandler) DeleteProduct(c *gin.Context) {
 	id := c.Param("id")
 	if id == "" {
@@ -165,3 +339,31 @@ func (h *ProductHandler) DeleteProduct(c *gin.Context) {
 	}
 	c.JSON(http.StatusNoContent, nil)
 }
+
+func (h *ProductHandler) RestoreProduct(c *gin.Context) {
+	p, err := h.productService.Restore(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, services.ErrProductNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+// PurgeProduct permanently deletes an archived product.
+func (h *ProductHandler) PurgeProduct(c *gin.Context) {
+	err := h.productService.Purge(c.Request.Context(), c.Param("id"))
+	switch {
+	case errors.Is(err, services.ErrProductNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrNotArchived):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.Status(http.StatusNoContent)
+	}
+}
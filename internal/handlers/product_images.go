@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/services"
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// maxUploadBytes bounds a whole multipart request carrying images.
+const maxUploadBytes = services.MaxProductImages*services.MaxImageBytes + 1<<20
+
+var errInvalidUpload = errors.New("invalid multipart upload")
+
+// UploadImages adds one or more "image" files to a product's gallery.
+func (h *ProductHandler) UploadImages(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxUploadBytes)
+	images, err := h.storeUploads(c)
+	if err != nil {
+		writeImageError(c, err)
+		return
+	}
+	if len(images) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "image is required"})
+		return
+	}
+	p, err := h.productService.AddImages(c.Request.Context(), c.Param("id"), images)
+	if err != nil {
+		h.releaseUploads(c, images)
+		writeImageError(c, err)
+		return
+	}
+	h.images.Finish(uploadKeys(images)...)
+	c.JSON(http.StatusCreated, p)
+}
+
+func (h *ProductHandler) RemoveImage(c *gin.Context) {
+	p, err := h.productService.RemoveImage(c.Request.Context(), c.Param("id"), c.Param("key"))
+	if err != nil {
+		writeImageError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+// ReorderImages takes {"images": [...]} with every gallery URL in the new
+// order.
+func (h *ProductHandler) ReorderImages(c *gin.Context) {
+	var req struct {
+		Images []string `json:"images" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	p, err := h.productService.ReorderImages(c.Request.Context(), c.Param("id"), req.Images)
+	if err != nil {
+		writeImageError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+// Media redirects to a short-lived signed URL for a product image held in a
+// private bucket.
+func (h *ProductHandler) Media(c *gin.Context) {
+	url, err := h.images.SignedURL(c.Request.Context(), strings.TrimPrefix(c.Param("key"), "/"))
+	if errors.Is(err, services.ErrImageNotFound) || errors.Is(err, storage.ErrInvalidKey) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Redirect(http.StatusFound, url)
+}
+
+// storeUploads runs every "image" file of a multipart request through the
+// image service. The original file names are never used. The caller ends the
+// uploads with Finish once attached, or releaseUploads.
+func (h *ProductHandler) storeUploads(c *gin.Context) ([]*models.ProductImage, error) {
+	if err := parseUploadForm(c); err != nil {
+		return nil, err
+	}
+	if c.Request.MultipartForm == nil {
+		return nil, nil
+	}
+	files := c.Request.MultipartForm.File["image"]
+	if len(files) > services.MaxProductImages {
+		return nil, services.ErrTooManyImages
+	}
+	var images []*models.ProductImage
+	for _, fh := range files {
+		if fh.Size == 0 {
+			continue
+		}
+		f, err := fh.Open()
+		if err != nil {
+			return nil, err
+		}
+		img, err := h.images.Store(c.Request.Context(), f)
+		f.Close()
+		if err != nil {
+			h.releaseUploads(c, images)
+			return nil, fmt.Errorf("%s: %w", fh.Filename, err)
+		}
+		images = append(images, img)
+	}
+	return images, nil
+}
+
+// parseUploadForm reads a multipart body up front so an oversized request is
+// reported as such rather than as missing form fields.
+func parseUploadForm(c *gin.Context) error {
+	_, err := c.MultipartForm()
+	var tooLarge *http.MaxBytesError
+	switch {
+	case err == nil, errors.Is(err, http.ErrNotMultipart):
+		return nil
+	case errors.As(err, &tooLarge):
+		return services.ErrImageTooLarge
+	default:
+		return errInvalidUpload
+	}
+}
+
+// releaseUploads drops files stored for a request that then failed.
+func (h *ProductHandler) releaseUploads(c *gin.Context, images []*models.ProductImage) {
+	h.images.Discard(c.Request.Context(), uploadKeys(images)...)
+}
+
+func uploadKeys(images []*models.ProductImage) []string {
+	keys := make([]string, 0, len(images))
+	for _, img := range images {
+		keys = append(keys, img.Key)
+	}
+	return keys
+}
+
+func writeImageError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrImageTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrUnsupportedImage):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTooManyImages), errors.Is(err, services.ErrInvalidImageList), errors.Is(err, errInvalidUpload):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrProductNotFound), errors.Is(err, services.ErrImageNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrVersionConflict):
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,389 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+const maxImportBytes = 10 << 20
+
+// importColumns are the columns of the flat product format, in export order.
+var importColumns = []string{
+	"id", "sku", "name", "description", "category", "gender", "price",
+	"sizes", "colors", "stock", "images", "low_stock_threshold", "is_active",
+}
+
+// ImportProducts upserts products from CSV or JSON Lines, sent as the request
+// body or as a multipart "file". With dry_run=true only validation runs.
+func (h *ProductHandler) ImportProducts(c *gin.Context) {
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+	body, format, err := importSource(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var rows []services.ProductImportRow
+	switch format {
+	case "csv":
+		rows, err = readCSVRows(body)
+	case "jsonl":
+		rows, err = readJSONLRows(body)
+	default:
+		err = fmt.Errorf("unsupported format %q, use csv or jsonl", format)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := h.productService.Import(c.Request.Context(), rows, dryRun)
+	switch {
+	case errors.Is(err, services.ErrImportTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "report": report})
+	case len(report.Errors) > 0:
+		c.JSON(http.StatusUnprocessableEntity, report)
+	default:
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// ExportProducts streams the catalog in the import format.
+func (h *ProductHandler) ExportProducts(c *gin.Context) {
+	format := strings.ToLower(c.DefaultQuery("format", "csv"))
+	if format != "csv" && format != "jsonl" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or jsonl"})
+		return
+	}
+	products, err := h.productService.List(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	sort.Slice(products, func(i, j int) bool { return products[i].SKU < products[j].SKU })
+
+	c.Header("Content-Disposition", "attachment; filename=products."+format)
+	if format == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		w := csv.NewWriter(c.Writer)
+		w.Write(importColumns)
+		for i, p := range products {
+			w.Write(productCSVRecord(p))
+			if i%100 == 99 {
+				w.Flush()
+				c.Writer.Flush()
+			}
+		}
+		w.Flush()
+		return
+	}
+	c.Header("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(c.Writer)
+	for i, p := range products {
+		enc.Encode(productJSONRecord(p))
+		if i%100 == 99 {
+			c.Writer.Flush()
+		}
+	}
+}
+
+func importSource(c *gin.Context) ([]byte, string, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes)
+	format := strings.ToLower(c.Query("format"))
+	var r io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		fh, err := c.FormFile("file")
+		if err != nil {
+			return nil, "", errors.New("file is required")
+		}
+		f, err := fh.Open()
+		if err != nil {
+			return nil, "", err
+		}
+		defer f.Close()
+		r = f
+		if format == "" {
+			format = strings.TrimPrefix(strings.ToLower(filepath.Ext(fh.Filename)), ".")
+		}
+	}
+	if format == "" {
+		switch c.ContentType() {
+		case "text/csv":
+			format = "csv"
+		case "application/x-ndjson", "application/jsonl", "application/json":
+			format = "jsonl"
+		}
+	}
+	if format == "ndjson" {
+		format = "jsonl"
+	}
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, "", fmt.Errorf("read import: %w", err)
+	}
+	return body, format, nil
+}
+
+func readCSVRows(body []byte) ([]services.ProductImportRow, error) {
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(body, []byte("\ufeff"))))
+	r.TrimLeadingSpace = true
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, errors.New("file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+		if !knownColumn(header[i]) {
+			return nil, fmt.Errorf("unknown column %q", header[i])
+		}
+	}
+	var rows []services.ProductImportRow
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := r.FieldPos(0)
+		cells := make(map[string]string, len(header))
+		for i, col := range header {
+			cells[col] = record[i]
+		}
+		rows = append(rows, importRowFromCells(line, cells))
+	}
+	return rows, nil
+}
+
+func readJSONLRows(body []byte) ([]services.ProductImportRow, error) {
+	var rows []services.ProductImportRow
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 64*1024), maxImportBytes)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(text, &obj); err != nil {
+			rows = append(rows, services.ProductImportRow{Line: line, ParseError: "invalid JSON object"})
+			continue
+		}
+		cells := make(map[string]string, len(obj))
+		row := services.ProductImportRow{Line: line}
+		for key, raw := range obj {
+			if !knownColumn(key) {
+				row.ParseError = fmt.Sprintf("unknown field %q", key)
+				break
+			}
+			value, err := jsonCell(raw)
+			if err != nil {
+				row.ParseError = fmt.Sprintf("%s: %v", key, err)
+				break
+			}
+			cells[key] = value
+		}
+		if row.ParseError != "" {
+			rows = append(rows, row)
+			continue
+		}
+		rows = append(rows, importRowFromCells(line, cells))
+	}
+	return rows, scanner.Err()
+}
+
+// jsonCell flattens a JSON value to the cell text a CSV file would hold:
+// arrays become comma separated lists and a stock object becomes size:qty
+// pairs.
+func jsonCell(raw json.RawMessage) (string, error) {
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return "", err
+	}
+	switch t := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return t, nil
+	case float64, bool:
+		return string(raw), nil
+	case []interface{}:
+		parts := make([]string, 0, len(t))
+		for _, item := range t {
+			s, ok := item.(string)
+			if !ok {
+				return "", errors.New("list items must be strings")
+			}
+			parts = append(parts, s)
+		}
+		return strings.Join(parts, ","), nil
+	case map[string]interface{}:
+		sizes := make([]string, 0, len(t))
+		for size := range t {
+			sizes = append(sizes, size)
+		}
+		sort.Strings(sizes)
+		parts := make([]string, 0, len(t))
+		for _, size := range sizes {
+			qty, ok := t[size].(float64)
+			if !ok {
+				return "", errors.New("stock quantities must be numbers")
+			}
+			parts = append(parts, size+":"+strconv.FormatFloat(qty, 'f', -1, 64))
+		}
+		return strings.Join(parts, ","), nil
+	}
+	return "", errors.New("unsupported value")
+}
+
+func importRowFromCells(line int, cells map[string]string) services.ProductImportRow {
+	row := services.ProductImportRow{
+		Line:        line,
+		ID:          cells["id"],
+		SKU:         cells["sku"],
+		Name:        cells["name"],
+		Description: cells["description"],
+		Category:    cells["category"],
+		Gender:      cells["gender"],
+	}
+	if v := strings.TrimSpace(cells["price"]); v != "" {
+		price, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			row.ParseError = fmt.Sprintf("price %q is not a number", v)
+			return row
+		}
+		row.Price = &price
+	}
+	if v := strings.TrimSpace(cells["sizes"]); v != "" {
+		row.Sizes = parseCommaString(v)
+	}
+	if v := strings.TrimSpace(cells["colors"]); v != "" {
+		row.Colors = parseCommaString(v)
+	}
+	if v := strings.TrimSpace(cells["stock"]); v != "" {
+		row.StockBySize = parseStockString(v)
+	}
+	if v := strings.TrimSpace(cells["images"]); v != "" {
+		row.Images = parseCommaString(v)
+	}
+	if v := strings.TrimSpace(cells["low_stock_threshold"]); v != "" {
+		threshold, err := strconv.Atoi(v)
+		if err != nil {
+			row.ParseError = fmt.Sprintf("low_stock_threshold %q is not a whole number", v)
+			return row
+		}
+		row.LowStockThreshold = &threshold
+	}
+	if v := strings.TrimSpace(cells["is_active"]); v != "" {
+		active, err := strconv.ParseBool(v)
+		if err != nil {
+			row.ParseError = fmt.Sprintf("is_active %q is not true or false", v)
+			return row
+		}
+		row.IsActive = &active
+	}
+	return row
+}
+
+func knownColumn(name string) bool {
+	for _, col := range importColumns {
+		if col == name {
+			return true
+		}
+	}
+	return false
+}
+
+func productCSVRecord(p *models.Product) []string {
+	threshold := ""
+	if p.LowStockThreshold > 0 {
+		threshold = strconv.Itoa(p.LowStockThreshold)
+	}
+	return []string{
+		p.ID,
+		p.SKU,
+		p.Name,
+		p.Description,
+		p.Category,
+		p.Gender,
+		strconv.FormatFloat(p.Price, 'f', -1, 64),
+		strings.Join(p.Sizes, ","),
+		strings.Join(p.Colors, ","),
+		stockCell(p),
+		strings.Join(p.Images, ","),
+		threshold,
+		strconv.FormatBool(p.IsActive),
+	}
+}
+
+func productJSONRecord(p *models.Product) gin.H {
+	record := gin.H{
+		"id":          p.ID,
+		"sku":         p.SKU,
+		"name":        p.Name,
+		"description": p.Description,
+		"category":    p.Category,
+		"gender":      p.Gender,
+		"price":       p.Price,
+		"sizes":       nonNil(p.Sizes),
+		"colors":      nonNil(p.Colors),
+		"stock":       p.StockBySize,
+		"images":      nonNil(p.Images),
+		"is_active":   p.IsActive,
+	}
+	if p.LowStockThreshold > 0 {
+		record["low_stock_threshold"] = p.LowStockThreshold
+	}
+	return record
+}
+
+// stockCell writes size:qty pairs in the product's size order.
+func stockCell(p *models.Product) string {
+	parts := make([]string, 0, len(p.StockBySize))
+	done := make(map[string]bool)
+	for _, size := range p.Sizes {
+		if qty, ok := p.StockBySize[size]; ok {
+			parts = append(parts, size+":"+strconv.Itoa(qty))
+			done[size] = true
+		}
+	}
+	var rest []string
+	for size := range p.StockBySize {
+		if !done[size] {
+			rest = append(rest, size)
+		}
+	}
+	sort.Strings(rest)
+	for _, size := range rest {
+		parts = append(parts, size+":"+strconv.Itoa(p.StockBySize[size]))
+	}
+	return strings.Join(parts, ",")
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
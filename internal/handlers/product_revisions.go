@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *ProductHandler) ListRevisions(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	revs, err := h.productService.Revisions(c.Request.Context(), c.Param("id"), limit)
+	if err != nil {
+		writeProductError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"product_id": c.Param("id"), "revisions": revs})
+}
+
+// DiffRevisions compares two versions given as ?from=&to=.
+func (h *ProductHandler) DiffRevisions(c *gin.Context) {
+	from, errFrom := strconv.ParseInt(c.Query("from"), 10, 64)
+	to, errTo := strconv.ParseInt(c.Query("to"), 10, 64)
+	if errFrom != nil || errTo != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from and to must be revision versions"})
+		return
+	}
+	changes, err := h.productService.DiffRevisions(c.Request.Context(), c.Param("id"), from, to)
+	if err != nil {
+		writeProductError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"from": from, "to": to, "changes": changes})
+}
+
+// RollbackRevision restores the product as it was at :version, saved as a
+// new revision.
+func (h *ProductHandler) RollbackRevision(c *gin.Context) {
+	version, err := strconv.ParseInt(c.Param("version"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid version"})
+		return
+	}
+	p, missing, err := h.productService.Rollback(c.Request.Context(), c.Param("id"), version)
+	if err != nil {
+		writeProductError(c, err)
+		return
+	}
+	if len(missing) > 0 {
+		c.Header("X-Missing-Images", strings.Join(missing, ","))
+	}
+	setETag(c, p)
+	c.JSON(http.StatusOK, p)
+}
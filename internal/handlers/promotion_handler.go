@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type PromotionHandler struct {
+	promotions *services.PromotionService
+}
+
+func NewPromotionHandler(promotions *services.PromotionService) *PromotionHandler {
+	return &PromotionHandler{promotions: promotions}
+}
+
+func (h *PromotionHandler) List(c *gin.Context) {
+	list, err := h.promotions.List(c.Request.Context())
+	if err != nil {
+		writePromotionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"promotions": list})
+}
+
+func (h *PromotionHandler) Get(c *gin.Context) {
+	p, err := h.promotions.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		writePromotionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+func (h *PromotionHandler) Create(c *gin.Context) {
+	var req models.Promotion
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	p, err := h.promotions.Create(c.Request.Context(), &req)
+	if err != nil {
+		writePromotionError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, p)
+}
+
+func (h *PromotionHandler) Update(c *gin.Context) {
+	var req models.Promotion
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	p, err := h.promotions.Update(c.Request.Context(), c.Param("id"), &req)
+	if err != nil {
+		writePromotionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+func (h *PromotionHandler) Delete(c *gin.Context) {
+	if err := h.promotions.Delete(c.Request.Context(), c.Param("id")); err != nil {
+		writePromotionError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func writePromotionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidPromotion):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPromotionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrDuplicatePromoCode):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
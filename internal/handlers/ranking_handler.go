@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type RankingHandler struct {
+	ranking *services.RankingService
+}
+
+func NewRankingHandler(ranking *services.RankingService) *RankingHandler {
+	return &RankingHandler{ranking: ranking}
+}
+
+// Weights returns the weights behind the shop's recommended sort.
+func (h *RankingHandler) Weights(c *gin.Context) {
+	w, err := h.ranking.Weights(c.Request.Context())
+	if err != nil {
+		writeRankingError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, w)
+}
+
+func (h *RankingHandler) SetWeights(c *gin.Context) {
+	var req models.RankingWeights
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	w, err := h.ranking.SetWeights(c.Request.Context(), &req, getStr(c, "user_id"))
+	if err != nil {
+		writeRankingError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, w)
+}
+
+func writeRankingError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidRanking):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
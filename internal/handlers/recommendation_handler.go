@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type RecommendationHandler struct {
+	recommendations *services.RecommendationService
+}
+
+func NewRecommendationHandler(recommendations *services.RecommendationService) *RecommendationHandler {
+	return &RecommendationHandler{recommendations: recommendations}
+}
+
+// ForProduct returns the products to show next to one product.
+func (h *RecommendationHandler) ForProduct(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	recs, err := h.recommendations.ForProduct(c.Request.Context(), c.Param("id"), limit)
+	if err != nil {
+		writeRecommendationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recommendations": recs})
+}
+
+// ForProducts returns the products to suggest alongside every
+// ?product_id=, as on the cart page.
+func (h *RecommendationHandler) ForProducts(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	recs, err := h.recommendations.ForProducts(c.Request.Context(), nonEmpty(c.QueryArray("product_id")), limit)
+	if err != nil {
+		writeRecommendationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recommendations": recs})
+}
+
+func writeRecommendationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrProductNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
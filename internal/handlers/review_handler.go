@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type ReviewHandler struct {
+	reviews  *services.ReviewService
+	products *services.ProductService
+}
+
+func NewReviewHandler(reviews *services.ReviewService, products *services.ProductService) *ReviewHandler {
+	return &ReviewHandler{reviews: reviews, products: products}
+}
+
+// List returns a product's rating and its approved reviews.
+func (h *ReviewHandler) List(c *gin.Context) {
+	ctx := c.Request.Context()
+	p, err := h.products.GetByID(ctx, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if p == nil || p.Archived() {
+		c.JSON(http.StatusNotFound, gin.H{"error": services.ErrProductNotFound.Error()})
+		return
+	}
+	reviews, err := h.reviews.Approved(ctx, p.ID)
+	if err != nil {
+		writeReviewError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"product_id": p.ID, "rating": p.Rating, "review_count": p.ReviewCount, "reviews": reviews})
+}
+
+func (h *ReviewHandler) Submit(c *gin.Context) {
+	var req models.CreateReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	review, err := h.reviews.Submit(c.Request.Context(), getStr(c, "user_id"), getStr(c, "user_name"), c.Param("id"), &req)
+	if err != nil {
+		writeReviewError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, review)
+}
+
+// Queue lists reviews awaiting moderation, or those in ?status=.
+func (h *ReviewHandler) Queue(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	reviews, err := h.reviews.Queue(c.Request.Context(), c.Query("status"), limit)
+	if err != nil {
+		writeReviewError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"reviews": reviews})
+}
+
+func (h *ReviewHandler) Moderate(c *gin.Context) {
+	var req models.ModerateReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	review, err := h.reviews.Moderate(c.Request.Context(), c.Param("id"), req.Status, req.Note, getStr(c, "user_id"))
+	if err != nil {
+		writeReviewError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, review)
+}
+
+func (h *ReviewHandler) Delete(c *gin.Context) {
+	if err := h.reviews.Delete(c.Request.Context(), c.Param("id")); err != nil {
+		writeReviewError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func writeReviewError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidReview):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrNotPurchased):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrReviewNotFound), errors.Is(err, services.ErrProductNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
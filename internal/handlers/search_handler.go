@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultSearchLimit  = 20
+	maxSearchLimit      = 50
+	defaultSuggestLimit = 5
+	maxSuggestLimit     = 10
+	maxSuggestPrefix    = 64
+)
+
+type SearchHandler struct {
+	productService *services.ProductService
+}
+
+func NewSearchHandler(svc *services.ProductService) *SearchHandler {
+	return &SearchHandler{productService: svc}
+}
+
+func (h *SearchHandler) Search(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+	results, err := h.productService.SearchText(c.Request.Context(), q, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"query": q, "results": results})
+}
+
+func (h *SearchHandler) Suggest(c *gin.Context) {
+	q := c.Query("q")
+	if len(q) > maxSuggestPrefix {
+		q = q[:maxSuggestPrefix]
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 {
+		limit = defaultSuggestLimit
+	}
+	if limit > maxSuggestLimit {
+		limit = maxSuggestLimit
+	}
+	c.Header("Cache-Control", "public, max-age=30")
+	c.JSON(http.StatusOK, h.productService.Suggest(q, limit))
+}
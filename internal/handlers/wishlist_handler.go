@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type WishlistHandler struct {
+	wishlists *services.WishlistService
+}
+
+func NewWishlistHandler(wishlists *services.WishlistService) *WishlistHandler {
+	return &WishlistHandler{wishlists: wishlists}
+}
+
+func (h *WishlistHandler) List(c *gin.Context) {
+	entries, err := h.wishlists.List(c.Request.Context(), getStr(c, "user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": entries})
+}
+
+func (h *WishlistHandler) AddItem(c *gin.Context) {
+	var body struct {
+		ProductID string `json:"product_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.wishlists.Add(c.Request.Context(), getStr(c, "user_id"), body.ProductID); err != nil {
+		if errors.Is(err, services.ErrProductNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.List(c)
+}
+
+func (h *WishlistHandler) RemoveItem(c *gin.Context) {
+	if err := h.wishlists.Remove(c.Request.Context(), getStr(c, "user_id"), c.Param("productId")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.List(c)
+}
+
+func (h *WishlistHandler) Notifications(c *gin.Context) {
+	unreadOnly := c.Query("unread") == "true"
+	notifications, err := h.wishlists.Notifications(c.Request.Context(), getStr(c, "user_id"), unreadOnly)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"notifications": notifications})
+}
+
+func (h *WishlistHandler) MarkNotificationsRead(c *gin.Context) {
+	if err := h.wishlists.MarkNotificationsRead(c.Request.Context(), getStr(c, "user_id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "notifications marked as read"})
+}
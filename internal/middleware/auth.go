@@ -34,6 +34,7 @@ func Auth(authSvc *services.AuthService) gin.HandlerFunc {
 		c.Set("user_role", user["role"])
 		c.Set("user_email", user["email"])
 		c.Set("user_name", user["name"])
+		c.Request = c.Request.WithContext(services.WithActor(c.Request.Context(), user["id"]))
 		c.Next()
 	}
 }
@@ -55,3 +56,22 @@ func RequireAdmin(c *gin.Context) {
 	}
 	c.Next()
 }
+
+// RequireAuthJSON is RequireAuth for API routes: it answers 401 JSON instead
+// of redirecting to the login page.
+func RequireAuthJSON(c *gin.Context) {
+	if _, ok := c.Get("user_id"); !ok {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+	c.Next()
+}
+
+func RequireAdminJSON(c *gin.Context) {
+	role, _ := c.Get("user_role")
+	if role != "admin" {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin role required"})
+		return
+	}
+	c.Next()
+}
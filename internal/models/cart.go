@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+type Cart struct {
+	ID        string     `json:"id" bson:"_id,omitempty"`
+	UserID    string     `json:"user_id,omitempty" bson:"userId,omitempty"`
+	SessionID string     `json:"-" bson:"sessionId,omitempty"`
+	Items     []CartItem `json:"items" bson:"items"`
+	CreatedAt time.Time  `json:"created_at" bson:"createdAt"`
+	UpdatedAt time.Time  `json:"updated_at" bson:"updatedAt"`
+}
+
+type CartItem struct {
+	ID            string    `json:"id" bson:"id"`
+	ProductID     string    `json:"product_id" bson:"productId"`
+	VariantID     string    `json:"variant_id" bson:"variantId"`
+	SelectedSize  string    `json:"selected_size" bson:"selectedSize"`
+	SelectedColor string    `json:"selected_color" bson:"selectedColor"`
+	Quantity      int       `json:"quantity" bson:"quantity"`
+	AddedAt       time.Time `json:"added_at" bson:"addedAt"`
+}
+
+// CartView is a cart priced against the current catalog.
+type CartView struct {
+	Items    []CartLine `json:"items"`
+	Count    int        `json:"count"`
+	Subtotal float64    `json:"subtotal"`
+}
+
+type CartLine struct {
+	ID            string  `json:"id"`
+	ProductID     string  `json:"product_id"`
+	ProductName   string  `json:"product_name"`
+	Image         string  `json:"image"`
+	VariantID     string  `json:"variant_id"`
+	SKU           string  `json:"sku"`
+	SelectedSize  string  `json:"selected_size"`
+	SelectedColor string  `json:"selected_color"`
+	Quantity      int     `json:"quantity"`
+	UnitPrice     float64 `json:"unit_price"`
+	LineTotal     float64 `json:"line_total"`
+	Available     bool    `json:"available"`
+	Issue         string  `json:"issue,omitempty"`
+}
+
+type AddCartItemRequest struct {
+	ProductID     string `json:"product_id" binding:"required"`
+	VariantID     string `json:"variant_id"`
+	SelectedSize  string `json:"selected_size"`
+	SelectedColor string `json:"selected_color"`
+	Quantity      int    `json:"quantity"`
+}
+
+type CheckoutRequest struct {
+	PaymentMethod   string `json:"payment_method"`
+	DeliveryMethod  string `json:"delivery_method"`
+	DeliveryAddress string `json:"delivery_address"`
+	Comment         string `json:"comment"`
+	PromoCode       string `json:"promo_code"`
+}
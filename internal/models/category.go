@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Category is a node of the shop taxonomy. Products reference it by ID and
+// keep its name as Product.Category.
+type Category struct {
+	ID   string `json:"id" bson:"_id,omitempty"`
+	Name string `json:"name" bson:"name"`
+	// Slug names the landing page, /shop/c/<slug>. It is unique.
+	Slug string `json:"slug" bson:"slug"`
+	// ParentID is empty for top-level categories.
+	ParentID string `json:"parent_id,omitempty" bson:"parentId,omitempty"`
+	// SortOrder orders siblings, lowest first; ties sort by name.
+	SortOrder int `json:"sort_order" bson:"sortOrder"`
+	// Description introduces the landing page; MetaTitle and
+	// MetaDescription fill its <title> and meta description.
+	Description     string    `json:"description,omitempty" bson:"description,omitempty"`
+	MetaTitle       string    `json:"meta_title,omitempty" bson:"metaTitle,omitempty"`
+	MetaDescription string    `json:"meta_description,omitempty" bson:"metaDescription,omitempty"`
+	CreatedAt       time.Time `json:"created_at" bson:"createdAt"`
+	UpdatedAt       time.Time `json:"updated_at" bson:"updatedAt"`
+}
+
+// CategoryNode is a category with its children, as shown in the shop.
+// ProductCount includes the products of every descendant.
+type CategoryNode struct {
+	*Category
+	ProductCount int             `json:"product_count"`
+	Children     []*CategoryNode `json:"children"`
+}
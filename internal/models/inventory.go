@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+const (
+	MovementReceipt     = "receipt"
+	MovementSale        = "sale"
+	MovementReturn      = "return"
+	MovementAdjustment  = "adjustment"
+	MovementReservation = "reservation"
+	MovementRelease     = "release"
+)
+
+// StockMovement is one entry of the append-only inventory ledger. Quantity
+// is signed: negative movements take units off the shelf. Balance is the
+// variant's stock right after the movement was applied.
+type StockMovement struct {
+	ID        string    `json:"id" bson:"_id,omitempty"`
+	ProductID string    `json:"product_id" bson:"productId"`
+	VariantID string    `json:"variant_id" bson:"variantId"`
+	SKU       string    `json:"sku" bson:"sku"`
+	Size      string    `json:"size" bson:"size"`
+	Color     string    `json:"color" bson:"color"`
+	Type      string    `json:"type" bson:"type"`
+	Quantity  int       `json:"quantity" bson:"quantity"`
+	Balance   int       `json:"balance" bson:"balance"`
+	Reason    string    `json:"reason,omitempty" bson:"reason,omitempty"`
+	OrderID   string    `json:"order_id,omitempty" bson:"orderId,omitempty"`
+	Actor     string    `json:"actor,omitempty" bson:"actor,omitempty"`
+	CreatedAt time.Time `json:"created_at" bson:"createdAt"`
+}
+
+// StockAdjustmentRequest is a manual ledger entry posted by an admin.
+// Quantity is signed for adjustments and a positive unit count otherwise.
+type StockAdjustmentRequest struct {
+	VariantID string `json:"variant_id" binding:"required"`
+	Type      string `json:"type"`
+	Quantity  int    `json:"quantity"`
+	Reason    string `json:"reason"`
+}
+
+// InventoryLevel compares a variant's cached stock with the sum of its
+// ledger entries; the two differ only if stock was changed off the books.
+type InventoryLevel struct {
+	VariantID string `json:"variant_id"`
+	SKU       string `json:"sku"`
+	Size      string `json:"size"`
+	Color     string `json:"color"`
+	Stock     int    `json:"stock"`
+	Ledger    int    `json:"ledger"`
+}
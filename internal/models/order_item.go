@@ -5,6 +5,8 @@ type OrderItem struct {
 	OrderID       string  `json:"order_id" bson:"orderId"`
 	ProductID     string  `json:"product_id" bson:"productId"`
 	ProductName   string  `json:"product_name" bson:"productName"`
+	VariantID     string  `json:"variant_id" bson:"variantId"`
+	SKU           string  `json:"sku" bson:"sku"`
 	SelectedSize  string  `json:"selected_size" bson:"selectedSize"`
 	SelectedColor string  `json:"selected_color" bson:"selectedColor"`
 	Quantity      int     `json:"quantity" bson:"quantity"`
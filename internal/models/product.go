@@ -3,29 +3,184 @@ package models
 import "time"
 
 type Product struct {
-	ID          string         `json:"id" bson:"_id,omitempty"`
-	Name        string         `json:"name" bson:"name"`
-	Description string         `json:"description" bson:"description"`
-	Category    string         `json:"category" bson:"category"`
-	Gender      string         `json:"gender" bson:"gender"`
-	Price       float64        `json:"price" bson:"price"`
-	Sizes       []string       `json:"sizes" bson:"sizes"`
-	Colors      []string       `json:"colors" bson:"colors"`
-	StockBySize map[string]int `json:"stock_by_size" bson:"stockBySize"`
-	Images      []string       `json:"images" bson:"images"`
-	IsActive    bool           `json:"is_active" bson:"isActive"`
-	CreatedAt   time.Time      `json:"created_at" bson:"createdAt"`
-	UpdatedAt   time.Time      `json:"updated_at" bson:"updateAt"`
+	ID string `json:"id" bson:"_id,omitempty"`
+	// SKU is the merchant's style code, the key bulk imports match on.
+	// Variant SKUs are derived from it when set.
+	SKU         string `json:"sku,omitempty" bson:"sku,omitempty"`
+	Name        string `json:"name" bson:"name"`
+	Description string `json:"description" bson:"description"`
+	// Category is the name of the category CategoryID points to, kept for
+	// display and search.
+	Category   string  `json:"category" bson:"category"`
+	CategoryID string  `json:"category_id,omitempty" bson:"categoryId,omitempty"`
+	Gender     string  `json:"gender" bson:"gender"`
+	Price      float64 `json:"price" bson:"price"`
+	// SalePrice replaces Price from SaleStartsAt until SaleEndsAt; an unset
+	// bound leaves that side open. Variants with their own price are
+	// discounted in proportion.
+	SalePrice    *float64   `json:"sale_price,omitempty" bson:"salePrice,omitempty"`
+	SaleStartsAt *time.Time `json:"sale_starts_at,omitempty" bson:"saleStartsAt,omitempty"`
+	SaleEndsAt   *time.Time `json:"sale_ends_at,omitempty" bson:"saleEndsAt,omitempty"`
+	// CompareAtPrice is a reference price shown struck through while it is
+	// above what the product sells for.
+	CompareAtPrice *float64 `json:"compare_at_price,omitempty" bson:"compareAtPrice,omitempty"`
+	// CurrentPrice is what the product sold for at its last write or sale
+	// scheduler check, sale included. Price filters and sorting use it.
+	CurrentPrice float64        `json:"-" bson:"currentPrice,omitempty"`
+	Sizes        []string       `json:"sizes" bson:"sizes"`
+	Colors       []string       `json:"colors" bson:"colors"`
+	StockBySize  map[string]int `json:"stock_by_size" bson:"stockBySize"`
+	// Images is the ordered gallery. Uploaded images appear here by their
+	// large rendition URL and are described in Media.
+	Images []string       `json:"images" bson:"images"`
+	Media  []ProductImage `json:"media,omitempty" bson:"media,omitempty"`
+	// Variants are the sellable SKUs. Sizes, Colors and StockBySize are
+	// derived from them.
+	Variants []ProductVariant `json:"variants" bson:"variants"`
+	// LowStockThreshold is the per-size level at or below which stock is
+	// reported as low; 0 uses the store-wide default.
+	LowStockThreshold int       `json:"low_stock_threshold,omitempty" bson:"lowStockThreshold,omitempty"`
+	IsActive          bool      `json:"is_active" bson:"isActive"`
+	CreatedAt         time.Time `json:"created_at" bson:"createdAt"`
+	UpdatedAt         time.Time `json:"updated_at" bson:"updateAt"`
+	// Version increases with every write and backs the ETag; updates that
+	// carry a stale version are rejected.
+	Version int64 `json:"version" bson:"version"`
+	// DeletedAt is set while the product is archived: hidden from the shop
+	// but still resolvable for orders and analytics.
+	DeletedAt *time.Time `json:"deleted_at,omitempty" bson:"deletedAt,omitempty"`
+	// Rating averages the approved reviews; both fields are kept up to date
+	// by the review service and cannot be edited.
+	Rating      float64 `json:"rating" bson:"rating,omitempty"`
+	ReviewCount int     `json:"review_count" bson:"reviewCount,omitempty"`
+	// RankScore is the product's place in the recommended sort before the
+	// shopper's affinity is added; the ranking service keeps it up to date.
+	RankScore float64 `json:"-" bson:"rankScore,omitempty"`
+	// Pricing is resolved when the product is read; it is never stored.
+	Pricing *ProductPricing `json:"pricing,omitempty" bson:"-"`
+}
+
+func (p *Product) Archived() bool {
+	return p.DeletedAt != nil
+}
+
+// SellingPrice is the resolved price when known and the list price
+// otherwise.
+func (p *Product) SellingPrice() float64 {
+	if p.Pricing != nil {
+		return p.Pricing.Price
+	}
+	return p.Price
+}
+
+// ProductPricing is what a product sells for at one point in time.
+type ProductPricing struct {
+	Price float64 `json:"price"`
+	// CompareAtPrice is the struck-through price, zero when there is none.
+	CompareAtPrice float64    `json:"compare_at_price,omitempty"`
+	OnSale         bool       `json:"on_sale"`
+	SaleEndsAt     *time.Time `json:"sale_ends_at,omitempty"`
+	// Variants holds the price of every variant by ID.
+	Variants map[string]float64 `json:"variants,omitempty"`
+}
+
+// ProductImage is an uploaded image stored under its content hash, with one
+// URL per rendition.
+type ProductImage struct {
+	Key         string `json:"key" bson:"key"`
+	ContentType string `json:"content_type" bson:"contentType"`
+	Width       int    `json:"width" bson:"width"`
+	Height      int    `json:"height" bson:"height"`
+	Thumbnail   string `json:"thumbnail" bson:"thumbnail"`
+	Medium      string `json:"medium" bson:"medium"`
+	Large       string `json:"large" bson:"large"`
+}
+
+type ProductVariant struct {
+	ID    string `json:"id" bson:"id"`
+	SKU   string `json:"sku" bson:"sku"`
+	Size  string `json:"size" bson:"size"`
+	Color string `json:"color" bson:"color"`
+	// Price overrides the product price when set.
+	Price   *float64 `json:"price,omitempty" bson:"price,omitempty"`
+	Stock   int      `json:"stock" bson:"stock"`
+	Barcode string   `json:"barcode,omitempty" bson:"barcode,omitempty"`
+	Images  []string `json:"images,omitempty" bson:"images,omitempty"`
 }
 
 type CreateProductRequest struct {
-	Name        string         `json:"name" binding:"required"`
-	Description string         `json:"description"`
-	Category    string         `json:"category"`
-	Gender      string         `json:"gender"`
-	Price       float64        `json:"price" binding:"required"`
-	Sizes       []string       `json:"sizes"`
-	Colors      []string       `json:"colors"`
-	StockBySize map[string]int `json:"stock_by_size"`
-	Images      []string       `json:"images"`
+	SKU         string `json:"sku"`
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	// Category is matched by name or slug when CategoryID is empty.
+	Category       string         `json:"category"`
+	CategoryID     string         `json:"category_id"`
+	Gender         string         `json:"gender"`
+	Price          float64        `json:"price" binding:"required"`
+	SalePrice      *float64       `json:"sale_price"`
+	SaleStartsAt   *time.Time     `json:"sale_starts_at"`
+	SaleEndsAt     *time.Time     `json:"sale_ends_at"`
+	CompareAtPrice *float64       `json:"compare_at_price"`
+	Sizes          []string       `json:"sizes"`
+	Colors         []string       `json:"colors"`
+	StockBySize    map[string]int `json:"stock_by_size"`
+	Images         []string       `json:"images"`
+	// Media describes uploaded images; it is set by the upload handler.
+	Media []ProductImage `json:"-"`
+	// Variants, when given, replace Sizes, Colors and StockBySize.
+	Variants          []ProductVariant `json:"variants"`
+	LowStockThreshold int              `json:"low_stock_threshold"`
+}
+
+// ProductQuery describes a catalog listing. Empty fields do not filter.
+// Genders may include "universal" for products without a gender.
+type ProductQuery struct {
+	Text        string
+	Categories  []string
+	Genders     []string
+	Colors      []string
+	Sizes       []string
+	MinPrice    float64
+	MaxPrice    float64
+	InStockOnly bool
+	Sort        string
+	Cursor      string
+	Limit       int
+	// IDs restricts results to these products; set by full-text search.
+	IDs []string
+	// CategoryIDs restricts results to these categories; set by category
+	// landing pages.
+	CategoryIDs []string
+	// UserID personalizes the recommended sort; empty for guests.
+	UserID string
+	// Affinity adds to the rank score of the products the shopper favours
+	// in the recommended sort; set by the ranking service.
+	Affinity *RankAffinity
+}
+
+type ProductPage struct {
+	Items      []*Product `json:"items"`
+	Total      int64      `json:"total"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+	// Snippets holds highlighted HTML excerpts by product ID for text queries.
+	Snippets map[string]string `json:"snippets,omitempty"`
+}
+
+type SearchResult struct {
+	Product *Product `json:"product"`
+	Score   float64  `json:"score"`
+	Name    string   `json:"name_html"`
+	Snippet string   `json:"snippet_html"`
+}
+
+type FacetCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+type ProductFacets struct {
+	Categories []FacetCount `json:"categories"`
+	Colors     []FacetCount `json:"colors"`
+	// CategoryIDs counts products by assigned category.
+	CategoryIDs map[string]int `json:"category_ids"`
 }
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	RevisionCreate   = "create"
+	RevisionUpdate   = "update"
+	RevisionArchive  = "archive"
+	RevisionRestore  = "restore"
+	RevisionRollback = "rollback"
+)
+
+// ProductRevision is a snapshot of a product taken after a write through the
+// product service. Version is the product version it captured, so revisions
+// of one product are ordered but not contiguous: stock changes from orders
+// bump the version without a revision.
+type ProductRevision struct {
+	ID        string `json:"id" bson:"_id,omitempty"`
+	ProductID string `json:"product_id" bson:"productId"`
+	Version   int64  `json:"version" bson:"version"`
+	Action    string `json:"action" bson:"action"`
+	// Author is the ID of the user who made the change, empty for changes
+	// made by the system.
+	Author string `json:"author,omitempty" bson:"author,omitempty"`
+	// RollbackOf is the version a rollback restored.
+	RollbackOf int64 `json:"rollback_of,omitempty" bson:"rollbackOf,omitempty"`
+	// MissingImages are the keys of uploaded images a rollback could not
+	// restore because their files had been deleted.
+	MissingImages []string `json:"missing_images,omitempty" bson:"missingImages,omitempty"`
+	// Changes is the diff against the product as stored before the write.
+	Changes   []FieldChange `json:"changes" bson:"changes"`
+	Snapshot  Product       `json:"snapshot" bson:"snapshot"`
+	CreatedAt time.Time     `json:"created_at" bson:"createdAt"`
+}
+
+// FieldChange is one field that differs between two product states. Field
+// is a dotted JSON path such as "price" or "stock_by_size.M"; From and To
+// are JSON values, null when the field is absent on that side.
+type FieldChange struct {
+	Field string          `json:"field" bson:"field"`
+	From  json.RawMessage `json:"from" bson:"from"`
+	To    json.RawMessage `json:"to" bson:"to"`
+}
@@ -0,0 +1,68 @@
+package models
+
+import "time"
+
+const (
+	PromotionPercent      = "percent"
+	PromotionFixed        = "fixed"
+	PromotionFreeShipping = "free_shipping"
+	PromotionBuyXGetY     = "buy_x_get_y"
+)
+
+// Promotion is a discount customers unlock by entering its code at
+// checkout.
+type Promotion struct {
+	ID string `json:"id" bson:"_id,omitempty"`
+	// Code is matched case-insensitively and stored in upper case.
+	Code        string `json:"code" bson:"code"`
+	Description string `json:"description,omitempty" bson:"description,omitempty"`
+	Type        string `json:"type" bson:"type"`
+	// Value is the percentage off for percent promotions and the amount off
+	// for fixed ones.
+	Value float64 `json:"value,omitempty" bson:"value,omitempty"`
+	// For every BuyQuantity eligible units, GetQuantity more are free; the
+	// cheapest units are the free ones.
+	BuyQuantity int `json:"buy_quantity,omitempty" bson:"buyQuantity,omitempty"`
+	GetQuantity int `json:"get_quantity,omitempty" bson:"getQuantity,omitempty"`
+	// MinSubtotal is the order subtotal needed to use the code.
+	MinSubtotal float64 `json:"min_subtotal,omitempty" bson:"minSubtotal,omitempty"`
+	// CategoryIDs and ProductIDs limit the discount to matching items,
+	// subcategories included. Both empty means every item.
+	CategoryIDs []string `json:"category_ids,omitempty" bson:"categoryIds,omitempty"`
+	ProductIDs  []string `json:"product_ids,omitempty" bson:"productIds,omitempty"`
+	// UsageLimit caps orders across all customers and PerUserLimit orders
+	// per customer; 0 means unlimited.
+	UsageLimit   int `json:"usage_limit,omitempty" bson:"usageLimit,omitempty"`
+	PerUserLimit int `json:"per_user_limit,omitempty" bson:"perUserLimit,omitempty"`
+	// UsedCount is the number of orders that used the code and were not
+	// cancelled.
+	UsedCount int        `json:"used_count" bson:"usedCount"`
+	StartsAt  *time.Time `json:"starts_at,omitempty" bson:"startsAt,omitempty"`
+	EndsAt    *time.Time `json:"ends_at,omitempty" bson:"endsAt,omitempty"`
+	Active    bool       `json:"active" bson:"active"`
+	CreatedAt time.Time  `json:"created_at" bson:"createdAt"`
+	UpdatedAt time.Time  `json:"updated_at" bson:"updatedAt"`
+}
+
+// OrderDiscount is a promotion applied to an order.
+type OrderDiscount struct {
+	PromotionID string  `json:"promotion_id" bson:"promotionId"`
+	Code        string  `json:"code" bson:"code"`
+	Type        string  `json:"type" bson:"type"`
+	Description string  `json:"description,omitempty" bson:"description,omitempty"`
+	Amount      float64 `json:"amount" bson:"amount"`
+}
+
+type ApplyCodeRequest struct {
+	Code           string `json:"code" binding:"required"`
+	DeliveryMethod string `json:"delivery_method"`
+}
+
+// OrderQuote is what an order would cost, as previewed before checkout.
+type OrderQuote struct {
+	Subtotal      float64         `json:"subtotal"`
+	DeliveryFee   float64         `json:"delivery_fee"`
+	Discounts     []OrderDiscount `json:"discounts"`
+	DiscountTotal float64         `json:"discount_total"`
+	Total         float64         `json:"total"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// RankingWeights sets how much each factor counts towards the shop's
+// "recommended" order. Only their ratios matter.
+type RankingWeights struct {
+	// Popularity favours products that sold many units recently.
+	Popularity float64 `json:"popularity" bson:"popularity"`
+	// Recency favours newly added products.
+	Recency float64 `json:"recency" bson:"recency"`
+	// Stock favours products available in more of their sizes.
+	Stock float64 `json:"stock" bson:"stock"`
+	// Affinity favours the category and gender a customer buys most.
+	Affinity  float64    `json:"affinity" bson:"affinity"`
+	UpdatedBy string     `json:"updated_by,omitempty" bson:"updatedBy,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty" bson:"updatedAt,omitempty"`
+}
+
+// RankAffinity is a shopper's favourite category and gender. Products in
+// either have Boost added to their rank score for each one they match.
+// Category is a category ID, or a lower-case name for products not assigned
+// to one; Gender is lower case.
+type RankAffinity struct {
+	Category string
+	Gender   string
+	Boost    float64
+}
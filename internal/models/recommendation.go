@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+const (
+	RecommendBoughtTogether = "bought_together"
+	RecommendSimilar        = "similar"
+)
+
+// ProductRecommendations is the co-purchase list of one product, computed
+// in batch from past orders.
+type ProductRecommendations struct {
+	ProductID      string       `json:"product_id" bson:"_id"`
+	BoughtTogether []CoPurchase `json:"bought_together" bson:"boughtTogether"`
+	ComputedAt     time.Time    `json:"computed_at" bson:"computedAt"`
+}
+
+// CoPurchase counts the orders that contained both products.
+type CoPurchase struct {
+	ProductID string `json:"product_id" bson:"productId"`
+	Orders    int    `json:"orders" bson:"orders"`
+}
+
+// Recommendation is a product suggested next to another one. Reason is
+// RecommendBoughtTogether or RecommendSimilar.
+type Recommendation struct {
+	Product *Product `json:"product"`
+	Reason  string   `json:"reason"`
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	ReviewPending  = "pending"
+	ReviewApproved = "approved"
+	ReviewRejected = "rejected"
+)
+
+// Review is a customer's rating of a product they bought. It is shown in
+// the shop once a moderator approves it.
+type Review struct {
+	ID        string `json:"id" bson:"_id,omitempty"`
+	ProductID string `json:"product_id" bson:"productId"`
+	UserID    string `json:"user_id" bson:"userId"`
+	// UserName is the author's name when the review was written.
+	UserName string `json:"user_name" bson:"userName"`
+	// OrderID is the order that verified the purchase.
+	OrderID string `json:"order_id" bson:"orderId"`
+	Rating  int    `json:"rating" bson:"rating"`
+	Title   string `json:"title,omitempty" bson:"title,omitempty"`
+	Body    string `json:"body" bson:"body"`
+	Status  string `json:"status" bson:"status"`
+	// ModerationNote explains a rejection to the author.
+	ModerationNote string     `json:"moderation_note,omitempty" bson:"moderationNote,omitempty"`
+	ModeratedBy    string     `json:"moderated_by,omitempty" bson:"moderatedBy,omitempty"`
+	ModeratedAt    *time.Time `json:"moderated_at,omitempty" bson:"moderatedAt,omitempty"`
+	CreatedAt      time.Time  `json:"created_at" bson:"createdAt"`
+	UpdatedAt      time.Time  `json:"updated_at" bson:"updatedAt"`
+}
+
+// Stars renders the rating as five filled or empty stars.
+func (r *Review) Stars() string {
+	return strings.Repeat("★", r.Rating) + strings.Repeat("☆", 5-r.Rating)
+}
+
+type CreateReviewRequest struct {
+	Rating int    `json:"rating" binding:"required"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+}
+
+type ModerateReviewRequest struct {
+	Status string `json:"status" binding:"required"`
+	Note   string `json:"note"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+type Wishlist struct {
+	ID        string         `json:"id" bson:"_id,omitempty"`
+	UserID    string         `json:"user_id" bson:"userId"`
+	Items     []WishlistItem `json:"items" bson:"items"`
+	UpdatedAt time.Time      `json:"updated_at" bson:"updatedAt"`
+}
+
+type WishlistItem struct {
+	ProductID string    `json:"product_id" bson:"productId"`
+	AddedAt   time.Time `json:"added_at" bson:"addedAt"`
+}
+
+// WishlistEntry is a wishlist item resolved against the current catalog.
+type WishlistEntry struct {
+	ProductID string             `json:"product_id"`
+	Name      string             `json:"name"`
+	Image     string             `json:"image"`
+	Price     float64            `json:"price"`
+	Available bool               `json:"available"`
+	InStock   bool               `json:"in_stock"`
+	Sizes     []SizeAvailability `json:"sizes"`
+	AddedAt   time.Time          `json:"added_at"`
+}
+
+type SizeAvailability struct {
+	Size    string `json:"size"`
+	Stock   int    `json:"stock"`
+	InStock bool   `json:"in_stock"`
+}
+
+// RestockNotification tells a user that a size of a wishlisted product came
+// back in stock.
+type RestockNotification struct {
+	ID          string    `json:"id" bson:"_id,omitempty"`
+	UserID      string    `json:"user_id" bson:"userId"`
+	ProductID   string    `json:"product_id" bson:"productId"`
+	ProductName string    `json:"product_name" bson:"productName"`
+	Size        string    `json:"size" bson:"size"`
+	Read        bool      `json:"read" bson:"read"`
+	CreatedAt   time.Time `json:"created_at" bson:"createdAt"`
+}
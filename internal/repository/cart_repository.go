@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type CartStore interface {
+	FindByUser(ctx context.Context, userID string) (*models.Cart, error)
+	FindBySession(ctx context.Context, sessionID string) (*models.Cart, error)
+	Save(ctx context.Context, cart *models.Cart) error
+	Delete(ctx context.Context, id string) error
+}
+
+type CartRepositoryMongo struct {
+	coll *mongo.Collection
+}
+
+func NewCartRepositoryMongo(coll *mongo.Collection) *CartRepositoryMongo {
+	return &CartRepositoryMongo{coll: coll}
+}
+
+func (r *CartRepositoryMongo) FindByUser(ctx context.Context, userID string) (*models.Cart, error) {
+	return r.findOne(ctx, bson.M{"userId": userID})
+}
+
+func (r *CartRepositoryMongo) FindBySession(ctx context.Context, sessionID string) (*models.Cart, error) {
+	return r.findOne(ctx, bson.M{"sessionId": sessionID})
+}
+
+func (r *CartRepositoryMongo) findOne(ctx context.Context, filter bson.M) (*models.Cart, error) {
+	var doc cartDoc
+	err := r.coll.FindOne(ctx, filter).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return doc.toModel(), nil
+}
+
+func (r *CartRepositoryMongo) Save(ctx context.Context, cart *models.Cart) error {
+	now := time.Now()
+	if cart.CreatedAt.IsZero() {
+		cart.CreatedAt = now
+	}
+	cart.UpdatedAt = now
+	if cart.ID == "" {
+		id := primitive.NewObjectID()
+		doc := cartDocFromModel(cart)
+		doc.ID = id
+		if _, err := r.coll.InsertOne(ctx, doc); err != nil {
+			return err
+		}
+		cart.ID = id.Hex()
+		return nil
+	}
+	oid, err := primitive.ObjectIDFromHex(cart.ID)
+	if err != nil {
+		return err
+	}
+	doc := cartDocFromModel(cart)
+	doc.ID = oid
+	_, err = r.coll.ReplaceOne(ctx, bson.M{"_id": oid}, doc)
+	return err
+}
+
+func (r *CartRepositoryMongo) Delete(ctx context.Context, id string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	_, err = r.coll.DeleteOne(ctx, bson.M{"_id": oid})
+	return err
+}
+
+type cartDoc struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	UserID    string             `bson:"userId,omitempty"`
+	SessionID string             `bson:"sessionId,omitempty"`
+	Items     []models.CartItem  `bson:"items"`
+	CreatedAt primitive.DateTime `bson:"createdAt"`
+	UpdatedAt primitive.DateTime `bson:"updatedAt"`
+}
+
+func cartDocFromModel(c *models.Cart) *cartDoc {
+	items := c.Items
+	if items == nil {
+		items = []models.CartItem{}
+	}
+	return &cartDoc{
+		UserID:    c.UserID,
+		SessionID: c.SessionID,
+		Items:     items,
+		CreatedAt: primitive.NewDateTimeFromTime(c.CreatedAt),
+		UpdatedAt: primitive.NewDateTimeFromTime(c.UpdatedAt),
+	}
+}
+
+func (d *cartDoc) toModel() *models.Cart {
+	return &models.Cart{
+		ID:        d.ID.Hex(),
+		UserID:    d.UserID,
+		SessionID: d.SessionID,
+		Items:     d.Items,
+		CreatedAt: d.CreatedAt.Time(),
+		UpdatedAt: d.UpdatedAt.Time(),
+	}
+}
+
+type CartRepositoryMemory struct {
+	mu   sync.RWMutex
+	data map[string]*models.Cart
+}
+
+func NewCartRepositoryMemory() *CartRepositoryMemory {
+	return &CartRepositoryMemory{data: make(map[string]*models.Cart)}
+}
+
+func (r *CartRepositoryMemory) FindByUser(ctx context.Context, userID string) (*models.Cart, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, c := range r.data {
+		if c.UserID == userID {
+			return copyCart(c), nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *CartRepositoryMemory) FindBySession(ctx context.Context, sessionID string) (*models.Cart, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, c := range r.data {
+		if c.SessionID == sessionID {
+			return copyCart(c), nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *CartRepositoryMemory) Save(ctx context.Context, cart *models.Cart) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cart.ID == "" {
+		b := make([]byte, 12)
+		if _, err := rand.Read(b); err != nil {
+			return err
+		}
+		cart.ID = hex.EncodeToString(b)
+	}
+	now := time.Now()
+	if cart.CreatedAt.IsZero() {
+		cart.CreatedAt = now
+	}
+	cart.UpdatedAt = now
+	r.data[cart.ID] = copyCart(cart)
+	return nil
+}
+
+func (r *CartRepositoryMemory) Delete(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.data, id)
+	return nil
+}
+
+func copyCart(c *models.Cart) *models.Cart {
+	out := *c
+	out.Items = append([]models.CartItem(nil), c.Items...)
+	return &out
+}
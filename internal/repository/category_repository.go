@@ -0,0 +1,235 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrDuplicateSlug = errors.New("duplicate category slug")
+
+type CategoryStore interface {
+	FindAll(ctx context.Context) ([]*models.Category, error)
+	// FindByID and FindBySlug return nil when there is no such category.
+	FindByID(ctx context.Context, id string) (*models.Category, error)
+	FindBySlug(ctx context.Context, slug string) (*models.Category, error)
+	// Insert and Update fail with ErrDuplicateSlug when the slug is taken.
+	Insert(ctx context.Context, c *models.Category) (*models.Category, error)
+	Update(ctx context.Context, id string, c *models.Category) error
+	Delete(ctx context.Context, id string) error
+}
+
+type CategoryRepositoryMongo struct {
+	coll *mongo.Collection
+}
+
+func NewCategoryRepositoryMongo(coll *mongo.Collection) *CategoryRepositoryMongo {
+	return &CategoryRepositoryMongo{coll: coll}
+}
+
+func (r *CategoryRepositoryMongo) FindAll(ctx context.Context) ([]*models.Category, error) {
+	cur, err := r.coll.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	out := []*models.Category{}
+	for cur.Next(ctx) {
+		var doc categoryDoc
+		if err := cur.Decode(&doc); err != nil {
+			return nil, err
+		}
+		out = append(out, doc.toModel())
+	}
+	return out, cur.Err()
+}
+
+func (r *CategoryRepositoryMongo) FindByID(ctx context.Context, id string) (*models.Category, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, nil
+	}
+	return r.findOne(ctx, bson.M{"_id": oid})
+}
+
+func (r *CategoryRepositoryMongo) FindBySlug(ctx context.Context, slug string) (*models.Category, error) {
+	return r.findOne(ctx, bson.M{"slug": slug})
+}
+
+func (r *CategoryRepositoryMongo) findOne(ctx context.Context, filter bson.M) (*models.Category, error) {
+	var doc categoryDoc
+	err := r.coll.FindOne(ctx, filter).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return doc.toModel(), nil
+}
+
+func (r *CategoryRepositoryMongo) Insert(ctx context.Context, c *models.Category) (*models.Category, error) {
+	now := time.Now()
+	c.CreatedAt, c.UpdatedAt = now, now
+	doc := categoryDocFromModel(c)
+	doc.ID = primitive.NewObjectID()
+	if _, err := r.coll.InsertOne(ctx, doc); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrDuplicateSlug
+		}
+		return nil, err
+	}
+	c.ID = doc.ID.Hex()
+	return c, nil
+}
+
+func (r *CategoryRepositoryMongo) Update(ctx context.Context, id string, c *models.Category) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	c.UpdatedAt = time.Now()
+	doc := categoryDocFromModel(c)
+	doc.ID = oid
+	_, err = r.coll.ReplaceOne(ctx, bson.M{"_id": oid}, doc)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicateSlug
+	}
+	return err
+}
+
+func (r *CategoryRepositoryMongo) Delete(ctx context.Context, id string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	_, err = r.coll.DeleteOne(ctx, bson.M{"_id": oid})
+	return err
+}
+
+type categoryDoc struct {
+	ID              primitive.ObjectID `bson:"_id,omitempty"`
+	Name            string             `bson:"name"`
+	Slug            string             `bson:"slug"`
+	ParentID        string             `bson:"parentId,omitempty"`
+	SortOrder       int                `bson:"sortOrder"`
+	Description     string             `bson:"description,omitempty"`
+	MetaTitle       string             `bson:"metaTitle,omitempty"`
+	MetaDescription string             `bson:"metaDescription,omitempty"`
+	CreatedAt       primitive.DateTime `bson:"createdAt"`
+	UpdatedAt       primitive.DateTime `bson:"updatedAt"`
+}
+
+func categoryDocFromModel(c *models.Category) *categoryDoc {
+	return &categoryDoc{
+		Name:            c.Name,
+		Slug:            c.Slug,
+		ParentID:        c.ParentID,
+		SortOrder:       c.SortOrder,
+		Description:     c.Description,
+		MetaTitle:       c.MetaTitle,
+		MetaDescription: c.MetaDescription,
+		CreatedAt:       primitive.NewDateTimeFromTime(c.CreatedAt),
+		UpdatedAt:       primitive.NewDateTimeFromTime(c.UpdatedAt),
+	}
+}
+
+func (d *categoryDoc) toModel() *models.Category {
+	return &models.Category{
+		ID:              d.ID.Hex(),
+		Name:            d.Name,
+		Slug:            d.Slug,
+		ParentID:        d.ParentID,
+		SortOrder:       d.SortOrder,
+		Description:     d.Description,
+		MetaTitle:       d.MetaTitle,
+		MetaDescription: d.MetaDescription,
+		CreatedAt:       d.CreatedAt.Time(),
+		UpdatedAt:       d.UpdatedAt.Time(),
+	}
+}
+
+type CategoryRepositoryMemory struct {
+	mu   sync.RWMutex
+	data map[string]*models.Category
+}
+
+func NewCategoryRepositoryMemory() *CategoryRepositoryMemory {
+	return &CategoryRepositoryMemory{data: make(map[string]*models.Category)}
+}
+
+func (r *CategoryRepositoryMemory) FindAll(ctx context.Context) ([]*models.Category, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]*models.Category, 0, len(r.data))
+	for _, c := range r.data {
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+func (r *CategoryRepositoryMemory) FindByID(ctx context.Context, id string) (*models.Category, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.data[id], nil
+}
+
+func (r *CategoryRepositoryMemory) FindBySlug(ctx context.Context, slug string) (*models.Category, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, c := range r.data {
+		if c.Slug == slug {
+			return c, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *CategoryRepositoryMemory) Insert(ctx context.Context, c *models.Category) (*models.Category, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.slugTaken(c.Slug, "") {
+		return nil, ErrDuplicateSlug
+	}
+	if c.ID == "" {
+		c.ID = primitive.NewObjectID().Hex()
+	}
+	now := time.Now()
+	c.CreatedAt, c.UpdatedAt = now, now
+	r.data[c.ID] = c
+	return c, nil
+}
+
+func (r *CategoryRepositoryMemory) Update(ctx context.Context, id string, c *models.Category) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.slugTaken(c.Slug, id) {
+		return ErrDuplicateSlug
+	}
+	c.ID = id
+	c.UpdatedAt = time.Now()
+	r.data[id] = c
+	return nil
+}
+
+func (r *CategoryRepositoryMemory) Delete(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.data, id)
+	return nil
+}
+
+func (r *CategoryRepositoryMemory) slugTaken(slug, exceptID string) bool {
+	for id, c := range r.data {
+		if id != exceptID && c.Slug == slug {
+			return true
+		}
+	}
+	return false
+}
@@ -2,22 +2,118 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"fmt"
 
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/db"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-func EnsureMongoIndexes(ctx context.Context, orderCol, orderItemCol *mongo.Collection) error {
-	if _, err := orderCol.Indexes().CreateMany(ctx, []mongo.IndexModel{
-		{Keys: bson.D{{"userId", 1}, {"createdAt", -1}}},
-	}); err != nil {
-		return err
-	}
+const anonymousCartTTL = 30 * 24 * 60 * 60
 
-	if _, err := orderItemCol.Indexes().CreateMany(ctx, []mongo.IndexModel{
+var mongoIndexes = map[string][]mongo.IndexModel{
+	"products": {
+		{Keys: bson.D{{"category", 1}, {"currentPrice", 1}}, Options: catalogIndex()},
+		{Keys: bson.D{{"gender", 1}, {"currentPrice", 1}}, Options: catalogIndex()},
+		{Keys: bson.D{{"colors", 1}}, Options: catalogIndex()},
+		{Keys: bson.D{{"sizes", 1}}, Options: catalogIndex()},
+		{Keys: bson.D{{"currentPrice", 1}}, Options: catalogIndex()},
+		{Keys: bson.D{{"name", 1}}, Options: catalogIndex()},
+		{Keys: bson.D{{"createdAt", -1}}, Options: catalogIndex()},
+		{Keys: bson.D{{"rankScore", -1}, {"createdAt", -1}}, Options: catalogIndex()},
+		{Keys: bson.D{{"totalStock", 1}}, Options: catalogIndex()},
+		{Keys: bson.D{{"variants.sku", 1}}},
+		{Keys: bson.D{{"categoryId", 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{"rating", -1}, {"reviewCount", -1}}},
+		{
+			Keys:    bson.D{{"sku", 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"sku": bson.M{"$type": "string"}}),
+		},
+	},
+	"categories": {
+		{Keys: bson.D{{"slug", 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{"parentId", 1}, {"sortOrder", 1}}},
+	},
+	"orders": {
+		{Keys: bson.D{{"userId", 1}, {"createdAt", -1}}},
+	},
+	"order_items": {
 		{Keys: bson.D{{"orderId", 1}, {"productId", 1}}},
-	}); err != nil {
-		return err
+	},
+	"carts": {
+		{
+			Keys:    bson.D{{"userId", 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"userId": bson.M{"$type": "string"}}),
+		},
+		{
+			Keys:    bson.D{{"sessionId", 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"sessionId": bson.M{"$type": "string"}}),
+		},
+		{
+			Keys:    bson.D{{"updatedAt", 1}},
+			Options: options.Index().SetExpireAfterSeconds(anonymousCartTTL).SetPartialFilterExpression(bson.M{"sessionId": bson.M{"$type": "string"}}),
+		},
+	},
+	"wishlists": {
+		{Keys: bson.D{{"userId", 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{"items.productId", 1}}},
+	},
+	"stock_movements": {
+		{Keys: bson.D{{"productId", 1}, {"createdAt", -1}}},
+		{Keys: bson.D{{"variantId", 1}}},
+		{Keys: bson.D{{"orderId", 1}}, Options: options.Index().SetSparse(true)},
+	},
+	"product_revisions": {
+		{Keys: bson.D{{"productId", 1}, {"version", -1}}, Options: options.Index().SetUnique(true)},
+	},
+	"reviews": {
+		{Keys: bson.D{{"userId", 1}, {"productId", 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{"productId", 1}, {"status", 1}, {"createdAt", -1}}},
+		{Keys: bson.D{{"status", 1}, {"createdAt", 1}}},
+	},
+	"promotions": {
+		{Keys: bson.D{{"code", 1}}, Options: options.Index().SetUnique(true)},
+	},
+	"promotion_redemptions": {
+		{Keys: bson.D{{"promotionId", 1}, {"userId", 1}}, Options: options.Index().SetUnique(true)},
+	},
+	"recommendations": {
+		{Keys: bson.D{{"computedAt", 1}}},
+	},
+	"wishlist_notifications": {
+		{Keys: bson.D{{"userId", 1}, {"read", 1}, {"createdAt", -1}}},
+	},
+}
+
+// retiredIndexes are dropped at startup; price filters and sorting moved
+// from price to currentPrice.
+var retiredIndexes = map[string][]string{
+	"products": {"category_1_price_1", "gender_1_price_1", "price_1"},
+}
+
+func catalogIndex() *options.IndexOptions {
+	return options.Index().SetCollation(catalogCollation)
+}
+
+func EnsureMongoIndexes(ctx context.Context, client *db.MongoDBClient) error {
+	for name, indexes := range mongoIndexes {
+		if _, err := client.Collection(name).Indexes().CreateMany(ctx, indexes); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	for name, indexes := range retiredIndexes {
+		for _, index := range indexes {
+			_, err := client.Collection(name).Indexes().DropOne(ctx, index)
+			var cmdErr mongo.CommandError
+			if errors.As(err, &cmdErr) && cmdErr.Name == "IndexNotFound" {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("%s: drop %s: %w", name, index, err)
+			}
+		}
 	}
 	return nil
 }
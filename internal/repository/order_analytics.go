@@ -127,11 +127,34 @@ func (r *OrderRepositoryMongo) AggregateRevenueByPeriod(ctx context.Context, sta
 	return result, nil
 }
 
-func (r *OrderRepositoryMongo) AggregateTopProducts(ctx context.Context, limit int) ([]TopProductAgg, error) {
+// AggregateTopProducts returns the best-selling products by revenue in
+// orders placed since the given time; a zero time counts every order.
+func (r *OrderRepositoryMongo) AggregateTopProducts(ctx context.Context, since time.Time, limit int) ([]TopProductAgg, error) {
+	return r.aggregateProductSales(ctx, since, nil, "revenue", limit)
+}
+
+// AggregateUnitsSold returns the products that sold the most units in orders
+// placed since the given time whose status is one of statuses.
+func (r *OrderRepositoryMongo) AggregateUnitsSold(ctx context.Context, since time.Time, statuses []string, limit int) ([]TopProductAgg, error) {
+	return r.aggregateProductSales(ctx, since, statuses, "totalSold", limit)
+}
+
+func (r *OrderRepositoryMongo) aggregateProductSales(ctx context.Context, since time.Time, statuses []string, sortBy string, limit int) ([]TopProductAgg, error) {
 	if limit <= 0 {
 		limit = 10
 	}
-	pipeline := mongo.Pipeline{
+	match := bson.D{}
+	if !since.IsZero() {
+		match = append(match, bson.E{"createdAt", bson.D{{"$gte", since}}})
+	}
+	if statuses != nil {
+		match = append(match, bson.E{"status", bson.D{{"$in", statuses}}})
+	}
+	var pipeline mongo.Pipeline
+	if len(match) > 0 {
+		pipeline = append(pipeline, bson.D{{"$match", match}})
+	}
+	pipeline = append(pipeline,
 		bson.D{{"$addFields", bson.D{{"orderId", bson.D{{"$toString", "$_id"}}}}}},
 		bson.D{{"$lookup", bson.D{
 			{"from", "order_items"},
@@ -146,9 +169,9 @@ func (r *OrderRepositoryMongo) AggregateTopProducts(ctx context.Context, limit i
 			{"totalSold", bson.D{{"$sum", "$items.quantity"}}},
 			{"revenue", bson.D{{"$sum", "$items.lineTotal"}}},
 		}}},
-		bson.D{{"$sort", bson.D{{"revenue", -1}}}},
+		bson.D{{"$sort", bson.D{{sortBy, -1}, {"_id", 1}}}},
 		bson.D{{"$limit", limit}},
-	}
+	)
 
 	cur, err := r.coll.Aggregate(ctx, pipeline)
 	if err != nil {
@@ -160,3 +183,99 @@ func (r *OrderRepositoryMongo) AggregateTopProducts(ctx context.Context, limit i
 	}
 	return result, nil
 }
+
+type SizeSalesAgg struct {
+	ProductID string `bson:"productId"`
+	Size      string `bson:"size"`
+	Units     int    `bson:"units"`
+}
+
+// AggregateSalesBySize sums units sold per product and size in orders placed
+// since the given time, leaving out cancelled and refunded orders.
+func (r *OrderRepositoryMongo) AggregateSalesBySize(ctx context.Context, since time.Time) ([]SizeSalesAgg, error) {
+	pipeline := mongo.Pipeline{
+		bson.D{{"$match", bson.D{
+			{"createdAt", bson.D{{"$gte", since}}},
+			{"status", bson.D{{"$nin", bson.A{"cancelled", "refunded"}}}},
+		}}},
+		bson.D{{"$addFields", bson.D{{"orderId", bson.D{{"$toString", "$_id"}}}}}},
+		bson.D{{"$lookup", bson.D{
+			{"from", "order_items"},
+			{"localField", "orderId"},
+			{"foreignField", "orderId"},
+			{"as", "items"},
+		}}},
+		bson.D{{"$unwind", "$items"}},
+		bson.D{{"$group", bson.D{
+			{"_id", bson.D{{"productId", "$items.productId"}, {"size", "$items.selectedSize"}}},
+			{"units", bson.D{{"$sum", "$items.quantity"}}},
+		}}},
+		bson.D{{"$project", bson.D{
+			{"_id", 0},
+			{"productId", "$_id.productId"},
+			{"size", "$_id.size"},
+			{"units", 1},
+		}}},
+	}
+
+	cur, err := r.coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var result []SizeSalesAgg
+	if err := cur.All(ctx, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+type CoPurchaseAgg struct {
+	ProductID string `bson:"productId"`
+	WithID    string `bson:"withId"`
+	Orders    int    `bson:"orders"`
+}
+
+// AggregateCoPurchases counts, for every ordered pair of distinct products,
+// the orders placed since the given time that contained both, leaving out
+// cancelled and refunded orders.
+func (r *OrderRepositoryMongo) AggregateCoPurchases(ctx context.Context, since time.Time) ([]CoPurchaseAgg, error) {
+	pipeline := mongo.Pipeline{
+		bson.D{{"$match", bson.D{
+			{"createdAt", bson.D{{"$gte", since}}},
+			{"status", bson.D{{"$nin", bson.A{"cancelled", "refunded"}}}},
+		}}},
+		bson.D{{"$addFields", bson.D{{"orderId", bson.D{{"$toString", "$_id"}}}}}},
+		bson.D{{"$lookup", bson.D{
+			{"from", "order_items"},
+			{"localField", "orderId"},
+			{"foreignField", "orderId"},
+			{"as", "items"},
+		}}},
+		bson.D{{"$project", bson.D{{"products", bson.D{{"$setUnion", bson.A{"$items.productId"}}}}}}},
+		bson.D{{"$match", bson.D{{"products.1", bson.D{{"$exists", true}}}}}},
+		bson.D{{"$project", bson.D{{"productId", "$products"}, {"withId", "$products"}}}},
+		bson.D{{"$unwind", "$productId"}},
+		bson.D{{"$unwind", "$withId"}},
+		bson.D{{"$match", bson.D{{"$expr", bson.D{{"$ne", bson.A{"$productId", "$withId"}}}}}}},
+		bson.D{{"$group", bson.D{
+			{"_id", bson.D{{"productId", "$productId"}, {"withId", "$withId"}}},
+			{"orders", bson.D{{"$sum", 1}}},
+		}}},
+		bson.D{{"$project", bson.D{
+			{"_id", 0},
+			{"productId", "$_id.productId"},
+			{"withId", "$_id.withId"},
+			{"orders", 1},
+		}}},
+	}
+
+	cur, err := r.coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var result []CoPurchaseAgg
+	if err := cur.All(ctx, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -12,6 +12,8 @@ import (
 
 type OrderItemStore interface {
 	CreateMany(ctx context.Context, items []models.OrderItem) error
+	// ReplaceForOrder makes items the complete item set of the order.
+	ReplaceForOrder(ctx context.Context, orderID string, items []models.OrderItem) error
 	FindByOrderId(ctx context.Context, orderID string) ([]*models.OrderItem, error)
 	FindByOrderIds(ctx context.Context, orderIDs []string) (map[string][]*models.OrderItem, error)
 }
@@ -47,6 +49,13 @@ func (r *OrderItemRepositoryMongo) CreateMany(ctx context.Context, items []model
 	return err
 }
 
+func (r *OrderItemRepositoryMongo) ReplaceForOrder(ctx context.Context, orderID string, items []models.OrderItem) error {
+	if _, err := r.coll.DeleteMany(ctx, bson.M{"orderId": orderID}); err != nil {
+		return err
+	}
+	return r.CreateMany(ctx, items)
+}
+
 func (r *OrderItemRepositoryMongo) FindByOrderId(ctx context.Context, orderID string) ([]*models.OrderItem, error) {
 	cur, err := r.coll.Find(ctx, bson.M{"orderId": orderID}, options.Find())
 	if err != nil {
@@ -90,6 +99,8 @@ type orderItemDocStandalone struct {
 	OrderID       string             `bson:"orderId"`
 	ProductID     string             `bson:"productId"`
 	ProductName   string             `bson:"productName"`
+	VariantID     string             `bson:"variantId,omitempty"`
+	SKU           string             `bson:"sku,omitempty"`
 	SelectedSize  string             `bson:"selectedSize"`
 	SelectedColor string             `bson:"selectedColor"`
 	Quantity      int                `bson:"quantity"`
@@ -102,6 +113,8 @@ func orderItemDocFromModel(it *models.OrderItem) *orderItemDocStandalone {
 		OrderID:       it.OrderID,
 		ProductID:     it.ProductID,
 		ProductName:   it.ProductName,
+		VariantID:     it.VariantID,
+		SKU:           it.SKU,
 		SelectedSize:  it.SelectedSize,
 		SelectedColor: it.SelectedColor,
 		Quantity:      it.Quantity,
@@ -116,6 +129,8 @@ func (d *orderItemDocStandalone) toModel() *models.OrderItem {
 		OrderID:       d.OrderID,
 		ProductID:     d.ProductID,
 		ProductName:   d.ProductName,
+		VariantID:     d.VariantID,
+		SKU:           d.SKU,
 		SelectedSize:  d.SelectedSize,
 		SelectedColor: d.SelectedColor,
 		Quantity:      d.Quantity,
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -13,11 +14,17 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// ErrStatusConflict is returned when an order no longer has the status a
+// transition was computed from.
+var ErrStatusConflict = errors.New("order status changed concurrently")
+
 type OrderStore interface {
 	Save(ctx context.Context, order *models.Order) error
 	FindByUser(ctx context.Context, userID string) ([]*models.Order, error)
 	FindAll(ctx context.Context) ([]*models.Order, error)
-	UpdateStatus(ctx context.Context, orderID, status string) error
+	// UpdateStatus moves the order from change.From to change.To and appends
+	// change to its status history.
+	UpdateStatus(ctx context.Context, orderID string, change models.OrderStatusChange) error
 	FindByID(ctx context.Context, orderID string) (*models.Order, error)
 }
 
@@ -55,13 +62,12 @@ func (r *OrderRepositoryMongo) Save(ctx context.Context, order *models.Order) er
 	for i := range order.Items {
 		order.Items[i].OrderID = order.ID
 	}
-	if err := r.itemRepo.CreateMany(ctx, order.Items); err != nil {
-		return err
-	}
 	doc := orderDocFromModel(order)
 	doc.ID = oid
-	_, err = r.coll.ReplaceOne(ctx, bson.M{"_id": oid}, doc)
-	return err
+	if _, err := r.coll.ReplaceOne(ctx, bson.M{"_id": oid}, doc); err != nil {
+		return err
+	}
+	return r.itemRepo.ReplaceForOrder(ctx, order.ID, order.Items)
 }
 
 func (r *OrderRepositoryMongo) FindByUser(ctx context.Context, userID string) ([]*models.Order, error) {
@@ -159,28 +165,43 @@ func (r *OrderRepositoryMongo) FindByID(ctx context.Context, orderID string) (*m
 	return o, nil
 }
 
-func (r *OrderRepositoryMongo) UpdateStatus(ctx context.Context, orderID, status string) error {
+func (r *OrderRepositoryMongo) UpdateStatus(ctx context.Context, orderID string, change models.OrderStatusChange) error {
 	oid, err := primitive.ObjectIDFromHex(orderID)
 	if err != nil {
 		return err
 	}
-	_, err = r.coll.UpdateOne(ctx, bson.M{"_id": oid}, bson.M{"$set": bson.M{"status": status, "updatedAt": primitive.NewDateTimeFromTime(time.Now())}})
-	return err
+	res, err := r.coll.UpdateOne(ctx,
+		bson.M{"_id": oid, "status": change.From},
+		bson.M{
+			"$set":  bson.M{"status": change.To, "updatedAt": primitive.NewDateTimeFromTime(change.ChangedAt)},
+			"$push": bson.M{"statusHistory": change},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrStatusConflict
+	}
+	return nil
 }
 
 type orderDoc struct {
-	ID              primitive.ObjectID `bson:"_id,omitempty"`
-	UserID          string             `bson:"userId"`
-	Status          string             `bson:"status"`
-	PaymentMethod   string             `bson:"paymentMethod"`
-	DeliveryMethod  string             `bson:"deliveryMethod"`
-	DeliveryAddress string             `bson:"deliveryAddress"`
-	Comment         string             `bson:"comment"`
-	Subtotal        float64            `bson:"subtotal"`
-	DeliveryFee     float64            `bson:"deliveryFee"`
-	Total           float64            `bson:"total"`
-	CreatedAt       primitive.DateTime `bson:"createdAt"`
-	UpdatedAt       primitive.DateTime `bson:"updatedAt"`
+	ID              primitive.ObjectID         `bson:"_id,omitempty"`
+	UserID          string                     `bson:"userId"`
+	Status          string                     `bson:"status"`
+	PaymentMethod   string                     `bson:"paymentMethod"`
+	DeliveryMethod  string                     `bson:"deliveryMethod"`
+	DeliveryAddress string                     `bson:"deliveryAddress"`
+	Comment         string                     `bson:"comment"`
+	Subtotal        float64                    `bson:"subtotal"`
+	DeliveryFee     float64                    `bson:"deliveryFee"`
+	Discounts       []models.OrderDiscount     `bson:"discounts,omitempty"`
+	DiscountTotal   float64                    `bson:"discountTotal"`
+	Total           float64                    `bson:"total"`
+	StatusHistory   []models.OrderStatusChange `bson:"statusHistory"`
+	CreatedAt       primitive.DateTime         `bson:"createdAt"`
+	UpdatedAt       primitive.DateTime         `bson:"updatedAt"`
 }
 
 func orderDocFromModel(o *models.Order) *orderDoc {
@@ -193,7 +214,10 @@ func orderDocFromModel(o *models.Order) *orderDoc {
 		Comment:         o.Comment,
 		Subtotal:        o.Subtotal,
 		DeliveryFee:     o.DeliveryFee,
+		Discounts:       o.Discounts,
+		DiscountTotal:   o.DiscountTotal,
 		Total:           o.Total,
+		StatusHistory:   o.StatusHistory,
 		CreatedAt:       primitive.NewDateTimeFromTime(o.CreatedAt),
 		UpdatedAt:       primitive.NewDateTimeFromTime(o.UpdatedAt),
 	}
@@ -210,7 +234,10 @@ func (d *orderDoc) toModel() *models.Order {
 		Comment:         d.Comment,
 		Subtotal:        d.Subtotal,
 		DeliveryFee:     d.DeliveryFee,
+		Discounts:       d.Discounts,
+		DiscountTotal:   d.DiscountTotal,
 		Total:           d.Total,
+		StatusHistory:   d.StatusHistory,
 		CreatedAt:       d.CreatedAt.Time(),
 		UpdatedAt:       d.UpdatedAt.Time(),
 	}
@@ -240,7 +267,7 @@ func (r *OrderRepositoryMemory) Save(ctx context.Context, order *models.Order) e
 	for i := range order.Items {
 		order.Items[i].OrderID = order.ID
 	}
-	r.data[order.ID] = order
+	r.data[order.ID] = copyOrder(order)
 	return nil
 }
 
@@ -250,7 +277,7 @@ func (r *OrderRepositoryMemory) FindByUser(ctx context.Context, userID string) (
 	var out []*models.Order
 	for _, o := range r.data {
 		if o.UserID == userID {
-			out = append(out, o)
+			out = append(out, copyOrder(o))
 		}
 	}
 	return out, nil
@@ -261,7 +288,7 @@ func (r *OrderRepositoryMemory) FindAll(ctx context.Context) ([]*models.Order, e
 	defer r.mu.RUnlock()
 	out := make([]*models.Order, 0, len(r.data))
 	for _, o := range r.data {
-		out = append(out, o)
+		out = append(out, copyOrder(o))
 	}
 	return out, nil
 }
@@ -269,15 +296,32 @@ func (r *OrderRepositoryMemory) FindAll(ctx context.Context) ([]*models.Order, e
 func (r *OrderRepositoryMemory) FindByID(ctx context.Context, orderID string) (*models.Order, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	return r.data[orderID], nil
+	o, ok := r.data[orderID]
+	if !ok {
+		return nil, nil
+	}
+	return copyOrder(o), nil
 }
 
-func (r *OrderRepositoryMemory) UpdateStatus(ctx context.Context, orderID, status string) error {
+func (r *OrderRepositoryMemory) UpdateStatus(ctx context.Context, orderID string, change models.OrderStatusChange) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	if o, ok := r.data[orderID]; ok {
-		o.Status = status
-		o.UpdatedAt = time.Now()
+	o, ok := r.data[orderID]
+	if !ok || o.Status != change.From {
+		return ErrStatusConflict
 	}
+	o.Status = change.To
+	o.StatusHistory = append(o.StatusHistory, change)
+	o.UpdatedAt = change.ChangedAt
 	return nil
 }
+
+// copyOrder keeps callers from changing stored orders, in particular from
+// appending to the status history a second time.
+func copyOrder(o *models.Order) *models.Order {
+	out := *o
+	out.Items = append([]models.OrderItem(nil), o.Items...)
+	out.Discounts = append([]models.OrderDiscount(nil), o.Discounts...)
+	out.StatusHistory = append([]models.OrderStatusChange(nil), o.StatusHistory...)
+	return &out
+}
@@ -0,0 +1,548 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// catalogCollation makes category, color and size matches case-insensitive.
+// Product indexes are built with the same collation so queries can use them.
+var catalogCollation = &options.Collation{Locale: "en", Strength: 2}
+
+func (r *ProductRepositoryMongo) Query(ctx context.Context, q *models.ProductQuery, offset, limit int) ([]*models.Product, error) {
+	if rank, order := productRank(q); rank != nil {
+		return r.rankedQuery(ctx, q, rank, order, offset, limit)
+	}
+	opts := options.Find().
+		SetCollation(catalogCollation).
+		SetSort(productSort(q.Sort)).
+		SetSkip(int64(offset))
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+	cur, err := r.coll.Find(ctx, productFilter(q), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	out := []*models.Product{}
+	for cur.Next(ctx) {
+		var doc productDoc
+		if err := cur.Decode(&doc); err != nil {
+			return nil, err
+		}
+		out = append(out, doc.toModel())
+	}
+	return out, cur.Err()
+}
+
+// rankedQuery pages through the products matching q in the order of a rank
+// computed for each of them.
+func (r *ProductRepositoryMongo) rankedQuery(ctx context.Context, q *models.ProductQuery, rank interface{}, order bson.D, offset, limit int) ([]*models.Product, error) {
+	pipeline := mongo.Pipeline{
+		{{"$match", productFilter(q)}},
+		{{"$addFields", bson.M{"_rank": rank}}},
+		{{"$sort", order}},
+		{{"$skip", offset}},
+	}
+	if limit > 0 {
+		pipeline = append(pipeline, bson.D{{"$limit", limit}})
+	}
+	cur, err := r.coll.Aggregate(ctx, pipeline, options.Aggregate().SetCollation(catalogCollation))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	out := []*models.Product{}
+	for cur.Next(ctx) {
+		var doc productDoc
+		if err := cur.Decode(&doc); err != nil {
+			return nil, err
+		}
+		out = append(out, doc.toModel())
+	}
+	return out, cur.Err()
+}
+
+// productRank returns the rank expression and order for sorts that cannot
+// use a stored field: search relevance, which follows q.IDs, and the
+// recommended sort with the shopper's affinity added. Other sorts get nil.
+func productRank(q *models.ProductQuery) (interface{}, bson.D) {
+	switch {
+	case q.Sort == "relevance" && q.IDs != nil:
+		rank := bson.M{"$indexOfArray": bson.A{objectIDs(q.IDs), "$_id"}}
+		return rank, bson.D{{"_rank", 1}, {"_id", 1}}
+	case q.Sort == "recommended" && q.Affinity != nil:
+		// The collation makes both comparisons case-insensitive.
+		rank := bson.A{bson.M{"$ifNull": bson.A{"$rankScore", 0}}}
+		if a := q.Affinity; a.Category != "" {
+			category := bson.M{"$ifNull": bson.A{"$categoryId", bson.M{"$trim": bson.M{"input": bson.M{"$ifNull": bson.A{"$category", ""}}}}}}
+			rank = append(rank, bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{category, a.Category}}, a.Boost, 0}})
+		}
+		if a := q.Affinity; a.Gender != "" {
+			rank = append(rank, bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$gender", a.Gender}}, a.Boost, 0}})
+		}
+		return bson.M{"$add": rank}, bson.D{{"_rank", -1}, {"createdAt", -1}, {"_id", 1}}
+	}
+	return nil, nil
+}
+
+func (r *ProductRepositoryMongo) Count(ctx context.Context, q *models.ProductQuery) (int64, error) {
+	return r.coll.CountDocuments(ctx, productFilter(q), options.Count().SetCollation(catalogCollation))
+}
+
+func (r *ProductRepositoryMongo) Facets(ctx context.Context) (*models.ProductFacets, error) {
+	trimmed := func(field string) bson.M {
+		return bson.M{"$trim": bson.M{"input": bson.M{"$ifNull": bson.A{field, ""}}}}
+	}
+	pipeline := mongo.Pipeline{
+		{{"$match", bson.M{"deletedAt": nil}}},
+		{{"$facet", bson.M{
+			"categories": bson.A{
+				bson.M{"$group": bson.M{
+					"_id":   bson.M{"$toLower": trimmed("$category")},
+					"name":  bson.M{"$first": trimmed("$category")},
+					"count": bson.M{"$sum": 1},
+				}},
+				bson.M{"$match": bson.M{"_id": bson.M{"$ne": ""}}},
+			},
+			"categoryIds": bson.A{
+				bson.M{"$match": bson.M{"categoryId": bson.M{"$type": "string"}}},
+				bson.M{"$group": bson.M{"_id": "$categoryId", "count": bson.M{"$sum": 1}}},
+			},
+			"colors": bson.A{
+				bson.M{"$unwind": "$colors"},
+				bson.M{"$group": bson.M{
+					"_id":  bson.M{"product": "$_id", "color": bson.M{"$toLower": trimmed("$colors")}},
+					"name": bson.M{"$first": trimmed("$colors")},
+				}},
+				bson.M{"$group": bson.M{
+					"_id":   "$_id.color",
+					"name":  bson.M{"$first": "$name"},
+					"count": bson.M{"$sum": 1},
+				}},
+				bson.M{"$match": bson.M{"_id": bson.M{"$ne": ""}}},
+			},
+		}}},
+	}
+	cur, err := r.coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	var rows []struct {
+		Categories  []facetRow `bson:"categories"`
+		CategoryIDs []struct {
+			ID    string `bson:"_id"`
+			Count int    `bson:"count"`
+		} `bson:"categoryIds"`
+		Colors []facetRow `bson:"colors"`
+	}
+	if err := cur.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+	facets := &models.ProductFacets{Categories: []models.FacetCount{}, Colors: []models.FacetCount{}, CategoryIDs: map[string]int{}}
+	if len(rows) > 0 {
+		facets.Categories = facetCounts(rows[0].Categories)
+		facets.Colors = facetCounts(rows[0].Colors)
+		for _, row := range rows[0].CategoryIDs {
+			facets.CategoryIDs[row.ID] = row.Count
+		}
+	}
+	return facets, nil
+}
+
+// BackfillTotalStock sets the denormalized totalStock on products written
+// before it existed.
+func (r *ProductRepositoryMongo) BackfillTotalStock(ctx context.Context) error {
+	_, err := r.coll.UpdateMany(ctx,
+		bson.M{"totalStock": bson.M{"$exists": false}},
+		mongo.Pipeline{
+			{{"$set", bson.M{"totalStock": bson.M{"$sum": bson.M{"$map": bson.M{
+				"input": bson.M{"$objectToArray": bson.M{"$ifNull": bson.A{"$stockBySize", bson.M{}}}},
+				"in":    "$$this.v",
+			}}}}}},
+		},
+	)
+	return err
+}
+
+// BackfillCurrentPrice sets the selling price queries filter and sort on for
+// products written before it was stored: the sale price while a sale runs,
+// the list price otherwise.
+func (r *ProductRepositoryMongo) BackfillCurrentPrice(ctx context.Context) error {
+	now := time.Now()
+	onSale := bson.M{"$and": bson.A{
+		bson.M{"$ne": bson.A{bson.M{"$ifNull": bson.A{"$salePrice", nil}}, nil}},
+		bson.M{"$lte": bson.A{bson.M{"$ifNull": bson.A{"$saleStartsAt", now}}, now}},
+		bson.M{"$gt": bson.A{bson.M{"$ifNull": bson.A{"$saleEndsAt", now.Add(time.Second)}}, now}},
+	}}
+	_, err := r.coll.UpdateMany(ctx,
+		bson.M{"currentPrice": bson.M{"$exists": false}},
+		mongo.Pipeline{
+			{{"$set", bson.M{"currentPrice": bson.M{"$cond": bson.A{onSale, "$salePrice", "$price"}}}}},
+		},
+	)
+	return err
+}
+
+type facetRow struct {
+	Name  string `bson:"name"`
+	Count int    `bson:"count"`
+}
+
+func facetCounts(rows []facetRow) []models.FacetCount {
+	out := make([]models.FacetCount, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, models.FacetCount{Name: row.Name, Count: row.Count})
+	}
+	sortFacets(out)
+	return out
+}
+
+func sortFacets(out []models.FacetCount) {
+	sort.Slice(out, func(i, j int) bool {
+		return strings.ToLower(out[i].Name) < strings.ToLower(out[j].Name)
+	})
+}
+
+func productFilter(q *models.ProductQuery) bson.M {
+	filter := bson.M{"deletedAt": nil}
+	var and bson.A
+	if text := strings.TrimSpace(q.Text); text != "" {
+		re := primitive.Regex{Pattern: regexp.QuoteMeta(text), Options: "i"}
+		and = append(and, bson.M{"$or": bson.A{bson.M{"name": re}, bson.M{"category": re}}})
+	}
+	if q.IDs != nil {
+		filter["_id"] = bson.M{"$in": objectIDs(q.IDs)}
+	}
+	if len(q.Categories) > 0 {
+		filter["category"] = bson.M{"$in": q.Categories}
+	}
+	if q.CategoryIDs != nil {
+		filter["categoryId"] = bson.M{"$in": q.CategoryIDs}
+	}
+	if len(q.Genders) > 0 {
+		var genders bson.A
+		universal := false
+		for _, g := range q.Genders {
+			if isUniversalGender(g) {
+				universal = true
+				continue
+			}
+			genders = append(genders, g)
+		}
+		var or bson.A
+		if len(genders) > 0 {
+			or = append(or, bson.M{"gender": bson.M{"$in": genders}})
+		}
+		if universal {
+			or = append(or, bson.M{"gender": bson.M{"$in": bson.A{"", nil}}})
+		}
+		and = append(and, bson.M{"$or": or})
+	}
+	if len(q.Colors) > 0 {
+		filter["colors"] = bson.M{"$in": q.Colors}
+	}
+	if len(q.Sizes) > 0 {
+		filter["sizes"] = bson.M{"$in": q.Sizes}
+	}
+	price := bson.M{}
+	if q.MinPrice > 0 {
+		price["$gte"] = q.MinPrice
+	}
+	if q.MaxPrice > 0 {
+		price["$lte"] = q.MaxPrice
+	}
+	if len(price) > 0 {
+		filter["currentPrice"] = price
+	}
+	if q.InStockOnly {
+		filter["totalStock"] = bson.M{"$gt": 0}
+	}
+	if len(and) > 0 {
+		filter["$and"] = and
+	}
+	return filter
+}
+
+// objectIDs converts ids to ObjectIDs in order, skipping malformed ones.
+func objectIDs(ids []string) bson.A {
+	oids := bson.A{}
+	for _, id := range ids {
+		if oid, err := primitive.ObjectIDFromHex(id); err == nil {
+			oids = append(oids, oid)
+		}
+	}
+	return oids
+}
+
+func productSort(order string) bson.D {
+	switch order {
+	case "price_asc":
+		return bson.D{{"currentPrice", 1}, {"_id", 1}}
+	case "price_desc":
+		return bson.D{{"currentPrice", -1}, {"_id", 1}}
+	case "name":
+		return bson.D{{"name", 1}, {"_id", 1}}
+	case "newest":
+		return bson.D{{"createdAt", -1}, {"_id", -1}}
+	case "rating":
+		return bson.D{{"rating", -1}, {"reviewCount", -1}, {"_id", 1}}
+	case "recommended":
+		return bson.D{{"rankScore", -1}, {"createdAt", -1}, {"_id", 1}}
+	default:
+		return bson.D{{"_id", 1}}
+	}
+}
+
+func isUniversalGender(value string) bool {
+	return strings.EqualFold(strings.TrimSpace(value), "universal")
+}
+
+func (r *ProductRepositoryMemory) Query(ctx context.Context, q *models.ProductQuery, offset, limit int) ([]*models.Product, error) {
+	matched := r.match(q)
+	sortMemoryProducts(matched, q)
+	if offset >= len(matched) {
+		return []*models.Product{}, nil
+	}
+	matched = matched[offset:]
+	if limit > 0 && limit < len(matched) {
+		matched = matched[:limit]
+	}
+	return matched, nil
+}
+
+func (r *ProductRepositoryMemory) Count(ctx context.Context, q *models.ProductQuery) (int64, error) {
+	return int64(len(r.match(q))), nil
+}
+
+func (r *ProductRepositoryMemory) Facets(ctx context.Context) (*models.ProductFacets, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	categories := newFacetCounter()
+	colors := newFacetCounter()
+	categoryIDs := map[string]int{}
+	for _, p := range r.data {
+		if p.Archived() {
+			continue
+		}
+		categories.add(p.Category)
+		if p.CategoryID != "" {
+			categoryIDs[p.CategoryID]++
+		}
+		seen := make(map[string]bool)
+		for _, color := range p.Colors {
+			key := strings.ToLower(strings.TrimSpace(color))
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			colors.add(color)
+		}
+	}
+	return &models.ProductFacets{Categories: categories.counts(), Colors: colors.counts(), CategoryIDs: categoryIDs}, nil
+}
+
+func (r *ProductRepositoryMemory) match(q *models.ProductQuery) []*models.Product {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := []*models.Product{}
+	for _, p := range r.data {
+		if matchesProductQuery(p, q) {
+			out = append(out, copyProduct(p))
+		}
+	}
+	return out
+}
+
+func matchesProductQuery(p *models.Product, q *models.ProductQuery) bool {
+	if p.Archived() {
+		return false
+	}
+	if q.IDs != nil && !containsFold(q.IDs, p.ID) {
+		return false
+	}
+	if text := strings.ToLower(strings.TrimSpace(q.Text)); text != "" &&
+		!strings.Contains(strings.ToLower(p.Name), text) && !strings.Contains(strings.ToLower(p.Category), text) {
+		return false
+	}
+	if len(q.Categories) > 0 && !containsFold(q.Categories, p.Category) {
+		return false
+	}
+	if q.CategoryIDs != nil && !containsFold(q.CategoryIDs, p.CategoryID) {
+		return false
+	}
+	if len(q.Genders) > 0 {
+		found := false
+		for _, g := range q.Genders {
+			if isUniversalGender(g) && p.Gender == "" || strings.EqualFold(p.Gender, g) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(q.Colors) > 0 && !anyFold(q.Colors, p.Colors) {
+		return false
+	}
+	if len(q.Sizes) > 0 && !anyFold(q.Sizes, p.Sizes) {
+		return false
+	}
+	if price := currentPrice(p); q.MinPrice > 0 && price < q.MinPrice || q.MaxPrice > 0 && price > q.MaxPrice {
+		return false
+	}
+	if q.InStockOnly && productStock(p) <= 0 {
+		return false
+	}
+	return true
+}
+
+func sortMemoryProducts(out []*models.Product, q *models.ProductQuery) {
+	var less func(a, b *models.Product) bool
+	switch q.Sort {
+	case "price_asc":
+		less = func(a, b *models.Product) bool { return currentPrice(a) < currentPrice(b) }
+	case "price_desc":
+		less = func(a, b *models.Product) bool { return currentPrice(a) > currentPrice(b) }
+	case "name":
+		less = func(a, b *models.Product) bool { return strings.ToLower(a.Name) < strings.ToLower(b.Name) }
+	case "newest":
+		less = func(a, b *models.Product) bool { return a.CreatedAt.After(b.CreatedAt) }
+	case "rating":
+		less = func(a, b *models.Product) bool {
+			return a.Rating > b.Rating || a.Rating == b.Rating && a.ReviewCount > b.ReviewCount
+		}
+	case "recommended":
+		less = func(a, b *models.Product) bool {
+			ra, rb := memoryRank(a, q.Affinity), memoryRank(b, q.Affinity)
+			return ra > rb || ra == rb && a.CreatedAt.After(b.CreatedAt)
+		}
+	case "relevance":
+		position := make(map[string]int, len(q.IDs))
+		for i, id := range q.IDs {
+			if _, seen := position[id]; !seen {
+				position[id] = i
+			}
+		}
+		less = func(a, b *models.Product) bool { return position[a.ID] < position[b.ID] }
+	default:
+		less = func(a, b *models.Product) bool { return false }
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if less(out[i], out[j]) {
+			return true
+		}
+		if less(out[j], out[i]) {
+			return false
+		}
+		return out[i].ID < out[j].ID
+	})
+}
+
+// memoryRank is the product's recommended-sort rank for a shopper with the
+// given affinity, which may be nil.
+func memoryRank(p *models.Product, a *models.RankAffinity) float64 {
+	rank := p.RankScore
+	if a == nil {
+		return rank
+	}
+	category := p.CategoryID
+	if category == "" {
+		category = strings.TrimSpace(p.Category)
+	}
+	if a.Category != "" && strings.EqualFold(category, a.Category) {
+		rank += a.Boost
+	}
+	if a.Gender != "" && strings.EqualFold(p.Gender, a.Gender) {
+		rank += a.Boost
+	}
+	return rank
+}
+
+func containsFold(values []string, v string) bool {
+	for _, candidate := range values {
+		if strings.EqualFold(candidate, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func anyFold(wanted, have []string) bool {
+	for _, h := range have {
+		if containsFold(wanted, h) {
+			return true
+		}
+	}
+	return false
+}
+
+func productStock(p *models.Product) int {
+	if len(p.Variants) == 0 {
+		return totalStock(p.StockBySize)
+	}
+	total := 0
+	for _, v := range p.Variants {
+		total += v.Stock
+	}
+	return total
+}
+
+func totalStock(stock map[string]int) int {
+	total := 0
+	for _, n := range stock {
+		total += n
+	}
+	return total
+}
+
+type facetCounter struct {
+	count   map[string]int
+	display map[string]string
+}
+
+func newFacetCounter() *facetCounter {
+	return &facetCounter{count: make(map[string]int), display: make(map[string]string)}
+}
+
+func (f *facetCounter) add(value string) {
+	name := strings.TrimSpace(value)
+	if name == "" {
+		return
+	}
+	key := strings.ToLower(name)
+	f.count[key]++
+	if _, ok := f.display[key]; !ok {
+		f.display[key] = name
+	}
+}
+
+func (f *facetCounter) counts() []models.FacetCount {
+	out := make([]models.FacetCount, 0, len(f.count))
+	for key, n := range f.count {
+		out = append(out, models.FacetCount{Name: f.display[key], Count: n})
+	}
+	sortFacets(out)
+	return out
+}
+
+// currentPrice is the stored selling price, or the list price for a product
+// written before it was kept.
+func currentPrice(p *models.Product) float64 {
+	if p.CurrentPrice > 0 {
+		return p.CurrentPrice
+	}
+	return p.Price
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"sync"
 	"time"
 
@@ -14,12 +15,44 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+var (
+	ErrInsufficientStock = errors.New("insufficient stock")
+	ErrVariantNotFound   = errors.New("variant not found")
+	ErrDuplicateSKU      = errors.New("duplicate product sku")
+	ErrVersionConflict   = errors.New("product was changed by someone else")
+)
+
 type ProductStore interface {
 	FindAll(ctx context.Context) ([]*models.Product, error)
 	FindByID(ctx context.Context, id string) (*models.Product, error)
 	Insert(ctx context.Context, p *models.Product) (*models.Product, error)
+	// Update replaces the product if its stored version still equals
+	// p.Version, failing with ErrVersionConflict otherwise. On success
+	// p.Version is the new version.
 	Update(ctx context.Context, id string, p *models.Product) error
 	Delete(ctx context.Context, id string) error
+	// DecrementStock removes qty units of a variant, failing with
+	// ErrInsufficientStock instead of going negative.
+	DecrementStock(ctx context.Context, id, variantID string, qty int) error
+	IncrementStock(ctx context.Context, id, variantID string, qty int) error
+	// SetRating stores the average rating and count of the approved reviews.
+	// Like SetCurrentPrice it leaves the version alone, and Update keeps the
+	// stored rating.
+	SetRating(ctx context.Context, id string, rating float64, count int) error
+	// SetCurrentPrice stores the selling price queries filter and sort on.
+	// It leaves the version alone: no field an editor sets changes.
+	SetCurrentPrice(ctx context.Context, id string, price float64) error
+	// SetRankScore stores the product's recommended-sort score. Like
+	// SetRating it leaves the version alone, and Update keeps the stored
+	// score.
+	SetRankScore(ctx context.Context, id string, score float64) error
+	// Query returns one page of products matching q; Cursor and Limit on q
+	// are ignored in favour of offset and limit.
+	Query(ctx context.Context, q *models.ProductQuery, offset, limit int) ([]*models.Product, error)
+	Count(ctx context.Context, q *models.ProductQuery) (int64, error)
+	// Query, Count and Facets leave out archived products.
+	// Facets counts products per category and color across the whole catalog.
+	Facets(ctx context.Context) (*models.ProductFacets, error)
 }
 
 type ProductRepositoryMongo struct {
@@ -72,9 +105,13 @@ func (r *ProductRepositoryMongo) Insert(ctx context.Context, p *models.Product)
 		p.UpdatedAt = now
 	}
 	id := primitive.NewObjectID()
+	p.Version = 1
 	doc := productDocFromModel(p)
 	doc.ID = id
 	_, err := r.coll.InsertOne(ctx, doc)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, ErrDuplicateSKU
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -90,8 +127,31 @@ func (r *ProductRepositoryMongo) Update(ctx context.Context, id string, p *model
 	p.UpdatedAt = time.Now()
 	doc := productDocFromModel(p)
 	doc.ID = oid
-	_, err = r.coll.ReplaceOne(ctx, bson.M{"_id": oid}, doc)
-	return err
+	doc.Version = p.Version + 1
+	filter := bson.M{"_id": oid, "version": p.Version}
+	if p.Version == 0 {
+		// Products written before versioning have no version field.
+		filter["version"] = bson.M{"$in": bson.A{0, nil}}
+	}
+	// The review and ranking services keep the rating and rank score up to
+	// date without bumping the version, so the stored values win over the
+	// ones read with p.
+	update := mongo.Pipeline{{{"$replaceWith", bson.D{{"$mergeObjects", bson.A{
+		bson.D{{"$literal", doc}},
+		bson.D{{"rating", "$rating"}, {"reviewCount", "$reviewCount"}, {"rankScore", "$rankScore"}},
+	}}}}}}
+	res, err := r.coll.UpdateOne(ctx, filter, update)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicateSKU
+	}
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrVersionConflict
+	}
+	p.Version = doc.Version
+	return nil
 }
 
 func (r *ProductRepositoryMongo) Delete(ctx context.Context, id string) error {
@@ -103,34 +163,141 @@ func (r *ProductRepositoryMongo) Delete(ctx context.Context, id string) error {
 	return err
 }
 
+func (r *ProductRepositoryMongo) DecrementStock(ctx context.Context, id, variantID string, qty int) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	res, err := r.coll.UpdateOne(ctx,
+		bson.M{"_id": oid, "variants": bson.M{"$elemMatch": bson.M{"id": variantID, "stock": bson.M{"$gte": qty}}}},
+		bson.M{"$inc": bson.M{"variants.$.stock": -qty, "totalStock": -qty, "version": 1}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrInsufficientStock
+	}
+	return nil
+}
+
+func (r *ProductRepositoryMongo) IncrementStock(ctx context.Context, id, variantID string, qty int) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	res, err := r.coll.UpdateOne(ctx,
+		bson.M{"_id": oid, "variants.id": variantID},
+		bson.M{"$inc": bson.M{"variants.$.stock": qty, "totalStock": qty, "version": 1}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrVariantNotFound
+	}
+	return nil
+}
+
+func (r *ProductRepositoryMongo) SetRating(ctx context.Context, id string, rating float64, count int) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	_, err = r.coll.UpdateOne(ctx,
+		bson.M{"_id": oid},
+		bson.M{"$set": bson.M{"rating": rating, "reviewCount": count}},
+	)
+	return err
+}
+
+func (r *ProductRepositoryMongo) SetCurrentPrice(ctx context.Context, id string, price float64) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	_, err = r.coll.UpdateOne(ctx, bson.M{"_id": oid}, bson.M{"$set": bson.M{"currentPrice": price}})
+	return err
+}
+
+func (r *ProductRepositoryMongo) SetRankScore(ctx context.Context, id string, score float64) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	_, err = r.coll.UpdateOne(ctx, bson.M{"_id": oid}, bson.M{"$set": bson.M{"rankScore": score}})
+	return err
+}
+
 type productDoc struct {
-	ID          primitive.ObjectID `bson:"_id,omitempty"`
-	Name        string             `bson:"name"`
-	Description string             `bson:"description"`
-	Category    string             `bson:"category"`
-	Gender      string             `bson:"gender"`
-	Price       float64            `bson:"price"`
-	Sizes       []string           `bson:"sizes"`
-	Colors      []string           `bson:"colors"`
-	StockBySize map[string]int     `bson:"stockBySize"`
-	Images      []string           `bson:"images"`
-	IsActive    bool               `bson:"isActive"`
-	CreatedAt   primitive.DateTime `bson:"createdAt"`
-	UpdatedAt   primitive.DateTime `bson:"updateAt"`
+	ID          primitive.ObjectID    `bson:"_id,omitempty"`
+	SKU         string                `bson:"sku,omitempty"`
+	Name        string                `bson:"name"`
+	Description string                `bson:"description"`
+	Category    string                `bson:"category"`
+	CategoryID  string                `bson:"categoryId,omitempty"`
+	Gender      string                `bson:"gender"`
+	Price       float64               `bson:"price"`
+	CurPrice    float64               `bson:"currentPrice"`
+	Sizes       []string              `bson:"sizes"`
+	Colors      []string              `bson:"colors"`
+	StockBySize map[string]int        `bson:"stockBySize,omitempty"`
+	TotalStock  int                   `bson:"totalStock"`
+	Images      []string              `bson:"images"`
+	Media       []models.ProductImage `bson:"media,omitempty"`
+	Variants    []variantDoc          `bson:"variants,omitempty"`
+	LowStock    int                   `bson:"lowStockThreshold,omitempty"`
+	IsActive    bool                  `bson:"isActive"`
+	Version     int64                 `bson:"version"`
+	CreatedAt   primitive.DateTime    `bson:"createdAt"`
+	UpdatedAt   primitive.DateTime    `bson:"updateAt"`
+	DeletedAt   *primitive.DateTime   `bson:"deletedAt,omitempty"`
+	SalePrice   *float64              `bson:"salePrice,omitempty"`
+	SaleStarts  *primitive.DateTime   `bson:"saleStartsAt,omitempty"`
+	SaleEnds    *primitive.DateTime   `bson:"saleEndsAt,omitempty"`
+	CompareAt   *float64              `bson:"compareAtPrice,omitempty"`
+	Rating      float64               `bson:"rating,omitempty"`
+	ReviewCount int                   `bson:"reviewCount,omitempty"`
+	RankScore   float64               `bson:"rankScore,omitempty"`
 }
 
 func productDocFromModel(p *models.Product) *productDoc {
 	d := &productDoc{
+		SKU:         p.SKU,
 		Name:        p.Name,
 		Description: p.Description,
 		Category:    p.Category,
+		CategoryID:  p.CategoryID,
 		Gender:      p.Gender,
 		Price:       p.Price,
+		CurPrice:    currentPrice(p),
 		Sizes:       p.Sizes,
 		Colors:      p.Colors,
-		StockBySize: p.StockBySize,
+		TotalStock:  totalStock(p.StockBySize),
 		Images:      p.Images,
+		Media:       p.Media,
+		LowStock:    p.LowStockThreshold,
 		IsActive:    p.IsActive,
+		Version:     p.Version,
+		DeletedAt:   dateTimePtr(p.DeletedAt),
+		SalePrice:   p.SalePrice,
+		SaleStarts:  dateTimePtr(p.SaleStartsAt),
+		SaleEnds:    dateTimePtr(p.SaleEndsAt),
+		CompareAt:   p.CompareAtPrice,
+		Rating:      p.Rating,
+		ReviewCount: p.ReviewCount,
+		RankScore:   p.RankScore,
+	}
+	// Variant stock is the source of truth; stockBySize is only kept for
+	// products that predate variants.
+	if len(p.Variants) == 0 {
+		d.StockBySize = p.StockBySize
+	} else {
+		d.TotalStock = 0
+	}
+	for _, v := range p.Variants {
+		d.Variants = append(d.Variants, variantDoc(v))
+		d.TotalStock += v.Stock
 	}
 	if !p.CreatedAt.IsZero() {
 		d.CreatedAt = primitive.NewDateTimeFromTime(p.CreatedAt)
@@ -141,22 +308,82 @@ func productDocFromModel(p *models.Product) *productDoc {
 	return d
 }
 
+func dateTimePtr(t *time.Time) *primitive.DateTime {
+	if t == nil {
+		return nil
+	}
+	dt := primitive.NewDateTimeFromTime(*t)
+	return &dt
+}
+
+func timePtr(dt *primitive.DateTime) *time.Time {
+	if dt == nil {
+		return nil
+	}
+	t := dt.Time()
+	return &t
+}
+
+type variantDoc struct {
+	ID      string   `bson:"id"`
+	SKU     string   `bson:"sku"`
+	Size    string   `bson:"size"`
+	Color   string   `bson:"color"`
+	Price   *float64 `bson:"price,omitempty"`
+	Stock   int      `bson:"stock"`
+	Barcode string   `bson:"barcode,omitempty"`
+	Images  []string `bson:"images,omitempty"`
+}
+
 func (d *productDoc) toModel() *models.Product {
-	return &models.Product{
-		ID:          d.ID.Hex(),
-		Name:        d.Name,
-		Description: d.Description,
-		Category:    d.Category,
-		Gender:      d.Gender,
-		Price:       d.Price,
-		Sizes:       d.Sizes,
-		Colors:      d.Colors,
-		StockBySize: d.StockBySize,
-		Images:      d.Images,
-		IsActive:    d.IsActive,
-		CreatedAt:   d.CreatedAt.Time(),
-		UpdatedAt:   d.UpdatedAt.Time(),
+	p := &models.Product{
+		ID:                d.ID.Hex(),
+		SKU:               d.SKU,
+		Name:              d.Name,
+		Description:       d.Description,
+		Category:          d.Category,
+		CategoryID:        d.CategoryID,
+		Gender:            d.Gender,
+		Price:             d.Price,
+		CurrentPrice:      d.CurPrice,
+		Sizes:             d.Sizes,
+		Colors:            d.Colors,
+		StockBySize:       d.StockBySize,
+		Images:            d.Images,
+		Media:             d.Media,
+		IsActive:          d.IsActive,
+		Version:           d.Version,
+		CreatedAt:         d.CreatedAt.Time(),
+		UpdatedAt:         d.UpdatedAt.Time(),
+		LowStockThreshold: d.LowStock,
+		DeletedAt:         timePtr(d.DeletedAt),
+		SalePrice:         d.SalePrice,
+		SaleStartsAt:      timePtr(d.SaleStarts),
+		SaleEndsAt:        timePtr(d.SaleEnds),
+		CompareAtPrice:    d.CompareAt,
+		Rating:            d.Rating,
+		ReviewCount:       d.ReviewCount,
+		RankScore:         d.RankScore,
+	}
+	if len(d.Variants) > 0 {
+		p.Variants = make([]models.ProductVariant, 0, len(d.Variants))
+		for _, v := range d.Variants {
+			p.Variants = append(p.Variants, models.ProductVariant(v))
+		}
+		p.StockBySize = StockBySize(p.Variants)
+	}
+	return p
+}
+
+// StockBySize sums variant stock per size.
+func StockBySize(variants []models.ProductVariant) map[string]int {
+	out := make(map[string]int)
+	for _, v := range variants {
+		if v.Size != "" {
+			out[v.Size] += v.Stock
+		}
 	}
+	return out
 }
 
 type ProductRepositoryMemory struct {
@@ -173,7 +400,7 @@ func (r *ProductRepositoryMemory) FindAll(ctx context.Context) ([]*models.Produc
 	defer r.mu.RUnlock()
 	out := make([]*models.Product, 0, len(r.data))
 	for _, p := range r.data {
-		out = append(out, p)
+		out = append(out, copyProduct(p))
 	}
 	return out, nil
 }
@@ -181,12 +408,19 @@ func (r *ProductRepositoryMemory) FindAll(ctx context.Context) ([]*models.Produc
 func (r *ProductRepositoryMemory) FindByID(ctx context.Context, id string) (*models.Product, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	return r.data[id], nil
+	p, ok := r.data[id]
+	if !ok {
+		return nil, nil
+	}
+	return copyProduct(p), nil
 }
 
 func (r *ProductRepositoryMemory) Insert(ctx context.Context, p *models.Product) (*models.Product, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	if r.skuTaken(p.SKU, p.ID) {
+		return nil, ErrDuplicateSKU
+	}
 	if p.ID == "" {
 		b := make([]byte, 8)
 		if _, err := rand.Read(b); err == nil {
@@ -201,19 +435,27 @@ func (r *ProductRepositoryMemory) Insert(ctx context.Context, p *models.Product)
 	if p.UpdatedAt.IsZero() {
 		p.UpdatedAt = time.Now()
 	}
-	r.data[p.ID] = p
+	p.Version = 1
+	r.data[p.ID] = copyProduct(p)
 	return p, nil
 }
 
 func (r *ProductRepositoryMemory) Update(ctx context.Context, id string, p *models.Product) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	if _, ok := r.data[id]; !ok {
-		return nil
+	current, ok := r.data[id]
+	if !ok || current.Version != p.Version {
+		return ErrVersionConflict
+	}
+	if r.skuTaken(p.SKU, id) {
+		return ErrDuplicateSKU
 	}
 	p.ID = id
 	p.UpdatedAt = time.Now()
-	r.data[id] = p
+	p.Version++
+	p.Rating, p.ReviewCount = current.Rating, current.ReviewCount
+	p.RankScore = current.RankScore
+	r.data[id] = copyProduct(p)
 	return nil
 }
 
@@ -223,3 +465,138 @@ func (r *ProductRepositoryMemory) Delete(ctx context.Context, id string) error {
 	delete(r.data, id)
 	return nil
 }
+
+func (r *ProductRepositoryMemory) DecrementStock(ctx context.Context, id, variantID string, qty int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, v := r.variant(id, variantID)
+	if v == nil || v.Stock < qty {
+		return ErrInsufficientStock
+	}
+	v.Stock -= qty
+	p.StockBySize = StockBySize(p.Variants)
+	p.Version++
+	return nil
+}
+
+func (r *ProductRepositoryMemory) IncrementStock(ctx context.Context, id, variantID string, qty int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, v := r.variant(id, variantID)
+	if v == nil {
+		return ErrVariantNotFound
+	}
+	v.Stock += qty
+	p.StockBySize = StockBySize(p.Variants)
+	p.Version++
+	return nil
+}
+
+func (r *ProductRepositoryMemory) SetRating(ctx context.Context, id string, rating float64, count int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if p, ok := r.data[id]; ok {
+		p.Rating, p.ReviewCount = rating, count
+	}
+	return nil
+}
+
+func (r *ProductRepositoryMemory) SetCurrentPrice(ctx context.Context, id string, price float64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if p, ok := r.data[id]; ok {
+		p.CurrentPrice = price
+	}
+	return nil
+}
+
+func (r *ProductRepositoryMemory) SetRankScore(ctx context.Context, id string, score float64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if p, ok := r.data[id]; ok {
+		p.RankScore = score
+	}
+	return nil
+}
+
+// copyProduct keeps callers from editing stored products in place: a
+// rejected update must leave the store untouched.
+func copyProduct(p *models.Product) *models.Product {
+	out := *p
+	out.SalePrice = copyFloat(p.SalePrice)
+	out.SaleStartsAt = copyTime(p.SaleStartsAt)
+	out.SaleEndsAt = copyTime(p.SaleEndsAt)
+	out.CompareAtPrice = copyFloat(p.CompareAtPrice)
+	out.DeletedAt = copyTime(p.DeletedAt)
+	out.Sizes = copyStrings(p.Sizes)
+	out.Colors = copyStrings(p.Colors)
+	out.Images = copyStrings(p.Images)
+	if p.StockBySize != nil {
+		out.StockBySize = make(map[string]int, len(p.StockBySize))
+		for size, n := range p.StockBySize {
+			out.StockBySize[size] = n
+		}
+	}
+	if p.Media != nil {
+		out.Media = append([]models.ProductImage{}, p.Media...)
+	}
+	if p.Variants != nil {
+		out.Variants = make([]models.ProductVariant, len(p.Variants))
+		for i, v := range p.Variants {
+			v.Price = copyFloat(v.Price)
+			v.Images = copyStrings(v.Images)
+			out.Variants[i] = v
+		}
+	}
+	// Pricing is resolved on read and never stored.
+	out.Pricing = nil
+	return &out
+}
+
+func copyStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	return append([]string{}, s...)
+}
+
+func copyFloat(f *float64) *float64 {
+	if f == nil {
+		return nil
+	}
+	v := *f
+	return &v
+}
+
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	v := *t
+	return &v
+}
+
+func (r *ProductRepositoryMemory) skuTaken(sku, id string) bool {
+	if sku == "" {
+		return false
+	}
+	for otherID, p := range r.data {
+		if otherID != id && p.SKU == sku {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *ProductRepositoryMemory) variant(id, variantID string) (*models.Product, *models.ProductVariant) {
+	p, ok := r.data[id]
+	if !ok {
+		return nil, nil
+	}
+	for i := range p.Variants {
+		if p.Variants[i].ID == variantID {
+			return p, &p.Variants[i]
+		}
+	}
+	return p, nil
+}
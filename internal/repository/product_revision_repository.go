@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ProductRevisionStore keeps the edit history of products. Like the stock
+// ledger it is append-only.
+type ProductRevisionStore interface {
+	Append(ctx context.Context, rev *models.ProductRevision) error
+	// FindByProduct returns the newest revisions of a product first.
+	FindByProduct(ctx context.Context, productID string, limit int) ([]*models.ProductRevision, error)
+	// FindVersion returns nil when the product has no revision for version.
+	FindVersion(ctx context.Context, productID string, version int64) (*models.ProductRevision, error)
+}
+
+type ProductRevisionRepositoryMongo struct {
+	coll *mongo.Collection
+}
+
+func NewProductRevisionRepositoryMongo(coll *mongo.Collection) *ProductRevisionRepositoryMongo {
+	return &ProductRevisionRepositoryMongo{coll: coll}
+}
+
+func (r *ProductRevisionRepositoryMongo) Append(ctx context.Context, rev *models.ProductRevision) error {
+	if rev.CreatedAt.IsZero() {
+		rev.CreatedAt = time.Now()
+	}
+	id := primitive.NewObjectID()
+	doc := productRevisionDoc{
+		ID:         id,
+		ProductID:  rev.ProductID,
+		Version:    rev.Version,
+		Action:     rev.Action,
+		Author:     rev.Author,
+		RollbackOf: rev.RollbackOf,
+		Changes:    rev.Changes,
+		Snapshot:   rev.Snapshot,
+		CreatedAt:  primitive.NewDateTimeFromTime(rev.CreatedAt),
+	}
+	if _, err := r.coll.InsertOne(ctx, doc); err != nil {
+		return err
+	}
+	rev.ID = id.Hex()
+	return nil
+}
+
+func (r *ProductRevisionRepositoryMongo) FindByProduct(ctx context.Context, productID string, limit int) ([]*models.ProductRevision, error) {
+	opts := options.Find().SetSort(bson.D{{"version", -1}})
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+	cur, err := r.coll.Find(ctx, bson.M{"productId": productID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	var out []*models.ProductRevision
+	for cur.Next(ctx) {
+		var doc productRevisionDoc
+		if err := cur.Decode(&doc); err != nil {
+			return nil, err
+		}
+		out = append(out, doc.toModel())
+	}
+	return out, cur.Err()
+}
+
+func (r *ProductRevisionRepositoryMongo) FindVersion(ctx context.Context, productID string, version int64) (*models.ProductRevision, error) {
+	var doc productRevisionDoc
+	err := r.coll.FindOne(ctx, bson.M{"productId": productID, "version": version}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return doc.toModel(), nil
+}
+
+type productRevisionDoc struct {
+	ID         primitive.ObjectID   `bson:"_id,omitempty"`
+	ProductID  string               `bson:"productId"`
+	Version    int64                `bson:"version"`
+	Action     string               `bson:"action"`
+	Author     string               `bson:"author,omitempty"`
+	RollbackOf int64                `bson:"rollbackOf,omitempty"`
+	Changes    []models.FieldChange `bson:"changes"`
+	Snapshot   models.Product       `bson:"snapshot"`
+	CreatedAt  primitive.DateTime   `bson:"createdAt"`
+}
+
+func (d *productRevisionDoc) toModel() *models.ProductRevision {
+	return &models.ProductRevision{
+		ID:         d.ID.Hex(),
+		ProductID:  d.ProductID,
+		Version:    d.Version,
+		Action:     d.Action,
+		Author:     d.Author,
+		RollbackOf: d.RollbackOf,
+		Changes:    d.Changes,
+		Snapshot:   d.Snapshot,
+		CreatedAt:  d.CreatedAt.Time(),
+	}
+}
+
+type ProductRevisionRepositoryMemory struct {
+	mu   sync.RWMutex
+	data []*models.ProductRevision
+}
+
+func NewProductRevisionRepositoryMemory() *ProductRevisionRepositoryMemory {
+	return &ProductRevisionRepositoryMemory{}
+}
+
+func (r *ProductRevisionRepositoryMemory) Append(ctx context.Context, rev *models.ProductRevision) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if rev.ID == "" {
+		rev.ID = primitive.NewObjectID().Hex()
+	}
+	if rev.CreatedAt.IsZero() {
+		rev.CreatedAt = time.Now()
+	}
+	cp := *rev
+	r.data = append(r.data, &cp)
+	return nil
+}
+
+func (r *ProductRevisionRepositoryMemory) FindByProduct(ctx context.Context, productID string, limit int) ([]*models.ProductRevision, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var out []*models.ProductRevision
+	for _, rev := range r.data {
+		if rev.ProductID == productID {
+			cp := *rev
+			out = append(out, &cp)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version > out[j].Version })
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func (r *ProductRevisionRepositoryMemory) FindVersion(ctx context.Context, productID string, version int64) (*models.ProductRevision, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, rev := range r.data {
+		if rev.ProductID == productID && rev.Version == version {
+			cp := *rev
+			return &cp, nil
+		}
+	}
+	return nil, nil
+}
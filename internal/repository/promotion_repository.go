@@ -0,0 +1,407 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrDuplicatePromoCode = errors.New("promotion code already exists")
+	ErrPromotionUsedUp    = errors.New("promotion usage limit reached")
+)
+
+type PromotionStore interface {
+	// FindAll returns every promotion, newest first.
+	FindAll(ctx context.Context) ([]*models.Promotion, error)
+	// FindByID and FindByCode return nil when there is no such promotion.
+	FindByID(ctx context.Context, id string) (*models.Promotion, error)
+	FindByCode(ctx context.Context, code string) (*models.Promotion, error)
+	// Insert and Update fail with ErrDuplicatePromoCode when the code is
+	// taken. Update leaves UsedCount and CreatedAt as stored.
+	Insert(ctx context.Context, p *models.Promotion) error
+	Update(ctx context.Context, p *models.Promotion) error
+	Delete(ctx context.Context, id string) error
+	// Redeem counts one use of p by the user, failing with
+	// ErrPromotionUsedUp instead of going over either usage limit.
+	Redeem(ctx context.Context, p *models.Promotion, userID string) error
+	// Release gives back a use counted by Redeem.
+	Release(ctx context.Context, promotionID, userID string) error
+	// Uses returns how many uses of the promotion the user holds.
+	Uses(ctx context.Context, promotionID, userID string) (int, error)
+}
+
+type PromotionRepositoryMongo struct {
+	coll        *mongo.Collection
+	redemptions *mongo.Collection
+}
+
+func NewPromotionRepositoryMongo(coll, redemptions *mongo.Collection) *PromotionRepositoryMongo {
+	return &PromotionRepositoryMongo{coll: coll, redemptions: redemptions}
+}
+
+func (r *PromotionRepositoryMongo) FindAll(ctx context.Context) ([]*models.Promotion, error) {
+	cur, err := r.coll.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{"createdAt", -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	out := []*models.Promotion{}
+	for cur.Next(ctx) {
+		var doc promotionDoc
+		if err := cur.Decode(&doc); err != nil {
+			return nil, err
+		}
+		out = append(out, doc.toModel())
+	}
+	return out, cur.Err()
+}
+
+func (r *PromotionRepositoryMongo) FindByID(ctx context.Context, id string) (*models.Promotion, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, nil
+	}
+	return r.findOne(ctx, bson.M{"_id": oid})
+}
+
+func (r *PromotionRepositoryMongo) FindByCode(ctx context.Context, code string) (*models.Promotion, error) {
+	return r.findOne(ctx, bson.M{"code": code})
+}
+
+func (r *PromotionRepositoryMongo) findOne(ctx context.Context, filter bson.M) (*models.Promotion, error) {
+	var doc promotionDoc
+	err := r.coll.FindOne(ctx, filter).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return doc.toModel(), nil
+}
+
+func (r *PromotionRepositoryMongo) Insert(ctx context.Context, p *models.Promotion) error {
+	doc := promotionDocFromModel(p)
+	doc.ID = primitive.NewObjectID()
+	if _, err := r.coll.InsertOne(ctx, doc); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrDuplicatePromoCode
+		}
+		return err
+	}
+	p.ID = doc.ID.Hex()
+	return nil
+}
+
+func (r *PromotionRepositoryMongo) Update(ctx context.Context, p *models.Promotion) error {
+	oid, err := primitive.ObjectIDFromHex(p.ID)
+	if err != nil {
+		return err
+	}
+	doc := promotionDocFromModel(p)
+	set := bson.M{
+		"code":         doc.Code,
+		"description":  doc.Description,
+		"type":         doc.Type,
+		"value":        doc.Value,
+		"buyQuantity":  doc.BuyQuantity,
+		"getQuantity":  doc.GetQuantity,
+		"minSubtotal":  doc.MinSubtotal,
+		"categoryIds":  doc.CategoryIDs,
+		"productIds":   doc.ProductIDs,
+		"usageLimit":   doc.UsageLimit,
+		"perUserLimit": doc.PerUserLimit,
+		"startsAt":     doc.StartsAt,
+		"endsAt":       doc.EndsAt,
+		"active":       doc.Active,
+		"updatedAt":    doc.UpdatedAt,
+	}
+	if _, err := r.coll.UpdateOne(ctx, bson.M{"_id": oid}, bson.M{"$set": set}); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrDuplicatePromoCode
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *PromotionRepositoryMongo) Delete(ctx context.Context, id string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	if _, err := r.coll.DeleteOne(ctx, bson.M{"_id": oid}); err != nil {
+		return err
+	}
+	_, err = r.redemptions.DeleteMany(ctx, bson.M{"promotionId": id})
+	return err
+}
+
+func (r *PromotionRepositoryMongo) Redeem(ctx context.Context, p *models.Promotion, userID string) error {
+	oid, err := primitive.ObjectIDFromHex(p.ID)
+	if err != nil {
+		return err
+	}
+	// The user's counter goes first: a failure there leaves nothing to undo.
+	// The unique (promotionId, userId) index turns the upsert into a
+	// duplicate key error once the counter is at the limit.
+	userFilter := bson.M{"promotionId": p.ID, "userId": userID}
+	if p.PerUserLimit > 0 {
+		userFilter["count"] = bson.M{"$lt": p.PerUserLimit}
+	}
+	_, err = r.redemptions.UpdateOne(ctx, userFilter, bson.M{"$inc": bson.M{"count": 1}}, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrPromotionUsedUp
+	}
+	if err != nil {
+		return err
+	}
+	filter := bson.M{"_id": oid}
+	if p.UsageLimit > 0 {
+		filter["usedCount"] = bson.M{"$lt": p.UsageLimit}
+	}
+	res, err := r.coll.UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"usedCount": 1}})
+	if err == nil && res.MatchedCount > 0 {
+		return nil
+	}
+	if undoErr := r.releaseUser(ctx, p.ID, userID); undoErr != nil {
+		return errors.Join(err, undoErr)
+	}
+	if err != nil {
+		return err
+	}
+	return ErrPromotionUsedUp
+}
+
+func (r *PromotionRepositoryMongo) Release(ctx context.Context, promotionID, userID string) error {
+	oid, err := primitive.ObjectIDFromHex(promotionID)
+	if err != nil {
+		return err
+	}
+	if _, err := r.coll.UpdateOne(ctx, bson.M{"_id": oid, "usedCount": bson.M{"$gt": 0}}, bson.M{"$inc": bson.M{"usedCount": -1}}); err != nil {
+		return err
+	}
+	return r.releaseUser(ctx, promotionID, userID)
+}
+
+func (r *PromotionRepositoryMongo) releaseUser(ctx context.Context, promotionID, userID string) error {
+	_, err := r.redemptions.UpdateOne(ctx,
+		bson.M{"promotionId": promotionID, "userId": userID, "count": bson.M{"$gt": 0}},
+		bson.M{"$inc": bson.M{"count": -1}})
+	return err
+}
+
+func (r *PromotionRepositoryMongo) Uses(ctx context.Context, promotionID, userID string) (int, error) {
+	var doc struct {
+		Count int `bson:"count"`
+	}
+	err := r.redemptions.FindOne(ctx, bson.M{"promotionId": promotionID, "userId": userID}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, nil
+	}
+	return doc.Count, err
+}
+
+type promotionDoc struct {
+	ID           primitive.ObjectID  `bson:"_id,omitempty"`
+	Code         string              `bson:"code"`
+	Description  string              `bson:"description,omitempty"`
+	Type         string              `bson:"type"`
+	Value        float64             `bson:"value,omitempty"`
+	BuyQuantity  int                 `bson:"buyQuantity,omitempty"`
+	GetQuantity  int                 `bson:"getQuantity,omitempty"`
+	MinSubtotal  float64             `bson:"minSubtotal,omitempty"`
+	CategoryIDs  []string            `bson:"categoryIds,omitempty"`
+	ProductIDs   []string            `bson:"productIds,omitempty"`
+	UsageLimit   int                 `bson:"usageLimit,omitempty"`
+	PerUserLimit int                 `bson:"perUserLimit,omitempty"`
+	UsedCount    int                 `bson:"usedCount"`
+	StartsAt     *primitive.DateTime `bson:"startsAt,omitempty"`
+	EndsAt       *primitive.DateTime `bson:"endsAt,omitempty"`
+	Active       bool                `bson:"active"`
+	CreatedAt    primitive.DateTime  `bson:"createdAt"`
+	UpdatedAt    primitive.DateTime  `bson:"updatedAt"`
+}
+
+func promotionDocFromModel(p *models.Promotion) *promotionDoc {
+	return &promotionDoc{
+		Code:         p.Code,
+		Description:  p.Description,
+		Type:         p.Type,
+		Value:        p.Value,
+		BuyQuantity:  p.BuyQuantity,
+		GetQuantity:  p.GetQuantity,
+		MinSubtotal:  p.MinSubtotal,
+		CategoryIDs:  p.CategoryIDs,
+		ProductIDs:   p.ProductIDs,
+		UsageLimit:   p.UsageLimit,
+		PerUserLimit: p.PerUserLimit,
+		UsedCount:    p.UsedCount,
+		StartsAt:     dateTimePtr(p.StartsAt),
+		EndsAt:       dateTimePtr(p.EndsAt),
+		Active:       p.Active,
+		CreatedAt:    primitive.NewDateTimeFromTime(p.CreatedAt),
+		UpdatedAt:    primitive.NewDateTimeFromTime(p.UpdatedAt),
+	}
+}
+
+func (d *promotionDoc) toModel() *models.Promotion {
+	return &models.Promotion{
+		ID:           d.ID.Hex(),
+		Code:         d.Code,
+		Description:  d.Description,
+		Type:         d.Type,
+		Value:        d.Value,
+		BuyQuantity:  d.BuyQuantity,
+		GetQuantity:  d.GetQuantity,
+		MinSubtotal:  d.MinSubtotal,
+		CategoryIDs:  d.CategoryIDs,
+		ProductIDs:   d.ProductIDs,
+		UsageLimit:   d.UsageLimit,
+		PerUserLimit: d.PerUserLimit,
+		UsedCount:    d.UsedCount,
+		StartsAt:     timePtr(d.StartsAt),
+		EndsAt:       timePtr(d.EndsAt),
+		Active:       d.Active,
+		CreatedAt:    d.CreatedAt.Time(),
+		UpdatedAt:    d.UpdatedAt.Time(),
+	}
+}
+
+type PromotionRepositoryMemory struct {
+	mu   sync.RWMutex
+	data map[string]*models.Promotion
+	// uses counts redemptions by promotion ID and user ID.
+	uses map[[2]string]int
+}
+
+func NewPromotionRepositoryMemory() *PromotionRepositoryMemory {
+	return &PromotionRepositoryMemory{data: make(map[string]*models.Promotion), uses: make(map[[2]string]int)}
+}
+
+func (r *PromotionRepositoryMemory) FindAll(ctx context.Context) ([]*models.Promotion, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]*models.Promotion, 0, len(r.data))
+	for _, p := range r.data {
+		cp := *p
+		out = append(out, &cp)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out, nil
+}
+
+func (r *PromotionRepositoryMemory) FindByID(ctx context.Context, id string) (*models.Promotion, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if p, ok := r.data[id]; ok {
+		cp := *p
+		return &cp, nil
+	}
+	return nil, nil
+}
+
+func (r *PromotionRepositoryMemory) FindByCode(ctx context.Context, code string) (*models.Promotion, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, p := range r.data {
+		if p.Code == code {
+			cp := *p
+			return &cp, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *PromotionRepositoryMemory) Insert(ctx context.Context, p *models.Promotion) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.codeTaken(p.Code, "") {
+		return ErrDuplicatePromoCode
+	}
+	p.ID = primitive.NewObjectID().Hex()
+	cp := *p
+	r.data[p.ID] = &cp
+	return nil
+}
+
+func (r *PromotionRepositoryMemory) Update(ctx context.Context, p *models.Promotion) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	existing, ok := r.data[p.ID]
+	if !ok {
+		return nil
+	}
+	if r.codeTaken(p.Code, p.ID) {
+		return ErrDuplicatePromoCode
+	}
+	cp := *p
+	cp.UsedCount = existing.UsedCount
+	cp.CreatedAt = existing.CreatedAt
+	r.data[p.ID] = &cp
+	return nil
+}
+
+func (r *PromotionRepositoryMemory) codeTaken(code, exceptID string) bool {
+	for id, p := range r.data {
+		if id != exceptID && p.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *PromotionRepositoryMemory) Delete(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.data, id)
+	for key := range r.uses {
+		if key[0] == id {
+			delete(r.uses, key)
+		}
+	}
+	return nil
+}
+
+func (r *PromotionRepositoryMemory) Redeem(ctx context.Context, p *models.Promotion, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.data[p.ID]
+	if !ok {
+		return ErrPromotionUsedUp
+	}
+	key := [2]string{p.ID, userID}
+	if p.UsageLimit > 0 && stored.UsedCount >= p.UsageLimit || p.PerUserLimit > 0 && r.uses[key] >= p.PerUserLimit {
+		return ErrPromotionUsedUp
+	}
+	stored.UsedCount++
+	r.uses[key]++
+	return nil
+}
+
+func (r *PromotionRepositoryMemory) Release(ctx context.Context, promotionID, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if p, ok := r.data[promotionID]; ok && p.UsedCount > 0 {
+		p.UsedCount--
+	}
+	key := [2]string{promotionID, userID}
+	if r.uses[key] > 0 {
+		r.uses[key]--
+	}
+	return nil
+}
+
+func (r *PromotionRepositoryMemory) Uses(ctx context.Context, promotionID, userID string) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.uses[[2]string{promotionID, userID}], nil
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const rankingWeightsID = "weights"
+
+type RankingStore interface {
+	// Weights returns nil while the weights were never saved.
+	Weights(ctx context.Context) (*models.RankingWeights, error)
+	SaveWeights(ctx context.Context, w *models.RankingWeights) error
+}
+
+type RankingRepositoryMongo struct {
+	coll *mongo.Collection
+}
+
+func NewRankingRepositoryMongo(coll *mongo.Collection) *RankingRepositoryMongo {
+	return &RankingRepositoryMongo{coll: coll}
+}
+
+type rankingDoc struct {
+	ID                    string `bson:"_id"`
+	models.RankingWeights `bson:",inline"`
+}
+
+func (r *RankingRepositoryMongo) Weights(ctx context.Context) (*models.RankingWeights, error) {
+	var doc rankingDoc
+	err := r.coll.FindOne(ctx, bson.M{"_id": rankingWeightsID}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &doc.RankingWeights, nil
+}
+
+func (r *RankingRepositoryMongo) SaveWeights(ctx context.Context, w *models.RankingWeights) error {
+	doc := rankingDoc{ID: rankingWeightsID, RankingWeights: *w}
+	_, err := r.coll.ReplaceOne(ctx, bson.M{"_id": rankingWeightsID}, doc, options.Replace().SetUpsert(true))
+	return err
+}
+
+type RankingRepositoryMemory struct {
+	mu      sync.RWMutex
+	weights *models.RankingWeights
+}
+
+func NewRankingRepositoryMemory() *RankingRepositoryMemory {
+	return &RankingRepositoryMemory{}
+}
+
+func (r *RankingRepositoryMemory) Weights(ctx context.Context) (*models.RankingWeights, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.weights == nil {
+		return nil, nil
+	}
+	cp := *r.weights
+	return &cp, nil
+}
+
+func (r *RankingRepositoryMemory) SaveWeights(ctx context.Context, w *models.RankingWeights) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cp := *w
+	r.weights = &cp
+	return nil
+}
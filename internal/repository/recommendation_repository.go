@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type RecommendationStore interface {
+	// ReplaceAll stores the result of one batch run. Products left out of
+	// recs lose their previous recommendations.
+	ReplaceAll(ctx context.Context, recs []*models.ProductRecommendations, computedAt time.Time) error
+	// FindByProduct returns nil when the product has no recommendations.
+	FindByProduct(ctx context.Context, productID string) (*models.ProductRecommendations, error)
+	FindByProducts(ctx context.Context, productIDs []string) ([]*models.ProductRecommendations, error)
+}
+
+type RecommendationRepositoryMongo struct {
+	coll *mongo.Collection
+}
+
+func NewRecommendationRepositoryMongo(coll *mongo.Collection) *RecommendationRepositoryMongo {
+	return &RecommendationRepositoryMongo{coll: coll}
+}
+
+func (r *RecommendationRepositoryMongo) ReplaceAll(ctx context.Context, recs []*models.ProductRecommendations, computedAt time.Time) error {
+	if len(recs) > 0 {
+		writes := make([]mongo.WriteModel, 0, len(recs))
+		for _, rec := range recs {
+			cp := *rec
+			cp.ComputedAt = computedAt
+			writes = append(writes, mongo.NewReplaceOneModel().
+				SetFilter(bson.M{"_id": rec.ProductID}).
+				SetReplacement(&cp).
+				SetUpsert(true))
+		}
+		if _, err := r.coll.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false)); err != nil {
+			return err
+		}
+	}
+	_, err := r.coll.DeleteMany(ctx, bson.M{"computedAt": bson.M{"$lt": computedAt}})
+	return err
+}
+
+func (r *RecommendationRepositoryMongo) FindByProduct(ctx context.Context, productID string) (*models.ProductRecommendations, error) {
+	var rec models.ProductRecommendations
+	err := r.coll.FindOne(ctx, bson.M{"_id": productID}).Decode(&rec)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+func (r *RecommendationRepositoryMongo) FindByProducts(ctx context.Context, productIDs []string) ([]*models.ProductRecommendations, error) {
+	out := []*models.ProductRecommendations{}
+	if len(productIDs) == 0 {
+		return out, nil
+	}
+	cur, err := r.coll.Find(ctx, bson.M{"_id": bson.M{"$in": productIDs}})
+	if err != nil {
+		return nil, err
+	}
+	if err := cur.All(ctx, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+type RecommendationRepositoryMemory struct {
+	mu   sync.RWMutex
+	data map[string]*models.ProductRecommendations
+}
+
+func NewRecommendationRepositoryMemory() *RecommendationRepositoryMemory {
+	return &RecommendationRepositoryMemory{data: make(map[string]*models.ProductRecommendations)}
+}
+
+func (r *RecommendationRepositoryMemory) ReplaceAll(ctx context.Context, recs []*models.ProductRecommendations, computedAt time.Time) error {
+	data := make(map[string]*models.ProductRecommendations, len(recs))
+	for _, rec := range recs {
+		cp := *rec
+		cp.BoughtTogether = append([]models.CoPurchase(nil), rec.BoughtTogether...)
+		cp.ComputedAt = computedAt
+		data[rec.ProductID] = &cp
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.data = data
+	return nil
+}
+
+func (r *RecommendationRepositoryMemory) FindByProduct(ctx context.Context, productID string) (*models.ProductRecommendations, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if rec, ok := r.data[productID]; ok {
+		cp := *rec
+		return &cp, nil
+	}
+	return nil, nil
+}
+
+func (r *RecommendationRepositoryMemory) FindByProducts(ctx context.Context, productIDs []string) ([]*models.ProductRecommendations, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := []*models.ProductRecommendations{}
+	for _, id := range productIDs {
+		if rec, ok := r.data[id]; ok {
+			cp := *rec
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
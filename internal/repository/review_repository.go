@@ -0,0 +1,264 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrDuplicateReview = errors.New("user has already reviewed this product")
+
+type ReviewStore interface {
+	// Insert fails with ErrDuplicateReview when the user already reviewed
+	// the product.
+	Insert(ctx context.Context, r *models.Review) error
+	Update(ctx context.Context, r *models.Review) error
+	Delete(ctx context.Context, id string) error
+	// FindByID and FindByUserProduct return nil when there is no review.
+	FindByID(ctx context.Context, id string) (*models.Review, error)
+	FindByUserProduct(ctx context.Context, userID, productID string) (*models.Review, error)
+	// FindByProduct returns a product's reviews in status, newest first.
+	FindByProduct(ctx context.Context, productID, status string) ([]*models.Review, error)
+	// FindByStatus returns reviews in status oldest first, the order they
+	// are moderated in.
+	FindByStatus(ctx context.Context, status string, limit int) ([]*models.Review, error)
+}
+
+type ReviewRepositoryMongo struct {
+	coll *mongo.Collection
+}
+
+func NewReviewRepositoryMongo(coll *mongo.Collection) *ReviewRepositoryMongo {
+	return &ReviewRepositoryMongo{coll: coll}
+}
+
+func (r *ReviewRepositoryMongo) Insert(ctx context.Context, rev *models.Review) error {
+	doc := reviewDocFromModel(rev)
+	doc.ID = primitive.NewObjectID()
+	if _, err := r.coll.InsertOne(ctx, doc); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrDuplicateReview
+		}
+		return err
+	}
+	rev.ID = doc.ID.Hex()
+	return nil
+}
+
+func (r *ReviewRepositoryMongo) Update(ctx context.Context, rev *models.Review) error {
+	oid, err := primitive.ObjectIDFromHex(rev.ID)
+	if err != nil {
+		return err
+	}
+	doc := reviewDocFromModel(rev)
+	doc.ID = oid
+	_, err = r.coll.ReplaceOne(ctx, bson.M{"_id": oid}, doc)
+	return err
+}
+
+func (r *ReviewRepositoryMongo) Delete(ctx context.Context, id string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	_, err = r.coll.DeleteOne(ctx, bson.M{"_id": oid})
+	return err
+}
+
+func (r *ReviewRepositoryMongo) FindByID(ctx context.Context, id string) (*models.Review, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, nil
+	}
+	return r.findOne(ctx, bson.M{"_id": oid})
+}
+
+func (r *ReviewRepositoryMongo) FindByUserProduct(ctx context.Context, userID, productID string) (*models.Review, error) {
+	return r.findOne(ctx, bson.M{"userId": userID, "productId": productID})
+}
+
+func (r *ReviewRepositoryMongo) findOne(ctx context.Context, filter bson.M) (*models.Review, error) {
+	var doc reviewDoc
+	err := r.coll.FindOne(ctx, filter).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return doc.toModel(), nil
+}
+
+func (r *ReviewRepositoryMongo) FindByProduct(ctx context.Context, productID, status string) ([]*models.Review, error) {
+	return r.find(ctx, bson.M{"productId": productID, "status": status}, options.Find().SetSort(bson.D{{"createdAt", -1}}))
+}
+
+func (r *ReviewRepositoryMongo) FindByStatus(ctx context.Context, status string, limit int) ([]*models.Review, error) {
+	opts := options.Find().SetSort(bson.D{{"createdAt", 1}})
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+	return r.find(ctx, bson.M{"status": status}, opts)
+}
+
+func (r *ReviewRepositoryMongo) find(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]*models.Review, error) {
+	cur, err := r.coll.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	out := []*models.Review{}
+	for cur.Next(ctx) {
+		var doc reviewDoc
+		if err := cur.Decode(&doc); err != nil {
+			return nil, err
+		}
+		out = append(out, doc.toModel())
+	}
+	return out, cur.Err()
+}
+
+type reviewDoc struct {
+	ID             primitive.ObjectID  `bson:"_id,omitempty"`
+	ProductID      string              `bson:"productId"`
+	UserID         string              `bson:"userId"`
+	UserName       string              `bson:"userName"`
+	OrderID        string              `bson:"orderId"`
+	Rating         int                 `bson:"rating"`
+	Title          string              `bson:"title,omitempty"`
+	Body           string              `bson:"body"`
+	Status         string              `bson:"status"`
+	ModerationNote string              `bson:"moderationNote,omitempty"`
+	ModeratedBy    string              `bson:"moderatedBy,omitempty"`
+	ModeratedAt    *primitive.DateTime `bson:"moderatedAt,omitempty"`
+	CreatedAt      primitive.DateTime  `bson:"createdAt"`
+	UpdatedAt      primitive.DateTime  `bson:"updatedAt"`
+}
+
+func reviewDocFromModel(r *models.Review) *reviewDoc {
+	return &reviewDoc{
+		ProductID:      r.ProductID,
+		UserID:         r.UserID,
+		UserName:       r.UserName,
+		OrderID:        r.OrderID,
+		Rating:         r.Rating,
+		Title:          r.Title,
+		Body:           r.Body,
+		Status:         r.Status,
+		ModerationNote: r.ModerationNote,
+		ModeratedBy:    r.ModeratedBy,
+		ModeratedAt:    dateTimePtr(r.ModeratedAt),
+		CreatedAt:      primitive.NewDateTimeFromTime(r.CreatedAt),
+		UpdatedAt:      primitive.NewDateTimeFromTime(r.UpdatedAt),
+	}
+}
+
+func (d *reviewDoc) toModel() *models.Review {
+	return &models.Review{
+		ID:             d.ID.Hex(),
+		ProductID:      d.ProductID,
+		UserID:         d.UserID,
+		UserName:       d.UserName,
+		OrderID:        d.OrderID,
+		Rating:         d.Rating,
+		Title:          d.Title,
+		Body:           d.Body,
+		Status:         d.Status,
+		ModerationNote: d.ModerationNote,
+		ModeratedBy:    d.ModeratedBy,
+		ModeratedAt:    timePtr(d.ModeratedAt),
+		CreatedAt:      d.CreatedAt.Time(),
+		UpdatedAt:      d.UpdatedAt.Time(),
+	}
+}
+
+type ReviewRepositoryMemory struct {
+	mu   sync.RWMutex
+	data map[string]*models.Review
+}
+
+func NewReviewRepositoryMemory() *ReviewRepositoryMemory {
+	return &ReviewRepositoryMemory{data: make(map[string]*models.Review)}
+}
+
+func (r *ReviewRepositoryMemory) Insert(ctx context.Context, rev *models.Review) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.data {
+		if existing.UserID == rev.UserID && existing.ProductID == rev.ProductID {
+			return ErrDuplicateReview
+		}
+	}
+	rev.ID = primitive.NewObjectID().Hex()
+	cp := *rev
+	r.data[rev.ID] = &cp
+	return nil
+}
+
+func (r *ReviewRepositoryMemory) Update(ctx context.Context, rev *models.Review) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cp := *rev
+	r.data[rev.ID] = &cp
+	return nil
+}
+
+func (r *ReviewRepositoryMemory) Delete(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.data, id)
+	return nil
+}
+
+func (r *ReviewRepositoryMemory) FindByID(ctx context.Context, id string) (*models.Review, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if rev, ok := r.data[id]; ok {
+		cp := *rev
+		return &cp, nil
+	}
+	return nil, nil
+}
+
+func (r *ReviewRepositoryMemory) FindByUserProduct(ctx context.Context, userID, productID string) (*models.Review, error) {
+	found := r.filter(func(rev *models.Review) bool { return rev.UserID == userID && rev.ProductID == productID })
+	if len(found) == 0 {
+		return nil, nil
+	}
+	return found[0], nil
+}
+
+func (r *ReviewRepositoryMemory) FindByProduct(ctx context.Context, productID, status string) ([]*models.Review, error) {
+	out := r.filter(func(rev *models.Review) bool { return rev.ProductID == productID && rev.Status == status })
+	sort.SliceStable(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out, nil
+}
+
+func (r *ReviewRepositoryMemory) FindByStatus(ctx context.Context, status string, limit int) ([]*models.Review, error) {
+	out := r.filter(func(rev *models.Review) bool { return rev.Status == status })
+	sort.SliceStable(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func (r *ReviewRepositoryMemory) filter(keep func(*models.Review) bool) []*models.Review {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := []*models.Review{}
+	for _, rev := range r.data {
+		if keep(rev) {
+			cp := *rev
+			out = append(out, &cp)
+		}
+	}
+	return out
+}
@@ -0,0 +1,211 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// StockMovementStore is the inventory ledger. It is append-only: movements
+// are never updated or deleted, corrections are new movements.
+type StockMovementStore interface {
+	Append(ctx context.Context, movements ...*models.StockMovement) error
+	// FindByProduct returns the newest movements of a product first.
+	FindByProduct(ctx context.Context, productID string, limit int) ([]*models.StockMovement, error)
+	// Balances sums movement quantities per variant ID. An empty productID
+	// covers the whole ledger.
+	Balances(ctx context.Context, productID string) (map[string]int, error)
+}
+
+type StockMovementRepositoryMongo struct {
+	coll *mongo.Collection
+}
+
+func NewStockMovementRepositoryMongo(coll *mongo.Collection) *StockMovementRepositoryMongo {
+	return &StockMovementRepositoryMongo{coll: coll}
+}
+
+func (r *StockMovementRepositoryMongo) Append(ctx context.Context, movements ...*models.StockMovement) error {
+	if len(movements) == 0 {
+		return nil
+	}
+	now := time.Now()
+	docs := make([]interface{}, len(movements))
+	ids := make([]primitive.ObjectID, len(movements))
+	for i, m := range movements {
+		if m.CreatedAt.IsZero() {
+			m.CreatedAt = now
+		}
+		ids[i] = primitive.NewObjectID()
+		docs[i] = stockMovementDoc{
+			ID:        ids[i],
+			ProductID: m.ProductID,
+			VariantID: m.VariantID,
+			SKU:       m.SKU,
+			Size:      m.Size,
+			Color:     m.Color,
+			Type:      m.Type,
+			Quantity:  m.Quantity,
+			Balance:   m.Balance,
+			Reason:    m.Reason,
+			OrderID:   m.OrderID,
+			Actor:     m.Actor,
+			CreatedAt: primitive.NewDateTimeFromTime(m.CreatedAt),
+		}
+	}
+	if _, err := r.coll.InsertMany(ctx, docs); err != nil {
+		return err
+	}
+	for i, m := range movements {
+		m.ID = ids[i].Hex()
+	}
+	return nil
+}
+
+func (r *StockMovementRepositoryMongo) FindByProduct(ctx context.Context, productID string, limit int) ([]*models.StockMovement, error) {
+	opts := options.Find().SetSort(bson.D{{"createdAt", -1}, {"_id", -1}})
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+	cur, err := r.coll.Find(ctx, bson.M{"productId": productID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	var out []*models.StockMovement
+	for cur.Next(ctx) {
+		var doc stockMovementDoc
+		if err := cur.Decode(&doc); err != nil {
+			return nil, err
+		}
+		out = append(out, doc.toModel())
+	}
+	return out, cur.Err()
+}
+
+func (r *StockMovementRepositoryMongo) Balances(ctx context.Context, productID string) (map[string]int, error) {
+	var pipeline mongo.Pipeline
+	if productID != "" {
+		pipeline = append(pipeline, bson.D{{"$match", bson.M{"productId": productID}}})
+	}
+	pipeline = append(pipeline, bson.D{{"$group", bson.M{"_id": "$variantId", "quantity": bson.M{"$sum": "$quantity"}}}})
+	cur, err := r.coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	out := make(map[string]int)
+	for cur.Next(ctx) {
+		var row struct {
+			VariantID string `bson:"_id"`
+			Quantity  int    `bson:"quantity"`
+		}
+		if err := cur.Decode(&row); err != nil {
+			return nil, err
+		}
+		out[row.VariantID] = row.Quantity
+	}
+	return out, cur.Err()
+}
+
+type stockMovementDoc struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	ProductID string             `bson:"productId"`
+	VariantID string             `bson:"variantId"`
+	SKU       string             `bson:"sku"`
+	Size      string             `bson:"size"`
+	Color     string             `bson:"color"`
+	Type      string             `bson:"type"`
+	Quantity  int                `bson:"quantity"`
+	Balance   int                `bson:"balance"`
+	Reason    string             `bson:"reason,omitempty"`
+	OrderID   string             `bson:"orderId,omitempty"`
+	Actor     string             `bson:"actor,omitempty"`
+	CreatedAt primitive.DateTime `bson:"createdAt"`
+}
+
+func (d *stockMovementDoc) toModel() *models.StockMovement {
+	return &models.StockMovement{
+		ID:        d.ID.Hex(),
+		ProductID: d.ProductID,
+		VariantID: d.VariantID,
+		SKU:       d.SKU,
+		Size:      d.Size,
+		Color:     d.Color,
+		Type:      d.Type,
+		Quantity:  d.Quantity,
+		Balance:   d.Balance,
+		Reason:    d.Reason,
+		OrderID:   d.OrderID,
+		Actor:     d.Actor,
+		CreatedAt: d.CreatedAt.Time(),
+	}
+}
+
+type StockMovementRepositoryMemory struct {
+	mu   sync.RWMutex
+	data []*models.StockMovement
+}
+
+func NewStockMovementRepositoryMemory() *StockMovementRepositoryMemory {
+	return &StockMovementRepositoryMemory{}
+}
+
+func (r *StockMovementRepositoryMemory) Append(ctx context.Context, movements ...*models.StockMovement) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for _, m := range movements {
+		if m.ID == "" {
+			b := make([]byte, 12)
+			if _, err := rand.Read(b); err != nil {
+				return err
+			}
+			m.ID = hex.EncodeToString(b)
+		}
+		if m.CreatedAt.IsZero() {
+			m.CreatedAt = now
+		}
+		cp := *m
+		r.data = append(r.data, &cp)
+	}
+	return nil
+}
+
+func (r *StockMovementRepositoryMemory) FindByProduct(ctx context.Context, productID string, limit int) ([]*models.StockMovement, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var out []*models.StockMovement
+	for i := len(r.data) - 1; i >= 0; i-- {
+		if r.data[i].ProductID == productID {
+			cp := *r.data[i]
+			out = append(out, &cp)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func (r *StockMovementRepositoryMemory) Balances(ctx context.Context, productID string) (map[string]int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make(map[string]int)
+	for _, m := range r.data {
+		if productID == "" || m.ProductID == productID {
+			out[m.VariantID] += m.Quantity
+		}
+	}
+	return out, nil
+}
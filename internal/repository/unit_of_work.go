@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/db"
+)
+
+// UnitOfWork groups writes across stores so they commit or fail together.
+// Stores take part by using the context handed to fn.
+type UnitOfWork interface {
+	Do(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type MongoUnitOfWork struct {
+	client *db.MongoDBClient
+}
+
+func NewMongoUnitOfWork(client *db.MongoDBClient) *MongoUnitOfWork {
+	return &MongoUnitOfWork{client: client}
+}
+
+func (u *MongoUnitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	return u.client.WithTransaction(ctx, fn)
+}
+
+// NoopUnitOfWork runs fn directly; it pairs with the in-memory stores, whose
+// callers compensate for partial failures themselves.
+type NoopUnitOfWork struct{}
+
+func (NoopUnitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
@@ -0,0 +1,280 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type WishlistStore interface {
+	FindByUser(ctx context.Context, userID string) (*models.Wishlist, error)
+	Save(ctx context.Context, w *models.Wishlist) error
+	// FindUserIDsByProduct lists the users who have the product on their wishlist.
+	FindUserIDsByProduct(ctx context.Context, productID string) ([]string, error)
+}
+
+type NotificationStore interface {
+	Create(ctx context.Context, n *models.RestockNotification) error
+	FindByUser(ctx context.Context, userID string, unreadOnly bool) ([]*models.RestockNotification, error)
+	MarkRead(ctx context.Context, userID string) error
+}
+
+type WishlistRepositoryMongo struct {
+	coll *mongo.Collection
+}
+
+func NewWishlistRepositoryMongo(coll *mongo.Collection) *WishlistRepositoryMongo {
+	return &WishlistRepositoryMongo{coll: coll}
+}
+
+func (r *WishlistRepositoryMongo) FindByUser(ctx context.Context, userID string) (*models.Wishlist, error) {
+	var doc wishlistDoc
+	err := r.coll.FindOne(ctx, bson.M{"userId": userID}).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return doc.toModel(), nil
+}
+
+func (r *WishlistRepositoryMongo) Save(ctx context.Context, w *models.Wishlist) error {
+	w.UpdatedAt = time.Now()
+	items := w.Items
+	if items == nil {
+		items = []models.WishlistItem{}
+	}
+	res, err := r.coll.UpdateOne(ctx,
+		bson.M{"userId": w.UserID},
+		bson.M{"$set": bson.M{"items": items, "updatedAt": primitive.NewDateTimeFromTime(w.UpdatedAt)}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return err
+	}
+	if oid, ok := res.UpsertedID.(primitive.ObjectID); ok {
+		w.ID = oid.Hex()
+	}
+	return nil
+}
+
+func (r *WishlistRepositoryMongo) FindUserIDsByProduct(ctx context.Context, productID string) ([]string, error) {
+	cur, err := r.coll.Find(ctx, bson.M{"items.productId": productID}, options.Find().SetProjection(bson.M{"userId": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	var out []string
+	for cur.Next(ctx) {
+		var doc wishlistDoc
+		if err := cur.Decode(&doc); err != nil {
+			return nil, err
+		}
+		out = append(out, doc.UserID)
+	}
+	return out, cur.Err()
+}
+
+type wishlistDoc struct {
+	ID        primitive.ObjectID    `bson:"_id,omitempty"`
+	UserID    string                `bson:"userId"`
+	Items     []models.WishlistItem `bson:"items"`
+	UpdatedAt primitive.DateTime    `bson:"updatedAt"`
+}
+
+func (d *wishlistDoc) toModel() *models.Wishlist {
+	return &models.Wishlist{
+		ID:        d.ID.Hex(),
+		UserID:    d.UserID,
+		Items:     d.Items,
+		UpdatedAt: d.UpdatedAt.Time(),
+	}
+}
+
+type NotificationRepositoryMongo struct {
+	coll *mongo.Collection
+}
+
+func NewNotificationRepositoryMongo(coll *mongo.Collection) *NotificationRepositoryMongo {
+	return &NotificationRepositoryMongo{coll: coll}
+}
+
+func (r *NotificationRepositoryMongo) Create(ctx context.Context, n *models.RestockNotification) error {
+	if n.CreatedAt.IsZero() {
+		n.CreatedAt = time.Now()
+	}
+	id := primitive.NewObjectID()
+	doc := notificationDoc{
+		ID:          id,
+		UserID:      n.UserID,
+		ProductID:   n.ProductID,
+		ProductName: n.ProductName,
+		Size:        n.Size,
+		Read:        n.Read,
+		CreatedAt:   primitive.NewDateTimeFromTime(n.CreatedAt),
+	}
+	if _, err := r.coll.InsertOne(ctx, doc); err != nil {
+		return err
+	}
+	n.ID = id.Hex()
+	return nil
+}
+
+func (r *NotificationRepositoryMongo) FindByUser(ctx context.Context, userID string, unreadOnly bool) ([]*models.RestockNotification, error) {
+	filter := bson.M{"userId": userID}
+	if unreadOnly {
+		filter["read"] = false
+	}
+	cur, err := r.coll.Find(ctx, filter, options.Find().SetSort(bson.M{"createdAt": -1}).SetLimit(50))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	var out []*models.RestockNotification
+	for cur.Next(ctx) {
+		var doc notificationDoc
+		if err := cur.Decode(&doc); err != nil {
+			return nil, err
+		}
+		out = append(out, doc.toModel())
+	}
+	return out, cur.Err()
+}
+
+func (r *NotificationRepositoryMongo) MarkRead(ctx context.Context, userID string) error {
+	_, err := r.coll.UpdateMany(ctx, bson.M{"userId": userID, "read": false}, bson.M{"$set": bson.M{"read": true}})
+	return err
+}
+
+type notificationDoc struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	UserID      string             `bson:"userId"`
+	ProductID   string             `bson:"productId"`
+	ProductName string             `bson:"productName"`
+	Size        string             `bson:"size"`
+	Read        bool               `bson:"read"`
+	CreatedAt   primitive.DateTime `bson:"createdAt"`
+}
+
+func (d *notificationDoc) toModel() *models.RestockNotification {
+	return &models.RestockNotification{
+		ID:          d.ID.Hex(),
+		UserID:      d.UserID,
+		ProductID:   d.ProductID,
+		ProductName: d.ProductName,
+		Size:        d.Size,
+		Read:        d.Read,
+		CreatedAt:   d.CreatedAt.Time(),
+	}
+}
+
+type WishlistRepositoryMemory struct {
+	mu   sync.RWMutex
+	data map[string]*models.Wishlist
+}
+
+func NewWishlistRepositoryMemory() *WishlistRepositoryMemory {
+	return &WishlistRepositoryMemory{data: make(map[string]*models.Wishlist)}
+}
+
+func (r *WishlistRepositoryMemory) FindByUser(ctx context.Context, userID string) (*models.Wishlist, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	w, ok := r.data[userID]
+	if !ok {
+		return nil, nil
+	}
+	out := *w
+	out.Items = append([]models.WishlistItem(nil), w.Items...)
+	return &out, nil
+}
+
+func (r *WishlistRepositoryMemory) Save(ctx context.Context, w *models.Wishlist) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	w.UpdatedAt = time.Now()
+	if w.ID == "" {
+		w.ID = w.UserID
+	}
+	out := *w
+	out.Items = append([]models.WishlistItem(nil), w.Items...)
+	r.data[w.UserID] = &out
+	return nil
+}
+
+func (r *WishlistRepositoryMemory) FindUserIDsByProduct(ctx context.Context, productID string) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var out []string
+	for userID, w := range r.data {
+		for _, it := range w.Items {
+			if it.ProductID == productID {
+				out = append(out, userID)
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+type NotificationRepositoryMemory struct {
+	mu   sync.RWMutex
+	data []*models.RestockNotification
+}
+
+func NewNotificationRepositoryMemory() *NotificationRepositoryMemory {
+	return &NotificationRepositoryMemory{}
+}
+
+func (r *NotificationRepositoryMemory) Create(ctx context.Context, n *models.RestockNotification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if n.ID == "" {
+		b := make([]byte, 12)
+		if _, err := rand.Read(b); err != nil {
+			return err
+		}
+		n.ID = hex.EncodeToString(b)
+	}
+	if n.CreatedAt.IsZero() {
+		n.CreatedAt = time.Now()
+	}
+	cp := *n
+	r.data = append(r.data, &cp)
+	return nil
+}
+
+func (r *NotificationRepositoryMemory) FindByUser(ctx context.Context, userID string, unreadOnly bool) ([]*models.RestockNotification, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var out []*models.RestockNotification
+	for _, n := range r.data {
+		if n.UserID == userID && (!unreadOnly || !n.Read) {
+			cp := *n
+			out = append(out, &cp)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out, nil
+}
+
+func (r *NotificationRepositoryMemory) MarkRead(ctx context.Context, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, n := range r.data {
+		if n.UserID == userID {
+			n.Read = true
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package search
+
+import (
+	"html"
+	"strings"
+)
+
+const (
+	snippetTokens  = 24
+	snippetLeading = 8
+)
+
+// highlight HTML-escapes text and wraps tokens found in match in <mark>.
+func highlight(text string, match map[string]bool) string {
+	toks := tokenize(text)
+	return mark(text, toks, match, 0, len(toks))
+}
+
+// snippet returns a window of text around the first matched token, or the
+// start of text when nothing in it matched.
+func snippet(text string, match map[string]bool) string {
+	toks := tokenize(text)
+	if len(toks) == 0 {
+		return ""
+	}
+	from := 0
+	for i, t := range toks {
+		if match[t.term] {
+			from = max(0, i-snippetLeading)
+			break
+		}
+	}
+	to := min(len(toks), from+snippetTokens)
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("… ")
+	}
+	b.WriteString(mark(text, toks, match, from, to))
+	if to < len(toks) {
+		b.WriteString(" …")
+	}
+	return b.String()
+}
+
+func mark(text string, toks []token, match map[string]bool, from, to int) string {
+	if from >= to {
+		return ""
+	}
+	var b strings.Builder
+	pos := toks[from].start
+	if from == 0 {
+		pos = 0
+	}
+	for _, t := range toks[from:to] {
+		b.WriteString(html.EscapeString(text[pos:t.start]))
+		if match[t.term] {
+			b.WriteString("<mark>")
+			b.WriteString(html.EscapeString(text[t.start:t.end]))
+			b.WriteString("</mark>")
+		} else {
+			b.WriteString(html.EscapeString(text[t.start:t.end]))
+		}
+		pos = t.end
+	}
+	if to == len(toks) {
+		b.WriteString(html.EscapeString(text[pos:]))
+	}
+	return b.String()
+}
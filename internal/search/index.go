@@ -0,0 +1,280 @@
+// Package search is an in-process inverted index over the product catalog
+// with relevance ranking, typo tolerance, quoted phrases and highlighting.
+package search
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
+)
+
+type field int
+
+const (
+	fieldName field = iota
+	fieldCategory
+	fieldColors
+	fieldDescription
+	numFields
+)
+
+var fieldWeights = [numFields]float64{3, 2, 1.5, 1}
+
+// prefixPenalty scores a prefix match of the last query term ("jack" for
+// "jacket") slightly below an exact hit so search-as-you-type still ranks.
+const prefixPenalty = 0.8
+
+type Hit struct {
+	ID    string  `json:"id"`
+	Score float64 `json:"score"`
+	// Name and Snippet are HTML-escaped with matches wrapped in <mark>.
+	Name    string `json:"name"`
+	Snippet string `json:"snippet"`
+}
+
+type document struct {
+	text  [numFields]string
+	terms [numFields][]string
+}
+
+type Index struct {
+	mu       sync.RWMutex
+	docs     map[string]*document
+	postings map[string]map[string]*[numFields]int
+}
+
+func NewIndex() *Index {
+	return &Index{
+		docs:     make(map[string]*document),
+		postings: make(map[string]map[string]*[numFields]int),
+	}
+}
+
+func (i *Index) Rebuild(products []*models.Product) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.docs = make(map[string]*document, len(products))
+	i.postings = make(map[string]map[string]*[numFields]int)
+	for _, p := range products {
+		if !p.Archived() {
+			i.putLocked(p)
+		}
+	}
+}
+
+func (i *Index) Put(p *models.Product) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.removeLocked(p.ID)
+	i.putLocked(p)
+}
+
+func (i *Index) Remove(id string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.removeLocked(id)
+}
+
+// ProductChanged keeps the index in sync; it matches services.ProductHook.
+// Archived products are dropped like deleted ones.
+func (i *Index) ProductChanged(ctx context.Context, before, after *models.Product) {
+	if after == nil || after.Archived() {
+		if before != nil {
+			i.Remove(before.ID)
+		}
+		return
+	}
+	i.Put(after)
+}
+
+func (i *Index) Len() int {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return len(i.docs)
+}
+
+func (i *Index) putLocked(p *models.Product) {
+	doc := &document{}
+	doc.text[fieldName] = p.Name
+	doc.text[fieldCategory] = p.Category
+	doc.text[fieldColors] = strings.Join(p.Colors, ", ")
+	doc.text[fieldDescription] = p.Description
+	for f := field(0); f < numFields; f++ {
+		doc.terms[f] = terms(doc.text[f])
+		for _, t := range doc.terms[f] {
+			list, ok := i.postings[t]
+			if !ok {
+				list = make(map[string]*[numFields]int)
+				i.postings[t] = list
+			}
+			tf, ok := list[p.ID]
+			if !ok {
+				tf = &[numFields]int{}
+				list[p.ID] = tf
+			}
+			tf[f]++
+		}
+	}
+	i.docs[p.ID] = doc
+}
+
+func (i *Index) removeLocked(id string) {
+	doc, ok := i.docs[id]
+	if !ok {
+		return
+	}
+	for f := field(0); f < numFields; f++ {
+		for _, t := range doc.terms[f] {
+			if list, ok := i.postings[t]; ok {
+				delete(list, id)
+				if len(list) == 0 {
+					delete(i.postings, t)
+				}
+			}
+		}
+	}
+	delete(i.docs, id)
+}
+
+type scored struct {
+	id      string
+	score   float64
+	matched int
+	terms   map[string]bool
+}
+
+// Search ranks documents against q. Each query term may match index terms
+// within a small edit distance; documents must match at least half of the
+// terms and every quoted phrase. At most limit hits are returned.
+func (i *Index) Search(q string, limit int) []Hit {
+	parsed := parseQuery(q)
+	if len(parsed.terms) == 0 {
+		return nil
+	}
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	n := float64(len(i.docs))
+	acc := make(map[string]*scored)
+	for qi, qt := range parsed.terms {
+		best := make(map[string]float64)
+		for t, penalty := range i.candidates(qt, qi == len(parsed.terms)-1) {
+			list := i.postings[t]
+			idf := math.Log(1 + n/float64(len(list)))
+			for id, tf := range list {
+				s := 0.0
+				for f := field(0); f < numFields; f++ {
+					if tf[f] > 0 {
+						s += fieldWeights[f] * (1 + math.Log(float64(tf[f])))
+					}
+				}
+				s *= idf * penalty
+				if s > best[id] {
+					best[id] = s
+				}
+				a, ok := acc[id]
+				if !ok {
+					a = &scored{id: id, terms: make(map[string]bool)}
+					acc[id] = a
+				}
+				a.terms[t] = true
+			}
+		}
+		for id, s := range best {
+			acc[id].score += s
+			acc[id].matched++
+		}
+	}
+
+	required := (len(parsed.terms) + 1) / 2
+	var ranked []*scored
+	for id, a := range acc {
+		if a.matched < required || !i.docs[id].hasPhrases(parsed.phrases) {
+			continue
+		}
+		a.score *= float64(a.matched) / float64(len(parsed.terms))
+		ranked = append(ranked, a)
+	}
+	sort.Slice(ranked, func(x, y int) bool {
+		if ranked[x].score != ranked[y].score {
+			return ranked[x].score > ranked[y].score
+		}
+		return ranked[x].id < ranked[y].id
+	})
+	if limit > 0 && len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+
+	hits := make([]Hit, 0, len(ranked))
+	for _, a := range ranked {
+		doc := i.docs[a.id]
+		hits = append(hits, Hit{
+			ID:      a.id,
+			Score:   math.Round(a.score*1000) / 1000,
+			Name:    highlight(doc.text[fieldName], a.terms),
+			Snippet: snippet(doc.text[fieldDescription], a.terms),
+		})
+	}
+	return hits
+}
+
+// candidates maps index terms that may stand for qt to a score multiplier.
+func (i *Index) candidates(qt string, last bool) map[string]float64 {
+	out := make(map[string]float64)
+	if _, ok := i.postings[qt]; ok {
+		out[qt] = 1
+	}
+	budget := maxEdits(utf8.RuneCountInString(qt))
+	prefix := last && utf8.RuneCountInString(qt) >= 3
+	if budget == 0 && !prefix {
+		return out
+	}
+	for t := range i.postings {
+		if t == qt {
+			continue
+		}
+		if budget > 0 {
+			if d := editDistance(qt, t, budget); d <= budget {
+				out[t] = 1 / float64(1+d)
+			}
+		}
+		if prefix && strings.HasPrefix(t, qt) && out[t] < prefixPenalty {
+			out[t] = prefixPenalty
+		}
+	}
+	return out
+}
+
+func (d *document) hasPhrases(phrases [][]string) bool {
+	for _, phrase := range phrases {
+		found := false
+		for f := field(0); f < numFields && !found; f++ {
+			found = containsSequence(d.terms[f], phrase)
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func containsSequence(haystack, needle []string) bool {
+	for i := 0; i+len(needle) <= len(haystack); i++ {
+		match := true
+		for j := range needle {
+			if haystack[i+j] != needle[j] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
+)
+
+func testIndex() *Index {
+	archived := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	idx := NewIndex()
+	idx.Rebuild([]*models.Product{
+		{ID: "jacket", Name: "Black Leather Jacket", Category: "Outerwear", Description: "A warm jacket for winter."},
+		{ID: "jeans", Name: "Denim Jeans", Category: "Pants", Colors: []string{"black", "blue"}, Description: "Classic five pocket jeans."},
+		{ID: "sweater", Name: "Wool Sweater", Category: "Knitwear", Description: "Wear it under a leather jacket."},
+		{ID: "old", Name: "Old Jacket", Description: "No longer sold.", DeletedAt: &archived},
+	})
+	return idx
+}
+
+func hitIDs(hits []Hit) []string {
+	ids := []string{}
+	for _, h := range hits {
+		ids = append(ids, h.ID)
+	}
+	return ids
+}
+
+func TestSearchRanking(t *testing.T) {
+	idx := testIndex()
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"name outranks description", "jacket", []string{"jacket", "sweater"}},
+		{"name outranks colors", "black", []string{"jacket", "jeans"}},
+		{"category", "knitwear", []string{"sweater"}},
+		{"case is ignored", "DENIM", []string{"jeans"}},
+		{"one typo", "jeens", []string{"jeans"}},
+		{"transposed letters", "jakcet", []string{"jacket", "sweater"}},
+		{"short terms allow no typos", "wol", []string{}},
+		{"prefix of the last term", "sweat", []string{"sweater"}},
+		{"prefix only for the last term", "jack wool", []string{"sweater"}},
+		{"half of the terms must match", "red denim jeans", []string{"jeans"}},
+		{"exactly half of the terms", "wool sweater hat cap", []string{"sweater"}},
+		{"too few terms match", "wool hat cap", []string{}},
+		{"quoted phrase", `"black leather"`, []string{"jacket"}},
+		{"phrase must appear in order", `"leather black"`, []string{}},
+		{"archived products are not indexed", "old", []string{}},
+		{"empty query", "  ", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hitIDs(idx.Search(tt.query, 10)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchLimitAndScores(t *testing.T) {
+	idx := testIndex()
+	hits := idx.Search("jacket", 1)
+	if len(hits) != 1 || hits[0].ID != "jacket" {
+		t.Fatalf("Search with limit 1 = %v", hitIDs(hits))
+	}
+	all := idx.Search("jacket", 0)
+	if len(all) != 2 || !(all[0].Score > all[1].Score) {
+		t.Fatalf("scores are not descending: %+v", all)
+	}
+	if want := "Black Leather <mark>Jacket</mark>"; all[0].Name != want {
+		t.Errorf("Name = %q, want %q", all[0].Name, want)
+	}
+}
+
+func TestIndexUpdates(t *testing.T) {
+	idx := testIndex()
+	idx.Put(&models.Product{ID: "jeans", Name: "Cargo Trousers", Description: "Roomy pockets."})
+	if got := hitIDs(idx.Search("denim", 10)); len(got) != 0 {
+		t.Errorf("old terms still match after Put: %v", got)
+	}
+	if got := hitIDs(idx.Search("cargo", 10)); !reflect.DeepEqual(got, []string{"jeans"}) {
+		t.Errorf("Search(cargo) = %v", got)
+	}
+	idx.Remove("jacket")
+	if got := hitIDs(idx.Search("jacket", 10)); !reflect.DeepEqual(got, []string{"sweater"}) {
+		t.Errorf("Search(jacket) after Remove = %v", got)
+	}
+	if idx.Len() != 2 {
+		t.Errorf("Len = %d, want 2", idx.Len())
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		max  int
+		want int
+	}{
+		{"jacket", "jacket", 2, 0},
+		{"jacket", "jackets", 2, 1},
+		{"jacket", "jakcet", 2, 1},
+		{"jacket", "jocket", 2, 1},
+		{"sweater", "swetaer", 2, 1},
+		{"denim", "dnm", 2, 2},
+		{"denim", "jacket", 2, 3},
+		{"blue", "blouse", 1, 2},
+		{"café", "cafe", 1, 1},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b, tt.max); got != tt.want {
+			t.Errorf("editDistance(%q, %q, %d) = %d, want %d", tt.a, tt.b, tt.max, got, tt.want)
+		}
+	}
+}
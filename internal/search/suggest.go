@@ -0,0 +1,277 @@
+package search
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
+)
+
+const (
+	SuggestProduct  = "product"
+	SuggestCategory = "category"
+	SuggestQuery    = "query"
+
+	// suggestVisitBudget bounds the trie nodes walked per lookup so a short
+	// prefix over a large catalog still answers in constant time.
+	suggestVisitBudget = 4000
+	// Only the most frequent queries are suggested; the tracked set is
+	// pruned back once it grows past maxTrackedQueries.
+	maxSuggestedQueries = 200
+	maxTrackedQueries   = 5000
+	rebuildDebounce     = 2 * time.Second
+	queryRefresh        = time.Minute
+)
+
+type Suggestion struct {
+	Kind      string `json:"kind"`
+	Text      string `json:"text"`
+	ProductID string `json:"product_id,omitempty"`
+	weight    int
+}
+
+type Suggestions struct {
+	Products   []Suggestion `json:"products"`
+	Categories []Suggestion `json:"categories"`
+	Queries    []Suggestion `json:"queries"`
+}
+
+type trieNode struct {
+	children map[rune]*trieNode
+	entries  []int
+}
+
+type trie struct {
+	root    *trieNode
+	entries []Suggestion
+}
+
+func (t *trie) insert(key string, entry int) {
+	n := t.root
+	for _, r := range key {
+		child, ok := n.children[r]
+		if !ok {
+			child = &trieNode{children: make(map[rune]*trieNode)}
+			n.children[r] = child
+		}
+		n = child
+	}
+	n.entries = append(n.entries, entry)
+}
+
+// add indexes s under its full text and under every later word, so "jac"
+// suggests "Leather Jacket".
+func (t *trie) add(s Suggestion) {
+	idx := len(t.entries)
+	t.entries = append(t.entries, s)
+	words := terms(s.Text)
+	for i := range words {
+		t.insert(strings.Join(words[i:], " "), idx)
+	}
+}
+
+func (t *trie) lookup(prefix string) []int {
+	n := t.root
+	for _, r := range prefix {
+		if n = n.children[r]; n == nil {
+			return nil
+		}
+	}
+	seen := make(map[int]bool)
+	var out []int
+	stack := []*trieNode{n}
+	for visited := 0; len(stack) > 0 && visited < suggestVisitBudget; visited++ {
+		n = stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, e := range n.entries {
+			if !seen[e] {
+				seen[e] = true
+				out = append(out, e)
+			}
+		}
+		for _, child := range n.children {
+			stack = append(stack, child)
+		}
+	}
+	return out
+}
+
+// Suggester answers search-as-you-type lookups from a trie of product names,
+// categories and popular queries. The trie is rebuilt in the background and
+// swapped atomically, so lookups never wait on a rebuild.
+type Suggester struct {
+	source  func(ctx context.Context) ([]*models.Product, error)
+	current atomic.Pointer[trie]
+	rebuild chan struct{}
+
+	mu      sync.Mutex
+	queries map[string]int
+	dirty   bool
+}
+
+func NewSuggester(source func(ctx context.Context) ([]*models.Product, error)) *Suggester {
+	s := &Suggester{
+		source:  source,
+		rebuild: make(chan struct{}, 1),
+		queries: make(map[string]int),
+	}
+	s.current.Store(&trie{root: &trieNode{children: make(map[rune]*trieNode)}})
+	return s
+}
+
+func (s *Suggester) Rebuild(ctx context.Context) error {
+	products, err := s.source(ctx)
+	if err != nil {
+		return err
+	}
+	t := &trie{root: &trieNode{children: make(map[rune]*trieNode)}}
+	categories := make(map[string]*Suggestion)
+	for _, p := range products {
+		if p.Archived() {
+			continue
+		}
+		if strings.TrimSpace(p.Name) != "" {
+			t.add(Suggestion{Kind: SuggestProduct, Text: p.Name, ProductID: p.ID, weight: 1})
+		}
+		name := strings.TrimSpace(p.Category)
+		if name == "" {
+			continue
+		}
+		key := strings.ToLower(name)
+		if c, ok := categories[key]; ok {
+			c.weight++
+		} else {
+			categories[key] = &Suggestion{Kind: SuggestCategory, Text: name, weight: 1}
+		}
+	}
+	for _, c := range categories {
+		t.add(*c)
+	}
+	for _, q := range s.topQueries() {
+		t.add(q)
+	}
+	s.current.Store(t)
+	return nil
+}
+
+// Run rebuilds the trie after product changes, debounced, and periodically
+// to pick up new popular queries. It returns when ctx is done.
+func (s *Suggester) Run(ctx context.Context) {
+	ticker := time.NewTicker(queryRefresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.rebuild:
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(rebuildDebounce):
+			}
+		case <-ticker.C:
+			s.mu.Lock()
+			dirty := s.dirty
+			s.mu.Unlock()
+			if !dirty {
+				continue
+			}
+		}
+		if err := s.Rebuild(ctx); err != nil {
+			log.Printf("suggest rebuild: %v", err)
+		}
+	}
+}
+
+// ProductChanged schedules a rebuild; it matches services.ProductHook.
+func (s *Suggester) ProductChanged(ctx context.Context, before, after *models.Product) {
+	select {
+	case s.rebuild <- struct{}{}:
+	default:
+	}
+}
+
+// RecordQuery counts a search that returned results.
+func (s *Suggester) RecordQuery(q string) {
+	key := strings.Join(terms(q), " ")
+	if key == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queries[key]++
+	s.dirty = true
+	if len(s.queries) > maxTrackedQueries {
+		for k, n := range s.queries {
+			if n <= 1 {
+				delete(s.queries, k)
+			}
+		}
+	}
+}
+
+func (s *Suggester) topQueries() []Suggestion {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dirty = false
+	out := make([]Suggestion, 0, len(s.queries))
+	for q, n := range s.queries {
+		out = append(out, Suggestion{Kind: SuggestQuery, Text: q, weight: n})
+	}
+	sortSuggestions(out)
+	if len(out) > maxSuggestedQueries {
+		out = out[:maxSuggestedQueries]
+	}
+	return out
+}
+
+// Suggest returns up to limit suggestions of each kind whose text, or a word
+// in it, starts with prefix.
+func (s *Suggester) Suggest(prefix string, limit int) Suggestions {
+	out := Suggestions{Products: []Suggestion{}, Categories: []Suggestion{}, Queries: []Suggestion{}}
+	key := strings.Join(terms(prefix), " ")
+	if key == "" {
+		return out
+	}
+	if strings.HasSuffix(prefix, " ") {
+		key += " "
+	}
+	t := s.current.Load()
+	for _, idx := range t.lookup(key) {
+		e := t.entries[idx]
+		switch e.Kind {
+		case SuggestProduct:
+			out.Products = append(out.Products, e)
+		case SuggestCategory:
+			out.Categories = append(out.Categories, e)
+		case SuggestQuery:
+			out.Queries = append(out.Queries, e)
+		}
+	}
+	out.Products = topSuggestions(out.Products, limit)
+	out.Categories = topSuggestions(out.Categories, limit)
+	out.Queries = topSuggestions(out.Queries, limit)
+	return out
+}
+
+func topSuggestions(list []Suggestion, limit int) []Suggestion {
+	sortSuggestions(list)
+	if limit > 0 && len(list) > limit {
+		list = list[:limit]
+	}
+	return list
+}
+
+func sortSuggestions(list []Suggestion) {
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].weight != list[j].weight {
+			return list[i].weight > list[j].weight
+		}
+		return strings.ToLower(list[i].Text) < strings.ToLower(list[j].Text)
+	})
+}
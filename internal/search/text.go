@@ -0,0 +1,125 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+type token struct {
+	term       string
+	start, end int
+}
+
+// tokenize splits s into lower-cased letter/digit runs, keeping byte offsets
+// into s so matches can be highlighted in the original text.
+func tokenize(s string) []token {
+	var out []token
+	start := -1
+	for i, r := range s {
+		word := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if word && start < 0 {
+			start = i
+		}
+		if !word && start >= 0 {
+			out = append(out, token{term: strings.ToLower(s[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		out = append(out, token{term: strings.ToLower(s[start:]), start: start, end: len(s)})
+	}
+	return out
+}
+
+func terms(s string) []string {
+	toks := tokenize(s)
+	out := make([]string, len(toks))
+	for i, t := range toks {
+		out[i] = t.term
+	}
+	return out
+}
+
+// query is a parsed search string: free terms plus quoted phrases that must
+// appear verbatim.
+type query struct {
+	terms   []string
+	phrases [][]string
+}
+
+func parseQuery(s string) query {
+	var q query
+	parts := strings.Split(s, `"`)
+	for i, part := range parts {
+		// Odd parts sit between quotes; an unterminated quote is a phrase too.
+		if i%2 == 1 {
+			if phrase := terms(part); len(phrase) > 1 {
+				q.phrases = append(q.phrases, phrase)
+				q.terms = append(q.terms, phrase...)
+				continue
+			}
+		}
+		q.terms = append(q.terms, terms(part)...)
+	}
+	q.terms = dedupe(q.terms)
+	return q
+}
+
+func dedupe(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	out := values[:0]
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// maxEdits is the typo budget for a query term of n runes.
+func maxEdits(n int) int {
+	switch {
+	case n >= 8:
+		return 2
+	case n >= 4:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// editDistance is the optimal string alignment distance between a and b
+// (Levenshtein plus adjacent transpositions), giving up once it exceeds max.
+func editDistance(a, b string, max int) int {
+	ra, rb := []rune(a), []rune(b)
+	if d := len(ra) - len(rb); d > max || -d > max {
+		return max + 1
+	}
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+			rowMin = min(rowMin, cur[j])
+		}
+		if rowMin > max {
+			return max + 1
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(rb)]
+}
@@ -106,7 +106,7 @@ func (s *AnalyticsService) GetDashboardStats(ctx context.Context) (*DashboardSta
 		for _, sc := range ordersByStatus {
 			stats.OrdersByStatus[sc.Status] = sc.Count
 		}
-		topProducts, err := mongoRepo.AggregateTopProducts(ctx, 10)
+		topProducts, err := mongoRepo.AggregateTopProducts(ctx, time.Time{}, 10)
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,420 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/repository"
+)
+
+var (
+	ErrCartItemNotFound = errors.New("cart item not found")
+	ErrCartEmpty        = errors.New("cart is empty")
+	ErrInvalidCartItem  = errors.New("invalid cart item")
+	ErrCartStock        = errors.New("not enough stock for requested quantity")
+)
+
+// CartOwner identifies a cart: the logged-in user, or an anonymous session
+// carried in a signed cookie.
+type CartOwner struct {
+	UserID    string
+	SessionID string
+}
+
+func (o CartOwner) empty() bool {
+	return o.UserID == "" && o.SessionID == ""
+}
+
+type CartService struct {
+	carts    repository.CartStore
+	products repository.ProductStore
+	orders   *OrderService
+	secret   []byte
+}
+
+func NewCartService(carts repository.CartStore, products repository.ProductStore, orders *OrderService, secret string) *CartService {
+	return &CartService{
+		carts:    carts,
+		products: products,
+		orders:   orders,
+		secret:   []byte(secret),
+	}
+}
+
+// NewSession issues an anonymous cart session id and the signed token to
+// store in the client cookie.
+func (s *CartService) NewSession() (string, string, error) {
+	id, err := newID()
+	if err != nil {
+		return "", "", err
+	}
+	return id, id + "." + s.sign(id), nil
+}
+
+// ParseSession verifies a token produced by NewSession.
+func (s *CartService) ParseSession(token string) (string, bool) {
+	id, sig, ok := strings.Cut(token, ".")
+	if !ok || id == "" {
+		return "", false
+	}
+	if !hmac.Equal([]byte(sig), []byte(s.sign(id))) {
+		return "", false
+	}
+	return id, true
+}
+
+func (s *CartService) sign(id string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte("cart:" + id))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (s *CartService) Get(ctx context.Context, owner CartOwner) (*models.CartView, error) {
+	cart, err := s.find(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	return s.price(ctx, cart)
+}
+
+func (s *CartService) AddItem(ctx context.Context, owner CartOwner, req *models.AddCartItemRequest) (*models.CartView, error) {
+	if req.Quantity == 0 {
+		req.Quantity = 1
+	}
+	if req.Quantity < 0 || owner.empty() {
+		return nil, ErrInvalidCartItem
+	}
+	p, err := s.products.FindByID(ctx, req.ProductID)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil || p.Archived() {
+		return nil, ErrProductNotFound
+	}
+	if req.VariantID == "" {
+		if _, ok := matchOption(p.Sizes, req.SelectedSize); !ok {
+			return nil, ErrInvalidCartItem
+		}
+		if _, ok := matchOption(p.Colors, req.SelectedColor); !ok {
+			return nil, ErrInvalidCartItem
+		}
+	}
+	v := resolveVariant(p, req.VariantID, req.SelectedSize, req.SelectedColor)
+	if v == nil {
+		return nil, ErrInvalidCartItem
+	}
+	cart, err := s.findOrNew(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	var line *models.CartItem
+	for i := range cart.Items {
+		it := &cart.Items[i]
+		if it.ProductID == p.ID && cartVariant(p, it) == v {
+			line = it
+			break
+		}
+	}
+	if line == nil {
+		id, err := newID()
+		if err != nil {
+			return nil, err
+		}
+		cart.Items = append(cart.Items, models.CartItem{
+			ID:            id,
+			ProductID:     p.ID,
+			VariantID:     v.ID,
+			SelectedSize:  v.Size,
+			SelectedColor: v.Color,
+			AddedAt:       time.Now(),
+		})
+		line = &cart.Items[len(cart.Items)-1]
+	}
+	if line.Quantity+req.Quantity > v.Stock {
+		return nil, ErrCartStock
+	}
+	line.Quantity += req.Quantity
+	if err := s.carts.Save(ctx, cart); err != nil {
+		return nil, err
+	}
+	return s.price(ctx, cart)
+}
+
+// UpdateItem sets the quantity of a line; zero or less removes it.
+func (s *CartService) UpdateItem(ctx context.Context, owner CartOwner, itemID string, qty int) (*models.CartView, error) {
+	if qty <= 0 {
+		return s.RemoveItem(ctx, owner, itemID)
+	}
+	cart, err := s.find(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	idx := cartItemIndex(cart, itemID)
+	if idx < 0 {
+		return nil, ErrCartItemNotFound
+	}
+	it := &cart.Items[idx]
+	if qty > it.Quantity {
+		p, err := s.products.FindByID(ctx, it.ProductID)
+		if err != nil {
+			return nil, err
+		}
+		if p == nil || p.Archived() {
+			return nil, ErrCartStock
+		}
+		if v := cartVariant(p, it); v == nil || qty > v.Stock {
+			return nil, ErrCartStock
+		}
+	}
+	it.Quantity = qty
+	if err := s.carts.Save(ctx, cart); err != nil {
+		return nil, err
+	}
+	return s.price(ctx, cart)
+}
+
+func (s *CartService) RemoveItem(ctx context.Context, owner CartOwner, itemID string) (*models.CartView, error) {
+	cart, err := s.find(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	idx := cartItemIndex(cart, itemID)
+	if idx < 0 {
+		return nil, ErrCartItemNotFound
+	}
+	cart.Items = append(cart.Items[:idx], cart.Items[idx+1:]...)
+	if err := s.carts.Save(ctx, cart); err != nil {
+		return nil, err
+	}
+	return s.price(ctx, cart)
+}
+
+func (s *CartService) Clear(ctx context.Context, owner CartOwner) error {
+	cart, err := s.find(ctx, owner)
+	if err != nil || cart == nil || cart.ID == "" {
+		return err
+	}
+	return s.carts.Delete(ctx, cart.ID)
+}
+
+// Merge folds the anonymous session cart into the user's cart, adding
+// quantities for lines both carts share, and drops the session cart.
+func (s *CartService) Merge(ctx context.Context, sessionID, userID string) error {
+	if sessionID == "" || userID == "" {
+		return nil
+	}
+	guest, err := s.carts.FindBySession(ctx, sessionID)
+	if err != nil || guest == nil {
+		return err
+	}
+	cart, err := s.findOrNew(ctx, CartOwner{UserID: userID})
+	if err != nil {
+		return err
+	}
+	for _, g := range guest.Items {
+		merged := false
+		for i := range cart.Items {
+			it := &cart.Items[i]
+			if sameCartLine(it, &g) {
+				it.Quantity += g.Quantity
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			cart.Items = append(cart.Items, g)
+		}
+	}
+	if err := s.carts.Save(ctx, cart); err != nil {
+		return err
+	}
+	return s.carts.Delete(ctx, guest.ID)
+}
+
+// Checkout places an order for everything in the user's cart, priced and
+// stock-checked by OrderService, and then empties the cart. A cart that
+// cannot be emptied is logged, not returned as an error.
+func (s *CartService) Checkout(ctx context.Context, userID string, req *models.CheckoutRequest) (*models.Order, error) {
+	cart, err := s.carts.FindByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if cart == nil || len(cart.Items) == 0 {
+		return nil, ErrCartEmpty
+	}
+	orderReq := &models.CreateOrderRequest{
+		UserID:          userID,
+		PaymentMethod:   req.PaymentMethod,
+		DeliveryMethod:  req.DeliveryMethod,
+		DeliveryAddress: req.DeliveryAddress,
+		Comment:         req.Comment,
+		PromoCode:       req.PromoCode,
+		Items:           orderItems(cart),
+	}
+	order, err := s.orders.Create(ctx, orderReq)
+	if err != nil {
+		return nil, err
+	}
+	// The order stands either way; failing here would make the shopper
+	// retry and order twice.
+	if err := s.carts.Delete(ctx, cart.ID); err != nil {
+		log.Printf("order %s placed but cart %s not cleared: %v", order.ID, cart.ID, err)
+	}
+	return order, nil
+}
+
+// ApplyCode previews the cart's order totals with a promo code, without
+// using the code up.
+func (s *CartService) ApplyCode(ctx context.Context, owner CartOwner, req *models.ApplyCodeRequest) (*models.OrderQuote, error) {
+	cart, err := s.find(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	if cart == nil || len(cart.Items) == 0 {
+		return nil, ErrCartEmpty
+	}
+	return s.orders.Quote(ctx, owner.UserID, orderItems(cart), req.DeliveryMethod, req.Code)
+}
+
+func orderItems(cart *models.Cart) []models.CreateOrderItem {
+	items := make([]models.CreateOrderItem, 0, len(cart.Items))
+	for _, it := range cart.Items {
+		items = append(items, models.CreateOrderItem{
+			ProductID:     it.ProductID,
+			VariantID:     it.VariantID,
+			SelectedSize:  it.SelectedSize,
+			SelectedColor: it.SelectedColor,
+			Quantity:      it.Quantity,
+		})
+	}
+	return items
+}
+
+func (s *CartService) find(ctx context.Context, owner CartOwner) (*models.Cart, error) {
+	switch {
+	case owner.UserID != "":
+		return s.carts.FindByUser(ctx, owner.UserID)
+	case owner.SessionID != "":
+		return s.carts.FindBySession(ctx, owner.SessionID)
+	}
+	return nil, nil
+}
+
+func (s *CartService) findOrNew(ctx context.Context, owner CartOwner) (*models.Cart, error) {
+	cart, err := s.find(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	if cart == nil {
+		cart = &models.Cart{UserID: owner.UserID}
+		if owner.UserID == "" {
+			cart.SessionID = owner.SessionID
+		}
+	}
+	return cart, nil
+}
+
+// price resolves every line against the catalog so the client always sees
+// current names, prices and availability.
+func (s *CartService) price(ctx context.Context, cart *models.Cart) (*models.CartView, error) {
+	view := &models.CartView{Items: []models.CartLine{}}
+	if cart == nil {
+		return view, nil
+	}
+	now := time.Now()
+	for _, it := range cart.Items {
+		line := models.CartLine{
+			ID:            it.ID,
+			ProductID:     it.ProductID,
+			SelectedSize:  it.SelectedSize,
+			SelectedColor: it.SelectedColor,
+			Quantity:      it.Quantity,
+		}
+		p, err := s.products.FindByID(ctx, it.ProductID)
+		if err != nil {
+			return nil, err
+		}
+		var v *models.ProductVariant
+		if p != nil {
+			v = cartVariant(p, &it)
+		}
+		switch {
+		case p == nil, p.Archived():
+			line.Issue = "no longer available"
+		case v == nil:
+			line.Issue = "selected option no longer available"
+		case v.Stock < it.Quantity:
+			line.Issue = fmt.Sprintf("only %d left", v.Stock)
+		default:
+			line.Available = true
+		}
+		if p != nil {
+			price := effectivePrice(p, v, now)
+			line.ProductName = p.Name
+			line.UnitPrice = price
+			line.LineTotal = price * float64(it.Quantity)
+			if len(p.Images) > 0 {
+				line.Image = p.Images[0]
+			}
+		}
+		if v != nil {
+			line.VariantID = v.ID
+			line.SKU = v.SKU
+			if len(v.Images) > 0 {
+				line.Image = v.Images[0]
+			}
+		}
+		if line.Available {
+			view.Subtotal += line.LineTotal
+		}
+		view.Count += it.Quantity
+		view.Items = append(view.Items, line)
+	}
+	return view, nil
+}
+
+// cartVariant resolves a cart line to its variant; lines saved before
+// variants existed only carry size and color.
+func cartVariant(p *models.Product, it *models.CartItem) *models.ProductVariant {
+	return resolveVariant(p, it.VariantID, it.SelectedSize, it.SelectedColor)
+}
+
+func sameCartLine(a, b *models.CartItem) bool {
+	if a.ProductID != b.ProductID {
+		return false
+	}
+	if a.VariantID != "" && b.VariantID != "" {
+		return a.VariantID == b.VariantID
+	}
+	return strings.EqualFold(a.SelectedSize, b.SelectedSize) && strings.EqualFold(a.SelectedColor, b.SelectedColor)
+}
+
+func cartItemIndex(cart *models.Cart, itemID string) int {
+	if cart == nil {
+		return -1
+	}
+	for i, it := range cart.Items {
+		if it.ID == itemID {
+			return i
+		}
+	}
+	return -1
+}
+
+func newID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,258 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/repository"
+)
+
+var (
+	ErrCategoryNotFound      = errors.New("category not found")
+	ErrInvalidCategory       = errors.New("invalid category")
+	ErrCategoryInUse         = errors.New("category still has subcategories or products")
+	ErrDuplicateCategorySlug = errors.New("slug is already used by another category")
+)
+
+var validSlug = regexp.MustCompile(`^[\p{Ll}\p{Lo}\p{Nd}]+(-[\p{Ll}\p{Lo}\p{Nd}]+)*$`)
+
+// categoryError is a validation failure reported with its own message; it
+// matches ErrInvalidCategory.
+type categoryError string
+
+func (e categoryError) Error() string        { return string(e) }
+func (e categoryError) Is(target error) bool { return target == ErrInvalidCategory }
+
+type CategoryService struct {
+	store    repository.CategoryStore
+	products *ProductService
+}
+
+func NewCategoryService(store repository.CategoryStore, products *ProductService) *CategoryService {
+	return &CategoryService{store: store, products: products}
+}
+
+// Tree returns the top-level categories with their descendants, siblings in
+// sort order. Product counts cover the shop's products, not archived ones.
+func (s *CategoryService) Tree(ctx context.Context) ([]*models.CategoryNode, error) {
+	all, err := s.store.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	facets, err := s.products.Facets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make(map[string]*models.CategoryNode, len(all))
+	for _, c := range all {
+		nodes[c.ID] = &models.CategoryNode{Category: c, ProductCount: facets.CategoryIDs[c.ID], Children: []*models.CategoryNode{}}
+	}
+	roots := []*models.CategoryNode{}
+	for _, c := range all {
+		if parent, ok := nodes[c.ParentID]; ok {
+			parent.Children = append(parent.Children, nodes[c.ID])
+		} else {
+			roots = append(roots, nodes[c.ID])
+		}
+	}
+	for _, root := range roots {
+		sumCounts(root)
+	}
+	sortNodes(roots)
+	return roots, nil
+}
+
+func sumCounts(n *models.CategoryNode) int {
+	for _, child := range n.Children {
+		n.ProductCount += sumCounts(child)
+	}
+	return n.ProductCount
+}
+
+func sortNodes(nodes []*models.CategoryNode) {
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].SortOrder != nodes[j].SortOrder {
+			return nodes[i].SortOrder < nodes[j].SortOrder
+		}
+		return strings.ToLower(nodes[i].Name) < strings.ToLower(nodes[j].Name)
+	})
+	for _, n := range nodes {
+		sortNodes(n.Children)
+	}
+}
+
+func (s *CategoryService) Get(ctx context.Context, id string) (*models.Category, error) {
+	c, err := s.store.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return nil, ErrCategoryNotFound
+	}
+	return c, nil
+}
+
+func (s *CategoryService) GetBySlug(ctx context.Context, slug string) (*models.Category, error) {
+	c, err := s.store.FindBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return nil, ErrCategoryNotFound
+	}
+	return c, nil
+}
+
+// Path returns the category's ancestors, top-level first, followed by the
+// category itself.
+func (s *CategoryService) Path(ctx context.Context, id string) ([]*models.Category, error) {
+	byID, err := s.byID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var path []*models.Category
+	for c := byID[id]; c != nil && len(path) <= len(byID); c = byID[c.ParentID] {
+		path = append([]*models.Category{c}, path...)
+	}
+	if len(path) == 0 {
+		return nil, ErrCategoryNotFound
+	}
+	return path, nil
+}
+
+// Subtree returns the IDs of the category and all of its descendants.
+func (s *CategoryService) Subtree(ctx context.Context, id string) ([]string, error) {
+	all, err := s.store.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	children := make(map[string][]string)
+	for _, c := range all {
+		children[c.ParentID] = append(children[c.ParentID], c.ID)
+	}
+	ids := []string{id}
+	for i := 0; i < len(ids); i++ {
+		ids = append(ids, children[ids[i]]...)
+	}
+	return ids, nil
+}
+
+func (s *CategoryService) Create(ctx context.Context, c *models.Category) (*models.Category, error) {
+	c.ID = ""
+	if err := s.validate(ctx, c); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	c.CreatedAt, c.UpdatedAt = now, now
+	created, err := s.store.Insert(ctx, c)
+	if errors.Is(err, repository.ErrDuplicateSlug) {
+		return nil, ErrDuplicateCategorySlug
+	}
+	return created, err
+}
+
+// Update replaces a category. A new name is copied onto its products.
+func (s *CategoryService) Update(ctx context.Context, id string, c *models.Category) (*models.Category, error) {
+	before, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	c.ID = id
+	if err := s.validate(ctx, c); err != nil {
+		return nil, err
+	}
+	c.CreatedAt = before.CreatedAt
+	c.UpdatedAt = time.Now()
+	// The category and its products are renamed together or not at all.
+	err = s.products.writeAll(ctx, func(ctx context.Context) error {
+		if err := s.store.Update(ctx, id, c); err != nil {
+			return err
+		}
+		if c.Name == before.Name {
+			return nil
+		}
+		return s.products.renameCategory(ctx, c)
+	})
+	if errors.Is(err, repository.ErrDuplicateSlug) {
+		return nil, ErrDuplicateCategorySlug
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Delete removes a category that has no subcategories and no products.
+func (s *CategoryService) Delete(ctx context.Context, id string) error {
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	all, err := s.store.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+	for _, c := range all {
+		if c.ParentID == id {
+			return ErrCategoryInUse
+		}
+	}
+	used, err := s.products.categoryUsage(ctx, id)
+	if err != nil {
+		return err
+	}
+	if used > 0 {
+		return ErrCategoryInUse
+	}
+	return s.store.Delete(ctx, id)
+}
+
+// validate normalizes c in place. The slug defaults to one made from the
+// name, and the parent must exist without making c its own ancestor.
+func (s *CategoryService) validate(ctx context.Context, c *models.Category) error {
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" {
+		return categoryError("name is required")
+	}
+	c.Slug = strings.ToLower(strings.TrimSpace(c.Slug))
+	if c.Slug == "" {
+		c.Slug = slugify(c.Name)
+	}
+	if !validSlug.MatchString(c.Slug) {
+		return categoryError("slug may only contain lowercase letters, digits and single hyphens")
+	}
+	c.ParentID = strings.TrimSpace(c.ParentID)
+	if c.ParentID == "" {
+		return nil
+	}
+	byID, err := s.byID(ctx)
+	if err != nil {
+		return err
+	}
+	if byID[c.ParentID] == nil {
+		return categoryError(fmt.Sprintf("parent category %q does not exist", c.ParentID))
+	}
+	for p := byID[c.ParentID]; p != nil; p = byID[p.ParentID] {
+		if c.ID != "" && p.ID == c.ID {
+			return categoryError("a category cannot be placed under itself or its subcategories")
+		}
+	}
+	return nil
+}
+
+func (s *CategoryService) byID(ctx context.Context) (map[string]*models.Category, error) {
+	all, err := s.store.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*models.Category, len(all))
+	for _, c := range all {
+		byID[c.ID] = c
+	}
+	return byID, nil
+}
@@ -0,0 +1,336 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/repository"
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/storage"
+)
+
+const (
+	MaxImageBytes = 10 << 20
+	// MaxImagePixels bounds decoding so a small file cannot expand into a
+	// huge bitmap.
+	MaxImagePixels = 40_000_000
+	// MaxProductImages caps the gallery of a single product.
+	MaxProductImages = 20
+	jpegQuality      = 85
+	// imagePrefix is the blob store folder for product images.
+	imagePrefix  = "products/"
+	signedURLTTL = 15 * time.Minute
+)
+
+var (
+	ErrImageTooLarge    = fmt.Errorf("image must be at most %d MB", MaxImageBytes>>20)
+	ErrUnsupportedImage = errors.New("image must be a JPEG, PNG or GIF")
+	ErrTooManyImages    = fmt.Errorf("a product can have at most %d images", MaxProductImages)
+	ErrImageNotFound    = errors.New("image not found")
+	ErrInvalidImageList = errors.New("images must list every current image exactly once")
+)
+
+// rendition is a stored size of an uploaded image. Images narrower than the
+// width are kept at their own size.
+type rendition struct {
+	name  string
+	width int
+}
+
+var renditions = []rendition{
+	{"thumb", 200},
+	{"medium", 600},
+	{"large", 1200},
+}
+
+// ImageService stores uploaded product images under their content hash and
+// removes the objects once no product uses them.
+type ImageService struct {
+	blobs    storage.BlobStore
+	products repository.ProductStore
+	// mu serializes Release against uploads: uploads counts the requests
+	// that stored an image but have not attached it to a product yet.
+	mu      sync.Mutex
+	uploads map[string]int
+}
+
+func NewImageService(blobs storage.BlobStore, products repository.ProductStore) *ImageService {
+	return &ImageService{blobs: blobs, products: products, uploads: make(map[string]int)}
+}
+
+// Store validates an upload and writes its renditions. Uploading the same
+// bytes twice yields the same key and reuses the stored objects. The image
+// is kept from Release until the caller passes its key to Finish or Discard.
+func (s *ImageService) Store(ctx context.Context, r io.Reader) (*models.ProductImage, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxImageBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxImageBytes {
+		return nil, ErrImageTooLarge
+	}
+	var ext, contentType string
+	switch http.DetectContentType(data) {
+	case "image/jpeg":
+		ext, contentType = "jpg", "image/jpeg"
+	case "image/png", "image/gif":
+		// GIFs are stored as PNG renditions of their first frame.
+		ext, contentType = "png", "image/png"
+	default:
+		return nil, ErrUnsupportedImage
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > MaxImagePixels {
+		return nil, ErrImageTooLarge
+	}
+
+	sum := sha256.Sum256(data)
+	key := hex.EncodeToString(sum[:16])
+	img := &models.ProductImage{
+		Key:         key,
+		ContentType: contentType,
+		Width:       cfg.Width,
+		Height:      cfg.Height,
+		Thumbnail:   s.blobs.URL(objectKey(key, "thumb", ext)),
+		Medium:      s.blobs.URL(objectKey(key, "medium", ext)),
+		Large:       s.blobs.URL(objectKey(key, "large", ext)),
+	}
+	// Registered before the existence check, so a Release of the same key
+	// either skips it or finishes deleting before the check runs.
+	s.begin(key)
+	if err := s.write(ctx, data, key, ext, contentType, cfg); err != nil {
+		s.Finish(key)
+		return nil, err
+	}
+	return img, nil
+}
+
+func (s *ImageService) write(ctx context.Context, data []byte, key, ext, contentType string, cfg image.Config) error {
+	stored, err := s.stored(ctx, key, ext)
+	if err != nil || stored {
+		return err
+	}
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return ErrUnsupportedImage
+	}
+	src := toNRGBA(decoded)
+	for _, r := range renditions {
+		w, h := fit(cfg.Width, cfg.Height, r.width)
+		var buf bytes.Buffer
+		if ext == "jpg" {
+			err = jpeg.Encode(&buf, resize(src, w, h), &jpeg.Options{Quality: jpegQuality})
+		} else {
+			err = png.Encode(&buf, resize(src, w, h))
+		}
+		if err != nil {
+			return err
+		}
+		if err := s.blobs.Put(ctx, objectKey(key, r.name, ext), buf.Bytes(), contentType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *ImageService) begin(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.uploads[key]++
+}
+
+// Finish ends the uploads of the given keys once they are attached to a
+// product or given up on.
+func (s *ImageService) Finish(keys ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range keys {
+		if s.uploads[key] <= 1 {
+			delete(s.uploads, key)
+		} else {
+			s.uploads[key]--
+		}
+	}
+}
+
+// Discard finishes uploads that were not attached and releases them.
+func (s *ImageService) Discard(ctx context.Context, keys ...string) {
+	s.Finish(keys...)
+	s.Release(ctx, keys...)
+}
+
+// SignedURL grants temporary access to a stored product image object.
+func (s *ImageService) SignedURL(ctx context.Context, object string) (string, error) {
+	if !strings.HasPrefix(object, imagePrefix) {
+		return "", ErrImageNotFound
+	}
+	return s.blobs.SignedURL(ctx, object, signedURLTTL)
+}
+
+// Stored reports whether every rendition of an uploaded image still exists.
+func (s *ImageService) Stored(ctx context.Context, img models.ProductImage) (bool, error) {
+	ext := "jpg"
+	if img.ContentType == "image/png" {
+		ext = "png"
+	}
+	return s.stored(ctx, img.Key, ext)
+}
+
+// Release deletes the objects of the given images unless a product still
+// references them or another upload of the same image is in flight.
+func (s *ImageService) Release(ctx context.Context, keys ...string) {
+	if len(keys) == 0 {
+		return
+	}
+	// Products are read under the lock too, so an upload attached after
+	// it is taken still counts as in flight here.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	products, err := s.products.FindAll(ctx)
+	if err != nil {
+		log.Printf("images: release %v: %v", keys, err)
+		return
+	}
+	used := make(map[string]bool)
+	for _, p := range products {
+		for _, m := range p.Media {
+			used[m.Key] = true
+		}
+	}
+	for _, key := range keys {
+		if !used[key] && s.uploads[key] == 0 {
+			s.delete(ctx, key)
+		}
+	}
+}
+
+// ProductChanged is a ProductHook that releases images dropped from a
+// product's gallery or left behind by a deleted product.
+func (s *ImageService) ProductChanged(ctx context.Context, before, after *models.Product) {
+	if before == nil {
+		return
+	}
+	kept := make(map[string]bool)
+	if after != nil {
+		for _, m := range after.Media {
+			kept[m.Key] = true
+		}
+	}
+	var removed []string
+	for _, m := range before.Media {
+		if !kept[m.Key] {
+			removed = append(removed, m.Key)
+		}
+	}
+	s.Release(ctx, removed...)
+}
+
+func (s *ImageService) delete(ctx context.Context, key string) {
+	if _, err := hex.DecodeString(key); err != nil || key == "" {
+		return
+	}
+	for _, r := range renditions {
+		for _, ext := range []string{"jpg", "png"} {
+			if err := s.blobs.Delete(ctx, objectKey(key, r.name, ext)); err != nil {
+				log.Printf("images: delete %s: %v", key, err)
+			}
+		}
+	}
+}
+
+func (s *ImageService) stored(ctx context.Context, key, ext string) (bool, error) {
+	for _, r := range renditions {
+		ok, err := s.blobs.Exists(ctx, objectKey(key, r.name, ext))
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func objectKey(key, rendition, ext string) string {
+	return imagePrefix + key + "-" + rendition + "." + ext
+}
+
+// fit scales width and height down to maxWidth, keeping the aspect ratio.
+func fit(width, height, maxWidth int) (int, int) {
+	if width <= maxWidth {
+		return width, height
+	}
+	h := height * maxWidth / width
+	if h < 1 {
+		h = 1
+	}
+	return maxWidth, h
+}
+
+// toNRGBA converts a decoded image once, so every rendition reads the same
+// bitmap.
+func toNRGBA(src image.Image) *image.NRGBA {
+	b := src.Bounds()
+	if in, ok := src.(*image.NRGBA); ok && b.Min == (image.Point{}) {
+		return in
+	}
+	in := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(in, in.Bounds(), src, b.Min, draw.Src)
+	return in
+}
+
+// resize scales in to w×h with a box filter, weighting colour by alpha so
+// transparent pixels do not darken the edges.
+func resize(in *image.NRGBA, w, h int) *image.NRGBA {
+	sw, sh := in.Rect.Dx(), in.Rect.Dy()
+	if sw == w && sh == h {
+		return in
+	}
+	out := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0, y1 := y*sh/h, (y+1)*sh/h
+		if y1 == y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < w; x++ {
+			x0, x1 := x*sw/w, (x+1)*sw/w
+			if x1 == x0 {
+				x1 = x0 + 1
+			}
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				row := in.Pix[sy*in.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					pa := uint64(p[3])
+					r += uint64(p[0]) * pa
+					g += uint64(p[1]) * pa
+					bl += uint64(p[2]) * pa
+					a += pa
+					n++
+				}
+			}
+			o := out.Pix[y*out.Stride+x*4:]
+			if a > 0 {
+				o[0], o[1], o[2] = uint8(r/a), uint8(g/a), uint8(bl/a)
+			}
+			o[3] = uint8(a / n)
+		}
+	}
+	return out
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/repository"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	DefaultLowStockThreshold = 5
+	// salesWindow is how far back sales are averaged for days of cover.
+	salesWindow = 28 * 24 * time.Hour
+)
+
+var (
+	inventoryStockUnits = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "inventory_stock_units",
+			Help: "Units in stock per product and size",
+		},
+		[]string{"product_id", "product", "size"},
+	)
+
+	inventoryLowStockThreshold = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "inventory_low_stock_threshold",
+			Help: "Per-size stock level at or below which a product is low on stock",
+		},
+		[]string{"product_id", "product"},
+	)
+
+	inventorySalesPerDay = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "inventory_sales_units_per_day",
+			Help: "Average units sold per day over the last 28 days, per product and size",
+		},
+		[]string{"product_id", "product", "size"},
+	)
+
+	inventoryDaysOfCover = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "inventory_days_of_cover",
+			Help: "Days until a size sells out at its recent sales rate; only sizes with recent sales",
+		},
+		[]string{"product_id", "product", "size"},
+	)
+
+	inventoryOutOfStockProducts = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "inventory_out_of_stock_products",
+		Help: "Active products with no stock in any size",
+	})
+
+	inventoryLowStockSizes = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "inventory_low_stock_sizes",
+		Help: "Sizes of active products at or below their low-stock threshold",
+	})
+
+	inventoryLastCollected = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "inventory_metrics_last_collected_timestamp_seconds",
+		Help: "When the inventory gauges were last refreshed",
+	})
+)
+
+// InventoryCollector periodically publishes stock levels and sales velocity
+// as Prometheus gauges.
+type InventoryCollector struct {
+	products  repository.ProductStore
+	orders    repository.OrderStore
+	threshold int
+}
+
+func NewInventoryCollector(products repository.ProductStore, orders repository.OrderStore, defaultThreshold int) *InventoryCollector {
+	if defaultThreshold <= 0 {
+		defaultThreshold = DefaultLowStockThreshold
+	}
+	return &InventoryCollector{products: products, orders: orders, threshold: defaultThreshold}
+}
+
+// Run collects once immediately and then on every tick until ctx is done.
+func (c *InventoryCollector) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := c.Collect(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("inventory metrics: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *InventoryCollector) Collect(ctx context.Context) error {
+	products, err := c.products.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+	sales, err := c.salesBySize(ctx, time.Now().Add(-salesWindow))
+	if err != nil {
+		return err
+	}
+	windowDays := salesWindow.Hours() / 24
+
+	inventoryStockUnits.Reset()
+	inventoryLowStockThreshold.Reset()
+	inventorySalesPerDay.Reset()
+	inventoryDaysOfCover.Reset()
+	outOfStock, lowSizes := 0, 0
+	for _, p := range products {
+		if !p.IsActive {
+			continue
+		}
+		threshold := c.lowStockThreshold(p)
+		inventoryLowStockThreshold.WithLabelValues(p.ID, p.Name).Set(float64(threshold))
+		total := 0
+		for size, stock := range sizeStock(p) {
+			total += stock
+			inventoryStockUnits.WithLabelValues(p.ID, p.Name, size).Set(float64(stock))
+			if stock <= threshold {
+				lowSizes++
+			}
+			units := sales[p.ID+"|"+size]
+			if units == 0 {
+				continue
+			}
+			perDay := float64(units) / windowDays
+			inventorySalesPerDay.WithLabelValues(p.ID, p.Name, size).Set(perDay)
+			inventoryDaysOfCover.WithLabelValues(p.ID, p.Name, size).Set(float64(stock) / perDay)
+		}
+		if total == 0 {
+			outOfStock++
+		}
+	}
+	inventoryOutOfStockProducts.Set(float64(outOfStock))
+	inventoryLowStockSizes.Set(float64(lowSizes))
+	inventoryLastCollected.SetToCurrentTime()
+	return nil
+}
+
+// lowStockThreshold is the product's own threshold, or the store default.
+func (c *InventoryCollector) lowStockThreshold(p *models.Product) int {
+	if p.LowStockThreshold > 0 {
+		return p.LowStockThreshold
+	}
+	return c.threshold
+}
+
+// salesBySize returns units sold since the given time keyed by
+// "productID|size", not counting cancelled or refunded orders.
+func (c *InventoryCollector) salesBySize(ctx context.Context, since time.Time) (map[string]int, error) {
+	out := make(map[string]int)
+	if mongoRepo, ok := c.orders.(*repository.OrderRepositoryMongo); ok {
+		rows, err := mongoRepo.AggregateSalesBySize(ctx, since)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			out[row.ProductID+"|"+row.Size] += row.Units
+		}
+		return out, nil
+	}
+
+	orders, err := c.orders.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range orders {
+		if o.CreatedAt.Before(since) || o.Status == models.OrderStatusCancelled || o.Status == models.OrderStatusRefunded {
+			continue
+		}
+		for _, it := range o.Items {
+			out[it.ProductID+"|"+it.SelectedSize] += it.Quantity
+		}
+	}
+	return out, nil
+}
+
+// sizeStock sums stock per size, including products sold without sizes.
+func sizeStock(p *models.Product) map[string]int {
+	if len(p.Variants) == 0 {
+		return p.StockBySize
+	}
+	out := make(map[string]int)
+	for _, v := range p.Variants {
+		out[v.Size] += v.Stock
+	}
+	return out
+}
@@ -0,0 +1,275 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/repository"
+)
+
+const (
+	DefaultHistorySize = 50
+	MaxHistorySize     = 500
+	maxReasonLength    = 500
+)
+
+var (
+	ErrInvalidMovement   = errors.New("invalid stock movement")
+	ErrVariantNotFound   = errors.New("variant not found")
+	ErrInsufficientStock = errors.New("insufficient stock")
+)
+
+// manualMovements are the movement types an admin may post, with the sign
+// applied to the unit count: a receipt restocks, an adjustment carries its
+// own sign. Sales, returns, reservations and releases only come from orders,
+// so reconciliation can trust them.
+var manualMovements = map[string]int{
+	models.MovementReceipt:    1,
+	models.MovementAdjustment: 0,
+}
+
+// InventoryService keeps the stock ledger. Variant stock on the product is
+// the cached level; every change to it is also written as a movement.
+type InventoryService struct {
+	products  *ProductService
+	movements repository.StockMovementStore
+	uow       repository.UnitOfWork
+}
+
+func NewInventoryService(products *ProductService, movements repository.StockMovementStore, uow repository.UnitOfWork) *InventoryService {
+	if uow == nil {
+		uow = repository.NoopUnitOfWork{}
+	}
+	return &InventoryService{products: products, movements: movements, uow: uow}
+}
+
+// Adjust applies a manual movement to one variant and records it together
+// with the resulting balance.
+func (s *InventoryService) Adjust(ctx context.Context, productID string, req *models.StockAdjustmentRequest, actor string) (*models.StockMovement, error) {
+	typ := strings.ToLower(strings.TrimSpace(req.Type))
+	if typ == "" {
+		typ = models.MovementAdjustment
+	}
+	sign, ok := manualMovements[typ]
+	if !ok {
+		return nil, fmt.Errorf("%w: type must be receipt or adjustment", ErrInvalidMovement)
+	}
+	qty := req.Quantity
+	switch {
+	case sign == 0 && qty == 0:
+		return nil, fmt.Errorf("%w: quantity must not be 0", ErrInvalidMovement)
+	case sign != 0 && qty <= 0:
+		return nil, fmt.Errorf("%w: quantity must be greater than 0", ErrInvalidMovement)
+	case sign != 0:
+		qty *= sign
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return nil, fmt.Errorf("%w: reason is required", ErrInvalidMovement)
+	}
+	if len(reason) > maxReasonLength {
+		return nil, fmt.Errorf("%w: reason is too long", ErrInvalidMovement)
+	}
+
+	m := &models.StockMovement{
+		ProductID: productID,
+		VariantID: req.VariantID,
+		Type:      typ,
+		Quantity:  qty,
+		Reason:    reason,
+		Actor:     actor,
+	}
+	if err := s.products.MoveStock(ctx, s.movements, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// History returns the newest movements of a product.
+func (s *InventoryService) History(ctx context.Context, productID string, limit int) ([]*models.StockMovement, error) {
+	if limit <= 0 {
+		limit = DefaultHistorySize
+	}
+	if limit > MaxHistorySize {
+		limit = MaxHistorySize
+	}
+	movements, err := s.movements.FindByProduct(ctx, productID, limit)
+	if err != nil {
+		return nil, err
+	}
+	if movements == nil {
+		movements = []*models.StockMovement{}
+	}
+	return movements, nil
+}
+
+// Levels lists each variant's cached stock next to its ledger balance.
+func (s *InventoryService) Levels(ctx context.Context, productID string) ([]models.InventoryLevel, error) {
+	p, err := s.products.GetByID(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, ErrProductNotFound
+	}
+	balances, err := s.movements.Balances(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	levels := make([]models.InventoryLevel, 0, len(p.Variants))
+	for _, v := range p.Variants {
+		levels = append(levels, models.InventoryLevel{
+			VariantID: v.ID,
+			SKU:       v.SKU,
+			Size:      v.Size,
+			Color:     v.Color,
+			Stock:     v.Stock,
+			Ledger:    balances[v.ID],
+		})
+	}
+	return levels, nil
+}
+
+// Discrepancies lists the adjustments that would bring the ledger in line
+// with the cached stock: one for every variant whose movements do not add up
+// to it. Variants with no movements yet get an opening balance. Nothing is
+// written.
+func (s *InventoryService) Discrepancies(ctx context.Context) ([]*models.StockMovement, error) {
+	products, err := s.products.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	balances, err := s.movements.Balances(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	out := []*models.StockMovement{}
+	for _, p := range products {
+		for _, v := range p.Variants {
+			balance, seen := balances[v.ID]
+			if balance == v.Stock {
+				continue
+			}
+			reason := "reconciliation"
+			if !seen {
+				reason = "opening balance"
+			}
+			out = append(out, &models.StockMovement{
+				ProductID: p.ID,
+				VariantID: v.ID,
+				SKU:       v.SKU,
+				Size:      v.Size,
+				Color:     v.Color,
+				Type:      models.MovementAdjustment,
+				Quantity:  v.Stock - balance,
+				Balance:   v.Stock,
+				Reason:    reason,
+			})
+		}
+	}
+	return out, nil
+}
+
+// Reconcile posts the adjustments Discrepancies lists, on behalf of actor,
+// and returns them.
+func (s *InventoryService) Reconcile(ctx context.Context, actor string) ([]*models.StockMovement, error) {
+	var out []*models.StockMovement
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		var err error
+		out, err = s.Discrepancies(ctx)
+		if err != nil {
+			return err
+		}
+		for _, m := range out {
+			m.Actor = actor
+		}
+		return s.movements.Append(ctx, out...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// recordMovements fills in each movement's balance from the product's
+// current stock and appends them to the ledger. Movements are in the order
+// they were applied, so balances are worked out backwards from the latest.
+// It returns the last product read.
+func recordMovements(ctx context.Context, products repository.ProductStore, ledger repository.StockMovementStore, movements []*models.StockMovement) (*models.Product, error) {
+	if ledger == nil || len(movements) == 0 {
+		return nil, nil
+	}
+	running := make(map[string]int)
+	loaded := make(map[string]bool)
+	var p *models.Product
+	for _, m := range movements {
+		if loaded[m.ProductID] {
+			continue
+		}
+		loaded[m.ProductID] = true
+		found, err := products.FindByID(ctx, m.ProductID)
+		if err != nil {
+			return nil, err
+		}
+		if found == nil {
+			continue
+		}
+		p = found
+		for _, v := range found.Variants {
+			running[v.ID] = v.Stock
+		}
+	}
+	for i := len(movements) - 1; i >= 0; i-- {
+		m := movements[i]
+		m.Balance = running[m.VariantID]
+		running[m.VariantID] -= m.Quantity
+	}
+	if err := ledger.Append(ctx, movements...); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// stockChanges turns the difference between two versions of a product into
+// adjustments; a removed variant is written off to zero.
+func stockChanges(productID string, before, after *models.Product, typ, reason string) []*models.StockMovement {
+	var out []*models.StockMovement
+	add := func(v models.ProductVariant, qty, balance int) {
+		if qty == 0 {
+			return
+		}
+		out = append(out, &models.StockMovement{
+			ProductID: productID,
+			VariantID: v.ID,
+			SKU:       v.SKU,
+			Size:      v.Size,
+			Color:     v.Color,
+			Type:      typ,
+			Quantity:  qty,
+			Balance:   balance,
+			Reason:    reason,
+		})
+	}
+	previous := make(map[string]int)
+	if before != nil {
+		for _, v := range before.Variants {
+			previous[v.ID] = v.Stock
+		}
+	}
+	current := make(map[string]bool)
+	for _, v := range after.Variants {
+		current[v.ID] = true
+		add(v, v.Stock-previous[v.ID], v.Stock)
+	}
+	if before != nil {
+		for _, v := range before.Variants {
+			if !current[v.ID] {
+				add(v, -v.Stock, 0)
+			}
+		}
+	}
+	return out
+}
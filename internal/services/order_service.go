@@ -3,6 +3,10 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
 	"time"
 
 	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
@@ -12,22 +16,113 @@ import (
 var (
 	ErrUserNotFound    = errors.New("user not found")
 	ErrProductNotFound = errors.New("product not found")
+	ErrEmptyOrder      = errors.New("order has no items")
+	ErrOrderNotFound   = errors.New("order not found")
+
+	ErrInvalidStatus           = errors.New("unknown order status")
+	ErrInvalidStatusTransition = errors.New("invalid order status transition")
+)
+
+// orderTransitions is the order lifecycle: each status maps to the statuses it
+// may move to next. Cancelled and refunded are terminal.
+var orderTransitions = map[string][]string{
+	models.OrderStatusPending:    {models.OrderStatusPaid, models.OrderStatusCancelled},
+	models.OrderStatusPaid:       {models.OrderStatusProcessing, models.OrderStatusCancelled, models.OrderStatusRefunded},
+	models.OrderStatusProcessing: {models.OrderStatusShipped, models.OrderStatusCancelled, models.OrderStatusRefunded},
+	models.OrderStatusShipped:    {models.OrderStatusDelivered},
+	models.OrderStatusDelivered:  {models.OrderStatusRefunded},
+	models.OrderStatusCancelled:  {},
+	models.OrderStatusRefunded:   {},
+}
+
+const (
+	LineErrProductNotFound   = "product_not_found"
+	LineErrInvalidQuantity   = "invalid_quantity"
+	LineErrInvalidSize       = "invalid_size"
+	LineErrInvalidColor      = "invalid_color"
+	LineErrInvalidVariant    = "invalid_variant"
+	LineErrInsufficientStock = "insufficient_stock"
 )
 
+type OrderLineError struct {
+	Line      int    `json:"line"`
+	ProductID string `json:"product_id"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Available *int   `json:"available,omitempty"`
+}
+
+// OrderValidationError lists every order line that could not be accepted.
+type OrderValidationError struct {
+	Lines []OrderLineError `json:"lines"`
+}
+
+func (e *OrderValidationError) Error() string {
+	if len(e.Lines) == 1 {
+		return "order line rejected: " + e.Lines[0].Message
+	}
+	return fmt.Sprintf("%d order lines rejected", len(e.Lines))
+}
+
+// StockOnly reports whether every rejected line failed only because of stock,
+// i.e. the request was well-formed but conflicts with current inventory.
+func (e *OrderValidationError) StockOnly() bool {
+	for _, l := range e.Lines {
+		if l.Code != LineErrInsufficientStock {
+			return false
+		}
+	}
+	return len(e.Lines) > 0
+}
+
+func (e *OrderValidationError) add(line int, productID, code, msg string) {
+	e.Lines = append(e.Lines, OrderLineError{Line: line, ProductID: productID, Code: code, Message: msg})
+}
+
 type OrderService struct {
 	orderRepo   repository.OrderStore
 	productRepo repository.ProductStore
 	userRepo    *repository.UserRepository
+	movements   repository.StockMovementStore
+	uow         repository.UnitOfWork
+	promotions  *PromotionService
+	// Courier and post delivery cost deliveryFee unless the subtotal reaches
+	// freeDeliveryOver (0 means never); pickup is always free.
+	deliveryFee      float64
+	freeDeliveryOver float64
 }
 
-func NewOrderService(orderRepo repository.OrderStore, productRepo repository.ProductStore, userRepo *repository.UserRepository) *OrderService {
+func NewOrderService(orderRepo repository.OrderStore, productRepo repository.ProductStore, userRepo *repository.UserRepository, movements repository.StockMovementStore, uow repository.UnitOfWork) *OrderService {
+	if uow == nil {
+		uow = repository.NoopUnitOfWork{}
+	}
 	return &OrderService{
 		orderRepo:   orderRepo,
 		productRepo: productRepo,
 		userRepo:    userRepo,
+		movements:   movements,
+		uow:         uow,
 	}
 }
 
+// EnablePromotions lets orders use promo codes.
+func (s *OrderService) EnablePromotions(promotions *PromotionService) {
+	s.promotions = promotions
+}
+
+// EnableDeliveryFee charges fee for courier and post delivery below a
+// subtotal of freeOver. Without it delivery is free.
+func (s *OrderService) EnableDeliveryFee(fee, freeOver float64) {
+	s.deliveryFee = fee
+	s.freeDeliveryOver = freeOver
+}
+
+// DeliveryPricing returns the delivery fee and the subtotal from which
+// delivery is free.
+func (s *OrderService) DeliveryPricing() (fee, freeOver float64) {
+	return s.deliveryFee, s.freeDeliveryOver
+}
+
 func (s *OrderService) Create(ctx context.Context, req *models.CreateOrderRequest) (*models.Order, error) {
 	if s.userRepo != nil {
 		if _, err := s.userRepo.FindByID(ctx, req.UserID); err != nil {
@@ -37,57 +132,421 @@ func (s *OrderService) Create(ctx context.Context, req *models.CreateOrderReques
 			return nil, err
 		}
 	}
-	var subtotal float64
-	items := make([]models.OrderItem, 0, len(req.Items))
-	for _, it := range req.Items {
-		if s.productRepo != nil {
-			p, err := s.productRepo.FindByID(ctx, it.ProductID)
-			if err != nil {
-				return nil, err
-			}
-			if p == nil {
-				return nil, ErrProductNotFound
-			}
-		}
-		lineTotal := it.UnitPrice * float64(it.Quantity)
-		subtotal += lineTotal
-		items = append(items, models.OrderItem{
-			ProductID:     it.ProductID,
-			ProductName:   it.ProductName,
-			SelectedSize:  it.SelectedSize,
-			SelectedColor: it.SelectedColor,
-			Quantity:      it.Quantity,
-			UnitPrice:     it.UnitPrice,
-			LineTotal:     lineTotal,
-		})
+	if len(req.Items) == 0 {
+		return nil, ErrEmptyOrder
+	}
+	items, subtotal, err := s.priceItems(ctx, req.Items)
+	if err != nil {
+		return nil, err
 	}
-	total := subtotal
+	now := time.Now()
+	fee := s.deliveryFeeFor(req.DeliveryMethod, subtotal)
 	order := &models.Order{
 		UserID:          req.UserID,
-		Status:          "pending",
+		Status:          models.OrderStatusPending,
 		PaymentMethod:   req.PaymentMethod,
 		DeliveryMethod:  req.DeliveryMethod,
 		DeliveryAddress: req.DeliveryAddress,
 		Comment:         req.Comment,
 		Subtotal:        subtotal,
-		DeliveryFee:     0,
-		Total:           total,
+		DeliveryFee:     fee,
 		Items:           items,
-		CreatedAt:       time.Now(),
-		UpdatedAt:       time.Now(),
+		StatusHistory: []models.OrderStatusChange{{
+			To:        models.OrderStatusPending,
+			ChangedBy: req.UserID,
+			ChangedAt: now,
+		}},
+		CreatedAt: now,
+		UpdatedAt: now,
 	}
-	if err := s.orderRepo.Save(ctx, order); err != nil {
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		// The transaction body may be retried; start each attempt from a new order.
+		order.ID = ""
+		// The code is checked and used up in the transaction, so its limits
+		// hold against concurrent orders.
+		promo, discounts, err := s.discount(ctx, req.UserID, req.PromoCode, items, subtotal, fee, now)
+		if err != nil {
+			return err
+		}
+		order.Discounts = discounts
+		order.DiscountTotal = sumDiscounts(discounts)
+		order.Total = roundCents(subtotal + fee - order.DiscountTotal)
+		if promo != nil {
+			if err := s.promotions.redeem(ctx, promo, order.UserID); err != nil {
+				return err
+			}
+		}
+		if err := s.reserveStock(ctx, items); err != nil {
+			if s.compensates() {
+				s.releasePromotions(ctx, order)
+			}
+			return err
+		}
+		if err := s.orderRepo.Save(ctx, order); err != nil {
+			if s.compensates() {
+				s.releaseStock(ctx, items)
+				s.releasePromotions(ctx, order)
+			}
+			return err
+		}
+		_, err = recordMovements(ctx, s.productRepo, s.movements, orderMovements(order, items, models.MovementSale, -1, order.UserID))
+		return err
+	})
+	if err != nil {
 		return nil, err
 	}
 	return order, nil
 }
 
+// Quote prices items as Create would, without placing the order or using up
+// the promo code.
+func (s *OrderService) Quote(ctx context.Context, userID string, reqItems []models.CreateOrderItem, deliveryMethod, code string) (*models.OrderQuote, error) {
+	if len(reqItems) == 0 {
+		return nil, ErrEmptyOrder
+	}
+	items, subtotal, err := s.priceItems(ctx, reqItems)
+	if err != nil {
+		return nil, err
+	}
+	fee := s.deliveryFeeFor(deliveryMethod, subtotal)
+	_, discounts, err := s.discount(ctx, userID, code, items, subtotal, fee, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if discounts == nil {
+		discounts = []models.OrderDiscount{}
+	}
+	discountTotal := sumDiscounts(discounts)
+	return &models.OrderQuote{
+		Subtotal:      subtotal,
+		DeliveryFee:   fee,
+		Discounts:     discounts,
+		DiscountTotal: discountTotal,
+		Total:         roundCents(subtotal + fee - discountTotal),
+	}, nil
+}
+
+// discount applies the promo code, if any, to the priced order.
+func (s *OrderService) discount(ctx context.Context, userID, code string, items []models.OrderItem, subtotal, fee float64, now time.Time) (*models.Promotion, []models.OrderDiscount, error) {
+	if strings.TrimSpace(code) == "" {
+		return nil, nil, nil
+	}
+	if s.promotions == nil {
+		return nil, nil, fmt.Errorf("%w: promo codes are not accepted", ErrPromotionNotApplicable)
+	}
+	promo, d, err := s.promotions.evaluate(ctx, code, userID, items, subtotal, fee, now)
+	if err != nil {
+		return nil, nil, err
+	}
+	return promo, []models.OrderDiscount{*d}, nil
+}
+
+// releasePromotions gives back the promo code uses of an order; failures
+// are logged rather than returned.
+func (s *OrderService) releasePromotions(ctx context.Context, order *models.Order) {
+	if s.promotions == nil {
+		return
+	}
+	for _, d := range order.Discounts {
+		if err := s.promotions.release(ctx, d.PromotionID, order.UserID); err != nil {
+			log.Printf("release promotion %s for order %s: %v", d.PromotionID, order.ID, err)
+		}
+	}
+}
+
+func (s *OrderService) deliveryFeeFor(method string, subtotal float64) float64 {
+	switch strings.ToLower(strings.TrimSpace(method)) {
+	case "courier", "post":
+		if s.freeDeliveryOver > 0 && subtotal >= s.freeDeliveryOver {
+			return 0
+		}
+		return s.deliveryFee
+	}
+	return 0
+}
+
+func sumDiscounts(discounts []models.OrderDiscount) float64 {
+	total := 0.0
+	for _, d := range discounts {
+		total += d.Amount
+	}
+	return roundCents(total)
+}
+
+func roundCents(x float64) float64 {
+	return math.Round(x*100) / 100
+}
+
+// reserveStock decrements stock line by line. If a line loses the race for
+// the last units, the lines already taken are undone by the transaction or,
+// without one, by hand.
+func (s *OrderService) reserveStock(ctx context.Context, items []models.OrderItem) error {
+	for i, it := range items {
+		err := s.productRepo.DecrementStock(ctx, it.ProductID, it.VariantID, it.Quantity)
+		if err == nil {
+			continue
+		}
+		if s.compensates() {
+			s.releaseStock(ctx, items[:i])
+		}
+		if errors.Is(err, repository.ErrInsufficientStock) {
+			verr := &OrderValidationError{}
+			verr.add(i+1, it.ProductID, LineErrInsufficientStock, fmt.Sprintf("%s (%s) is no longer available in the requested quantity", it.ProductName, it.SKU))
+			return verr
+		}
+		return err
+	}
+	return nil
+}
+
+// restoreStock puts the units of an ended order back on the shelf. Stock of
+// an order that was never paid is released; otherwise it is a return.
+func (s *OrderService) restoreStock(ctx context.Context, order *models.Order, change models.OrderStatusChange) error {
+	restored := make([]models.OrderItem, 0, len(order.Items))
+	for _, it := range order.Items {
+		variantID, err := s.itemVariant(ctx, it)
+		if err != nil {
+			return err
+		}
+		if variantID == "" {
+			continue
+		}
+		err = s.productRepo.IncrementStock(ctx, it.ProductID, variantID, it.Quantity)
+		if errors.Is(err, repository.ErrVariantNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		it.VariantID = variantID
+		restored = append(restored, it)
+	}
+	typ := models.MovementReturn
+	if change.From == models.OrderStatusPending {
+		typ = models.MovementRelease
+	}
+	_, err := recordMovements(ctx, s.productRepo, s.movements, orderMovements(order, restored, typ, 1, change.ChangedBy))
+	return err
+}
+
+// compensates reports whether a failed Create has to undo its own writes.
+// A transaction rolls them back; without one nothing else would.
+func (s *OrderService) compensates() bool {
+	_, noop := s.uow.(repository.NoopUnitOfWork)
+	return noop
+}
+
+// releaseStock is the best-effort compensation for stores without
+// transactions; failures are logged rather than returned.
+func (s *OrderService) releaseStock(ctx context.Context, items []models.OrderItem) {
+	for _, it := range items {
+		if err := s.productRepo.IncrementStock(ctx, it.ProductID, it.VariantID, it.Quantity); err != nil {
+			log.Printf("restore stock for product %s variant %s: %v", it.ProductID, it.VariantID, err)
+		}
+	}
+}
+
+// itemVariant returns the variant an order line took stock from. Lines
+// placed before variants existed are matched by size and color; if the
+// product or variant is gone there is nothing to restock.
+func (s *OrderService) itemVariant(ctx context.Context, it models.OrderItem) (string, error) {
+	if it.VariantID != "" {
+		return it.VariantID, nil
+	}
+	p, err := s.productRepo.FindByID(ctx, it.ProductID)
+	if err != nil || p == nil {
+		return "", err
+	}
+	if v := findVariant(p.Variants, it.SelectedSize, it.SelectedColor); v != nil {
+		return v.ID, nil
+	}
+	return "", nil
+}
+
+func orderMovements(order *models.Order, items []models.OrderItem, typ string, sign int, actor string) []*models.StockMovement {
+	out := make([]*models.StockMovement, 0, len(items))
+	for _, it := range items {
+		out = append(out, &models.StockMovement{
+			ProductID: it.ProductID,
+			VariantID: it.VariantID,
+			SKU:       it.SKU,
+			Size:      it.SelectedSize,
+			Color:     it.SelectedColor,
+			Type:      typ,
+			Quantity:  sign * it.Quantity,
+			OrderID:   order.ID,
+			Actor:     actor,
+		})
+	}
+	return out
+}
+
+// priceItems builds order lines from the catalog: name and unit price always
+// come from the stored product and variant, never from the client payload.
+func (s *OrderService) priceItems(ctx context.Context, reqItems []models.CreateOrderItem) ([]models.OrderItem, float64, error) {
+	verr := &OrderValidationError{}
+	products := make(map[string]*models.Product)
+	requested := make(map[string]int)
+	var subtotal float64
+	items := make([]models.OrderItem, 0, len(reqItems))
+	// One clock for the whole order, so a sale ending mid-checkout prices
+	// every line alike.
+	now := time.Now()
+	for i, it := range reqItems {
+		line := i + 1
+		if it.Quantity <= 0 {
+			verr.add(line, it.ProductID, LineErrInvalidQuantity, "quantity must be greater than 0")
+			continue
+		}
+		p, ok := products[it.ProductID]
+		if !ok {
+			found, err := s.productRepo.FindByID(ctx, it.ProductID)
+			if err != nil {
+				return nil, 0, err
+			}
+			p = found
+			products[it.ProductID] = p
+		}
+		if p == nil || p.Archived() {
+			verr.add(line, it.ProductID, LineErrProductNotFound, ErrProductNotFound.Error())
+			continue
+		}
+		if it.VariantID == "" {
+			if _, ok := matchOption(p.Sizes, it.SelectedSize); !ok {
+				verr.add(line, it.ProductID, LineErrInvalidSize, fmt.Sprintf("size %q is not available for %s", it.SelectedSize, p.Name))
+				continue
+			}
+			if _, ok := matchOption(p.Colors, it.SelectedColor); !ok {
+				verr.add(line, it.ProductID, LineErrInvalidColor, fmt.Sprintf("color %q is not available for %s", it.SelectedColor, p.Name))
+				continue
+			}
+		}
+		v := resolveVariant(p, it.VariantID, it.SelectedSize, it.SelectedColor)
+		if v == nil {
+			verr.add(line, it.ProductID, LineErrInvalidVariant, fmt.Sprintf("the selected option of %s is not offered", p.Name))
+			continue
+		}
+		key := p.ID + "|" + v.ID
+		requested[key] += it.Quantity
+		if requested[key] > v.Stock {
+			available := v.Stock
+			verr.add(line, it.ProductID, LineErrInsufficientStock, fmt.Sprintf("only %d of %s (%s) left", available, p.Name, variantLabel(v)))
+			verr.Lines[len(verr.Lines)-1].Available = &available
+			continue
+		}
+		price := effectivePrice(p, v, now)
+		lineTotal := price * float64(it.Quantity)
+		subtotal += lineTotal
+		items = append(items, models.OrderItem{
+			ProductID:     p.ID,
+			ProductName:   p.Name,
+			VariantID:     v.ID,
+			SKU:           v.SKU,
+			SelectedSize:  v.Size,
+			SelectedColor: v.Color,
+			Quantity:      it.Quantity,
+			UnitPrice:     price,
+			LineTotal:     lineTotal,
+		})
+	}
+	if len(verr.Lines) > 0 {
+		return nil, 0, verr
+	}
+	return items, subtotal, nil
+}
+
+// matchOption resolves a client-selected size or color to the product's own
+// spelling. Products without declared options accept only an empty selection.
+func matchOption(options []string, selected string) (string, bool) {
+	selected = strings.TrimSpace(selected)
+	if len(options) == 0 {
+		return "", selected == ""
+	}
+	for _, o := range options {
+		if strings.EqualFold(o, selected) {
+			return o, true
+		}
+	}
+	return "", false
+}
+
 func (s *OrderService) ListByUser(ctx context.Context, userID string) ([]*models.Order, error) {
 	return s.orderRepo.FindByUser(ctx, userID)
 }
 
-func (s *OrderService) UpdateStatus(ctx context.Context, orderID, status string) error {
-	return s.orderRepo.UpdateStatus(ctx, orderID, status)
+// UpdateStatus moves an order along its lifecycle and records who did it.
+// Stock is returned to the shelf when an order is cancelled, or refunded
+// before it was shipped. A cancelled order gives its promo code use back.
+func (s *OrderService) UpdateStatus(ctx context.Context, orderID, status, changedBy, note string) (*models.Order, error) {
+	status = strings.ToLower(strings.TrimSpace(status))
+	if _, ok := orderTransitions[status]; !ok {
+		return nil, ErrInvalidStatus
+	}
+	order, err := s.orderRepo.FindByID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order == nil {
+		return nil, ErrOrderNotFound
+	}
+	if !CanTransition(order.Status, status) {
+		return nil, ErrInvalidStatusTransition
+	}
+	change := models.OrderStatusChange{
+		From:      order.Status,
+		To:        status,
+		ChangedBy: changedBy,
+		Note:      strings.TrimSpace(note),
+		ChangedAt: time.Now(),
+	}
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		if err := s.orderRepo.UpdateStatus(ctx, orderID, change); err != nil {
+			return err
+		}
+		if change.To == models.OrderStatusCancelled {
+			s.releasePromotions(ctx, order)
+		}
+		if restocks(change.From, change.To) {
+			return s.restoreStock(ctx, order, change)
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, repository.ErrStatusConflict) {
+			return nil, ErrInvalidStatusTransition
+		}
+		return nil, err
+	}
+	order.Status = change.To
+	order.StatusHistory = append(order.StatusHistory, change)
+	order.UpdatedAt = change.ChangedAt
+	return order, nil
+}
+
+// CanTransition reports whether the lifecycle allows moving from one status
+// to another.
+func CanTransition(from, to string) bool {
+	for _, next := range orderTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// NextStatuses lists the statuses an order in the given status may move to.
+func NextStatuses(from string) []string {
+	return orderTransitions[from]
+}
+
+func restocks(from, to string) bool {
+	switch to {
+	case models.OrderStatusCancelled:
+		return true
+	case models.OrderStatusRefunded:
+		return from == models.OrderStatusPaid || from == models.OrderStatusProcessing
+	}
+	return false
 }
 
 func (s *OrderService) GetByID(ctx context.Context, orderID string) (*models.Order, error) {
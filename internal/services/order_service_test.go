@@ -0,0 +1,216 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/repository"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{models.OrderStatusPending, models.OrderStatusPaid, true},
+		{models.OrderStatusPending, models.OrderStatusCancelled, true},
+		{models.OrderStatusPending, models.OrderStatusShipped, false},
+		{models.OrderStatusPending, models.OrderStatusRefunded, false},
+		{models.OrderStatusPaid, models.OrderStatusProcessing, true},
+		{models.OrderStatusPaid, models.OrderStatusRefunded, true},
+		{models.OrderStatusPaid, models.OrderStatusPending, false},
+		{models.OrderStatusProcessing, models.OrderStatusShipped, true},
+		{models.OrderStatusProcessing, models.OrderStatusCancelled, true},
+		{models.OrderStatusShipped, models.OrderStatusDelivered, true},
+		{models.OrderStatusShipped, models.OrderStatusCancelled, false},
+		{models.OrderStatusDelivered, models.OrderStatusRefunded, true},
+		{models.OrderStatusDelivered, models.OrderStatusCancelled, false},
+		{models.OrderStatusCancelled, models.OrderStatusPending, false},
+		{models.OrderStatusRefunded, models.OrderStatusPaid, false},
+		{models.OrderStatusPaid, models.OrderStatusPaid, false},
+		{"unknown", models.OrderStatusPaid, false},
+	}
+	for _, tt := range tests {
+		if got := CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestRestocks(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{models.OrderStatusPending, models.OrderStatusCancelled, true},
+		{models.OrderStatusPaid, models.OrderStatusCancelled, true},
+		{models.OrderStatusProcessing, models.OrderStatusCancelled, true},
+		{models.OrderStatusPaid, models.OrderStatusRefunded, true},
+		{models.OrderStatusProcessing, models.OrderStatusRefunded, true},
+		// Delivered goods only come back through a return.
+		{models.OrderStatusDelivered, models.OrderStatusRefunded, false},
+		{models.OrderStatusPending, models.OrderStatusPaid, false},
+		{models.OrderStatusProcessing, models.OrderStatusShipped, false},
+		{models.OrderStatusShipped, models.OrderStatusDelivered, false},
+	}
+	for _, tt := range tests {
+		if got := restocks(tt.from, tt.to); got != tt.want {
+			t.Errorf("restocks(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestNextStatusesAreValidTransitions(t *testing.T) {
+	for from := range orderTransitions {
+		for _, to := range NextStatuses(from) {
+			if !CanTransition(from, to) {
+				t.Errorf("NextStatuses(%q) lists %q, which CanTransition rejects", from, to)
+			}
+			if _, ok := orderTransitions[to]; !ok {
+				t.Errorf("%q leads to unknown status %q", from, to)
+			}
+		}
+	}
+}
+
+func TestUpdateStatusRecordsHistoryOnce(t *testing.T) {
+	ctx := context.Background()
+	orders := repository.NewOrderRepositoryMemory()
+	s := NewOrderService(orders, repository.NewProductRepositoryMemory(), nil, nil, nil)
+	order := &models.Order{UserID: "u1", Status: models.OrderStatusPending}
+	if err := orders.Save(ctx, order); err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := s.UpdateStatus(ctx, order.ID, models.OrderStatusPaid, "admin", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := orders.FindByID(ctx, order.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, o := range map[string]*models.Order{"returned": updated, "stored": stored} {
+		if o.Status != models.OrderStatusPaid || len(o.StatusHistory) != 1 {
+			t.Errorf("%s order: status %q with %d history entries, want paid with 1", name, o.Status, len(o.StatusHistory))
+		}
+	}
+	if _, err := s.UpdateStatus(ctx, order.ID, models.OrderStatusPending, "admin", ""); !errors.Is(err, ErrInvalidStatusTransition) {
+		t.Errorf("paid to pending: err = %v, want ErrInvalidStatusTransition", err)
+	}
+}
+
+// failingOrderStore fails every Save, as a write conflict or a lost
+// connection would.
+type failingOrderStore struct {
+	*repository.OrderRepositoryMemory
+}
+
+func (failingOrderStore) Save(ctx context.Context, o *models.Order) error {
+	return errors.New("save failed")
+}
+
+// lastUnitFixture stores a product with a single unit of stock left.
+func lastUnitFixture(t *testing.T) (*repository.ProductRepositoryMemory, *repository.StockMovementRepositoryMemory, *models.CreateOrderRequest) {
+	t.Helper()
+	products := repository.NewProductRepositoryMemory()
+	p, err := products.Insert(context.Background(), &models.Product{
+		Name:     "Tee",
+		Price:    20,
+		Variants: []models.ProductVariant{{ID: "v1", SKU: "TEE-M", Size: "M", Stock: 1}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &models.CreateOrderRequest{
+		UserID: "u1",
+		Items:  []models.CreateOrderItem{{ProductID: p.ID, VariantID: "v1", Quantity: 1}},
+	}
+	return products, repository.NewStockMovementRepositoryMemory(), req
+}
+
+func variantStock(t *testing.T, products repository.ProductStore, productID string) int {
+	t.Helper()
+	p, err := products.FindByID(context.Background(), productID)
+	if err != nil || p == nil {
+		t.Fatalf("find product: %v", err)
+	}
+	return p.Variants[0].Stock
+}
+
+func TestCreateDoesNotOversell(t *testing.T) {
+	ctx := context.Background()
+	products, movements, req := lastUnitFixture(t)
+	orders := repository.NewOrderRepositoryMemory()
+	s := NewOrderService(orders, products, nil, movements, nil)
+
+	const buyers = 2
+	errs := make(chan error, buyers)
+	var wg sync.WaitGroup
+	for i := 0; i < buyers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.Create(ctx, req)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	placed := 0
+	for err := range errs {
+		var verr *OrderValidationError
+		switch {
+		case err == nil:
+			placed++
+		case errors.As(err, &verr) && verr.StockOnly():
+		default:
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if placed != 1 {
+		t.Errorf("%d orders placed, want 1", placed)
+	}
+	if stock := variantStock(t, products, req.Items[0].ProductID); stock != 0 {
+		t.Errorf("stock = %d, want 0", stock)
+	}
+	all, err := orders.FindAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 {
+		t.Errorf("%d orders stored, want 1", len(all))
+	}
+	balances, err := movements.Balances(ctx, req.Items[0].ProductID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balances["v1"] != -1 {
+		t.Errorf("ledger moved %d units, want -1", balances["v1"])
+	}
+}
+
+func TestCreateUndoesStockWhenSaveFails(t *testing.T) {
+	ctx := context.Background()
+	products, movements, req := lastUnitFixture(t)
+	orders := failingOrderStore{repository.NewOrderRepositoryMemory()}
+	s := NewOrderService(orders, products, nil, movements, nil)
+
+	if _, err := s.Create(ctx, req); err == nil {
+		t.Fatal("Create succeeded although the order was not saved")
+	}
+	if stock := variantStock(t, products, req.Items[0].ProductID); stock != 1 {
+		t.Errorf("stock = %d, want the unit back at 1", stock)
+	}
+	recorded, err := movements.FindByProduct(ctx, req.Items[0].ProductID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recorded) != 0 {
+		t.Errorf("ledger has %d movements for an order that was not placed", len(recorded))
+	}
+}
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/repository"
+)
+
+var slugSeparators = regexp.MustCompile(`[^\p{Ll}\p{Lo}\p{Nd}]+`)
+
+// EnableCategories ties products to the category taxonomy: every write must
+// name an existing category, by ID or by name, and Product.Category follows
+// the category's name. Without it the category stays free text.
+func (s *ProductService) EnableCategories(store repository.CategoryStore) {
+	s.categories = store
+}
+
+// assignCategory resolves the category a product names. CategoryID wins;
+// otherwise the free-text Category is matched by name or slug.
+func (s *ProductService) assignCategory(ctx context.Context, p *models.Product) error {
+	if s.categories == nil {
+		return nil
+	}
+	p.Category = strings.TrimSpace(p.Category)
+	if p.CategoryID == "" && p.Category == "" {
+		return nil
+	}
+	var cat *models.Category
+	var err error
+	if p.CategoryID != "" {
+		cat, err = s.categories.FindByID(ctx, p.CategoryID)
+		if err == nil && cat == nil {
+			return productError(fmt.Sprintf("category %q does not exist", p.CategoryID))
+		}
+	} else {
+		cat, err = s.findCategory(ctx, p.Category)
+		if err == nil && cat == nil {
+			return productError(fmt.Sprintf("unknown category %q", p.Category))
+		}
+	}
+	if err != nil {
+		return err
+	}
+	p.CategoryID = cat.ID
+	p.Category = cat.Name
+	return nil
+}
+
+func (s *ProductService) findCategory(ctx context.Context, name string) (*models.Category, error) {
+	all, err := s.categories.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return matchCategory(all, name), nil
+}
+
+func matchCategory(all []*models.Category, name string) *models.Category {
+	key, slug := categoryKey(name), slugify(name)
+	for _, c := range all {
+		if c.Slug == slug || categoryKey(c.Name) == key {
+			return c
+		}
+	}
+	return nil
+}
+
+// renameCategory copies a category's new name onto its products, through
+// Update so revisions and hooks see the change. CategoryService.Update runs
+// it in the unit of work that renames the category.
+func (s *ProductService) renameCategory(ctx context.Context, c *models.Category) error {
+	products, err := s.repo.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+	for _, p := range products {
+		if p.CategoryID != c.ID || p.Category == c.Name {
+			continue
+		}
+		p.Category = c.Name
+		if err := s.Update(ctx, p.ID, p); err != nil {
+			return fmt.Errorf("product %s: %w", p.ID, err)
+		}
+	}
+	return nil
+}
+
+// categoryUsage counts the products, archived ones included, assigned to a
+// category.
+func (s *ProductService) categoryUsage(ctx context.Context, id string) (int, error) {
+	products, err := s.repo.FindAll(ctx)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, p := range products {
+		if p.CategoryID == id {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// MigrateCategories assigns products stored with only a free-text category.
+// Spellings that differ in case, punctuation or plural ("T-shirts",
+// "t shirt") share one category, created from the most common spelling when
+// none matches. It returns how many products were assigned.
+func (s *ProductService) MigrateCategories(ctx context.Context) (int, error) {
+	if s.categories == nil {
+		return 0, nil
+	}
+	products, err := s.repo.FindAll(ctx)
+	if err != nil {
+		return 0, err
+	}
+	spellings := make(map[string]map[string]int)
+	var pending []*models.Product
+	for _, p := range products {
+		name := strings.Join(strings.Fields(p.Category), " ")
+		if p.CategoryID != "" || name == "" {
+			continue
+		}
+		key := categoryKey(name)
+		if spellings[key] == nil {
+			spellings[key] = make(map[string]int)
+		}
+		spellings[key][name]++
+		pending = append(pending, p)
+	}
+	if len(pending) == 0 {
+		return 0, nil
+	}
+
+	all, err := s.categories.FindAll(ctx)
+	if err != nil {
+		return 0, err
+	}
+	byKey := make(map[string]*models.Category)
+	for _, c := range all {
+		byKey[categoryKey(c.Name)] = c
+	}
+	keys := make([]string, 0, len(spellings))
+	for key := range spellings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if byKey[key] != nil {
+			continue
+		}
+		if c := matchCategory(all, key); c != nil {
+			byKey[key] = c
+			continue
+		}
+		c, err := s.insertCategory(ctx, commonSpelling(spellings[key]))
+		if err != nil {
+			return 0, err
+		}
+		byKey[key] = c
+	}
+
+	migrated := 0
+	for _, p := range pending {
+		c := byKey[categoryKey(strings.Join(strings.Fields(p.Category), " "))]
+		p.CategoryID = c.ID
+		p.Category = c.Name
+		if err := s.repo.Update(ctx, p.ID, p); err != nil {
+			return migrated, fmt.Errorf("product %s: %w", p.ID, err)
+		}
+		migrated++
+	}
+	return migrated, nil
+}
+
+// insertCategory creates a top-level category, numbering the slug when it
+// is taken.
+func (s *ProductService) insertCategory(ctx context.Context, name string) (*models.Category, error) {
+	now := time.Now()
+	base := slugify(name)
+	if base == "" {
+		base = "category"
+	}
+	for n := 1; ; n++ {
+		slug := base
+		if n > 1 {
+			slug = fmt.Sprintf("%s-%d", base, n)
+		}
+		c, err := s.categories.Insert(ctx, &models.Category{Name: name, Slug: slug, CreatedAt: now, UpdatedAt: now})
+		if errors.Is(err, repository.ErrDuplicateSlug) {
+			continue
+		}
+		return c, err
+	}
+}
+
+// commonSpelling picks the most used spelling, alphabetically first on a
+// tie, with its first letter capitalized.
+func commonSpelling(counts map[string]int) string {
+	best := ""
+	for name, n := range counts {
+		if best == "" || n > counts[best] || n == counts[best] && name < best {
+			best = name
+		}
+	}
+	r, size := utf8.DecodeRuneInString(best)
+	return string(unicode.ToUpper(r)) + best[size:]
+}
+
+// slugify lowercases s and joins its letters and digits with hyphens.
+// Letters outside ASCII are kept, so non-Latin names get a slug too.
+func slugify(s string) string {
+	return strings.Trim(slugSeparators.ReplaceAllString(strings.ToLower(s), "-"), "-")
+}
+
+// categoryKey is the slug with its last word made singular, so that
+// "Dresses" and "dress" compare equal.
+func categoryKey(name string) string {
+	slug := slugify(name)
+	head, last := "", slug
+	if i := strings.LastIndex(slug, "-"); i >= 0 {
+		head, last = slug[:i+1], slug[i+1:]
+	}
+	switch {
+	case strings.HasSuffix(last, "ies") && len(last) > 4:
+		last = strings.TrimSuffix(last, "ies") + "y"
+	case strings.HasSuffix(last, "sses"), strings.HasSuffix(last, "xes"),
+		strings.HasSuffix(last, "ches"), strings.HasSuffix(last, "shes"):
+		last = strings.TrimSuffix(last, "es")
+	case strings.HasSuffix(last, "s") && !strings.HasSuffix(last, "ss") && len(last) > 3:
+		last = strings.TrimSuffix(last, "s")
+	}
+	return head + last
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
+)
+
+// EnableImageCheck lets rollbacks leave out uploaded images whose files
+// have been deleted since.
+func (s *ProductService) EnableImageCheck(stored func(ctx context.Context, img models.ProductImage) (bool, error)) {
+	s.imageStored = stored
+}
+
+// dropMissingImages removes the uploaded images of p that are neither on
+// current nor in storage any more, and returns their keys.
+func (s *ProductService) dropMissingImages(ctx context.Context, p, current *models.Product) ([]string, error) {
+	if s.imageStored == nil {
+		return nil, nil
+	}
+	var missing []string
+	media := make([]models.ProductImage, 0, len(p.Media))
+	for _, m := range p.Media {
+		ok := false
+		for _, cur := range current.Media {
+			ok = ok || cur.Key == m.Key
+		}
+		if !ok {
+			stored, err := s.imageStored(ctx, m)
+			if err != nil {
+				return nil, err
+			}
+			ok = stored
+		}
+		if ok {
+			media = append(media, m)
+			continue
+		}
+		missing = append(missing, m.Key)
+		images := p.Images[:0]
+		for _, url := range p.Images {
+			if url != m.Large {
+				images = append(images, url)
+			}
+		}
+		p.Images = images
+	}
+	p.Media = media
+	return missing, nil
+}
+
+// AddImages appends uploaded images to the end of a product's gallery.
+func (s *ProductService) AddImages(ctx context.Context, id string, images []*models.ProductImage) (*models.Product, error) {
+	p, err := s.editable(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, img := range images {
+		if containsString(p.Images, img.Large) {
+			continue
+		}
+		p.Images = append(p.Images, img.Large)
+		p.Media = append(p.Media, *img)
+	}
+	if len(p.Images) > MaxProductImages {
+		return nil, ErrTooManyImages
+	}
+	if err := s.Update(ctx, id, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// RemoveImage drops an uploaded image from the gallery. Its files are deleted
+// by the image service once no product uses them.
+func (s *ProductService) RemoveImage(ctx context.Context, id, key string) (*models.Product, error) {
+	p, err := s.editable(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	var large string
+	for _, m := range p.Media {
+		if m.Key == key {
+			large = m.Large
+		}
+	}
+	if large == "" {
+		return nil, ErrImageNotFound
+	}
+	images := make([]string, 0, len(p.Images))
+	for _, url := range p.Images {
+		if url != large {
+			images = append(images, url)
+		}
+	}
+	p.Images = images
+	if err := s.Update(ctx, id, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ReorderImages sets the gallery order. order must hold every current image
+// URL exactly once; the first one is the product's cover.
+func (s *ProductService) ReorderImages(ctx context.Context, id string, order []string) (*models.Product, error) {
+	p, err := s.editable(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(order) != len(p.Images) {
+		return nil, ErrInvalidImageList
+	}
+	current := make(map[string]bool, len(p.Images))
+	for _, url := range p.Images {
+		current[url] = true
+	}
+	for _, url := range order {
+		if !current[url] {
+			return nil, ErrInvalidImageList
+		}
+		delete(current, url)
+	}
+	p.Images = order
+	if err := s.Update(ctx, id, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// editable returns a copy of the product that can be changed and passed to
+// Update without touching the stored value.
+func (s *ProductService) editable(ctx context.Context, id string) (*models.Product, error) {
+	p, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, ErrProductNotFound
+	}
+	cp := *p
+	cp.Images = append([]string(nil), p.Images...)
+	cp.Media = append([]models.ProductImage(nil), p.Media...)
+	cp.Variants = append([]models.ProductVariant(nil), p.Variants...)
+	return &cp, nil
+}
+
+// syncMedia keeps Media in step with Images: a request that omits media keeps
+// the stored entries, and entries whose URL left the gallery are dropped.
+func syncMedia(p, before *models.Product) {
+	if p.Media == nil && before != nil {
+		p.Media = before.Media
+	}
+	media := make([]models.ProductImage, 0, len(p.Media))
+	for _, m := range p.Media {
+		if containsString(p.Images, m.Large) {
+			media = append(media, m)
+		}
+	}
+	p.Media = media
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
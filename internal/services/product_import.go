@@ -0,0 +1,302 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
+)
+
+// MaxImportRows caps a single bulk import.
+const MaxImportRows = 5000
+
+// importBatchSize is how many rows share a transaction, keeping each one
+// well inside the database's time and size limits.
+const importBatchSize = 100
+
+var ErrImportTooLarge = fmt.Errorf("import is limited to %d rows", MaxImportRows)
+
+// ProductImportRow is one product read from an import file. Nil or empty
+// fields were left blank: they take defaults on create and leave the stored
+// value untouched on update.
+type ProductImportRow struct {
+	Line              int
+	ID                string
+	SKU               string
+	Name              string
+	Description       string
+	Category          string
+	Gender            string
+	Price             *float64
+	Sizes             []string
+	Colors            []string
+	StockBySize       map[string]int
+	Images            []string
+	LowStockThreshold *int
+	IsActive          *bool
+	// ParseError is set when a cell could not be read.
+	ParseError string
+}
+
+type ImportRowError struct {
+	Line    int    `json:"line"`
+	ID      string `json:"id,omitempty"`
+	SKU     string `json:"sku,omitempty"`
+	Message string `json:"message"`
+}
+
+type ImportReport struct {
+	DryRun  bool             `json:"dry_run"`
+	Rows    int              `json:"rows"`
+	Created int              `json:"created"`
+	Updated int              `json:"updated"`
+	Errors  []ImportRowError `json:"errors"`
+}
+
+type importPlan struct {
+	row     ProductImportRow
+	product *models.Product
+	before  *models.Product
+}
+
+// Import upserts products by ID or, when a row has none, by SKU. Every row
+// is validated first; if any row is rejected nothing is written. The writes
+// then run in batches of importBatchSize rows, each in its own unit of work;
+// a batch that fails is reported row by row and the next one still runs.
+// A dry run stops after validation.
+func (s *ProductService) Import(ctx context.Context, rows []ProductImportRow, dryRun bool) (*ImportReport, error) {
+	if len(rows) > MaxImportRows {
+		return nil, ErrImportTooLarge
+	}
+	report := &ImportReport{DryRun: dryRun, Rows: len(rows), Errors: []ImportRowError{}}
+	existing, err := s.repo.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*models.Product, len(existing))
+	bySKU := make(map[string]*models.Product, len(existing))
+	for _, p := range existing {
+		byID[p.ID] = p
+		if p.SKU != "" {
+			bySKU[p.SKU] = p
+		}
+	}
+
+	seen := make(map[string]int)
+	plans := make([]importPlan, 0, len(rows))
+	for _, row := range rows {
+		row.ID = strings.TrimSpace(row.ID)
+		row.SKU = normalizeSKU(row.SKU)
+		fail := func(msg string) {
+			report.Errors = append(report.Errors, ImportRowError{Line: row.Line, ID: row.ID, SKU: row.SKU, Message: msg})
+		}
+		if row.ParseError != "" {
+			fail(row.ParseError)
+			continue
+		}
+		// Products created before SKUs existed have none, so an exported row
+		// may carry only its ID.
+		var before *models.Product
+		switch {
+		case row.ID != "":
+			before = byID[row.ID]
+			if before == nil {
+				fail(fmt.Sprintf("no product has id %q", row.ID))
+				continue
+			}
+			if other := bySKU[row.SKU]; other != nil && other.ID != before.ID {
+				fail(fmt.Sprintf("sku %s belongs to another product", row.SKU))
+				continue
+			}
+		case row.SKU != "":
+			before = bySKU[row.SKU]
+		default:
+			fail("sku or id is required")
+			continue
+		}
+		key := "sku:" + row.SKU
+		if before != nil {
+			key = before.ID
+		}
+		if line, dup := seen[key]; dup {
+			fail(fmt.Sprintf("the same product already appears on line %d", line))
+			continue
+		}
+		seen[key] = row.Line
+		p, err := importProduct(row, before)
+		if err == nil {
+			err = s.assignCategory(ctx, p)
+		}
+		if err != nil {
+			fail(err.Error())
+			continue
+		}
+		plans = append(plans, importPlan{row: row, product: p, before: before})
+		if before == nil {
+			report.Created++
+		} else {
+			report.Updated++
+		}
+	}
+	if len(report.Errors) > 0 || dryRun {
+		return report, nil
+	}
+
+	report.Created, report.Updated = 0, 0
+	for start := 0; start < len(plans); start += importBatchSize {
+		end := start + importBatchSize
+		if end > len(plans) {
+			end = len(plans)
+		}
+		s.importBatch(ctx, plans[start:end], report)
+	}
+	return report, nil
+}
+
+// importBatch writes plans in one unit of work and the change hooks after it
+// commits. If a row fails, the whole batch is rolled back and each of its
+// rows is reported.
+func (s *ProductService) importBatch(ctx context.Context, plans []importPlan, report *ImportReport) {
+	var created, updated, failedLine int
+	err := s.writeAll(ctx, func(ctx context.Context) error {
+		created, updated, failedLine = 0, 0, 0
+		for _, plan := range plans {
+			product := *plan.product
+			var err error
+			if plan.before == nil {
+				_, err = s.insert(ctx, &product)
+			} else {
+				err = s.Update(ctx, plan.before.ID, &product)
+			}
+			if err != nil {
+				failedLine = plan.row.Line
+				return err
+			}
+			if plan.before == nil {
+				created++
+			} else {
+				updated++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		for _, plan := range plans {
+			msg := err.Error()
+			if failedLine != 0 && failedLine != plan.row.Line {
+				msg = fmt.Sprintf("not imported: line %d in the same batch failed", failedLine)
+			}
+			report.Errors = append(report.Errors, ImportRowError{Line: plan.row.Line, ID: plan.row.ID, SKU: plan.row.SKU, Message: msg})
+		}
+		return
+	}
+	report.Created += created
+	report.Updated += updated
+}
+
+// importProduct applies a row on top of the stored product, or on an empty
+// one, and validates the result the way Create and Update would.
+func importProduct(row ProductImportRow, before *models.Product) (*models.Product, error) {
+	p := &models.Product{IsActive: true}
+	if before != nil {
+		cp := *before
+		p = &cp
+	}
+	if row.SKU != "" {
+		p.SKU = row.SKU
+	}
+	setString := func(dst *string, v string) {
+		if v = strings.TrimSpace(v); v != "" {
+			*dst = v
+		}
+	}
+	setString(&p.Name, row.Name)
+	setString(&p.Description, row.Description)
+	if strings.TrimSpace(row.Category) != "" {
+		p.Category = strings.TrimSpace(row.Category)
+		p.CategoryID = ""
+	}
+	setString(&p.Gender, row.Gender)
+	if row.Price != nil {
+		p.Price = *row.Price
+	}
+	if row.Images != nil {
+		p.Images = row.Images
+	}
+	if row.LowStockThreshold != nil {
+		p.LowStockThreshold = *row.LowStockThreshold
+	}
+	if row.IsActive != nil {
+		p.IsActive = *row.IsActive
+	}
+
+	// Variants are rebuilt only when the options or stock actually change,
+	// so re-importing an export keeps per-color stock and variant prices.
+	var existing []models.ProductVariant
+	if before != nil {
+		existing = before.Variants
+	}
+	if optionsChanged(row, before) {
+		if row.Sizes != nil {
+			p.Sizes = row.Sizes
+		}
+		if row.Colors != nil {
+			p.Colors = row.Colors
+		}
+		if row.StockBySize != nil {
+			p.StockBySize = row.StockBySize
+		}
+		p.Variants = nil
+	} else if before != nil {
+		p.Variants = append([]models.ProductVariant(nil), before.Variants...)
+	}
+	fillEmpty(p)
+	if err := validateProduct(p); err != nil {
+		return nil, err
+	}
+	if err := normalizeVariants(p, existing); err != nil {
+		return nil, err
+	}
+	if before == nil {
+		now := time.Now()
+		p.CreatedAt, p.UpdatedAt = now, now
+	}
+	return p, nil
+}
+
+func optionsChanged(row ProductImportRow, before *models.Product) bool {
+	if before == nil {
+		return true
+	}
+	if row.Sizes != nil && !sameFold(row.Sizes, before.Sizes) {
+		return true
+	}
+	if row.Colors != nil && !sameFold(row.Colors, before.Colors) {
+		return true
+	}
+	if row.StockBySize != nil {
+		if len(row.StockBySize) != len(before.StockBySize) {
+			return true
+		}
+		for size, qty := range row.StockBySize {
+			if before.StockBySize[size] != qty {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func sameFold(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !strings.EqualFold(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
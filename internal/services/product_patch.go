@@ -0,0 +1,119 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
+)
+
+// Patch applies a JSON Merge Patch (RFC 7396) to a product: members present
+// in the patch replace the stored ones, null removes them and objects such as
+// stock_by_size are merged key by key. version, or a "version" member in the
+// patch, is required and must match the stored version.
+func (s *ProductService) Patch(ctx context.Context, id string, patch []byte, version int64) (*models.Product, error) {
+	var changes map[string]interface{}
+	if err := json.Unmarshal(patch, &changes); err != nil || changes == nil {
+		return nil, productError("patch must be a JSON object")
+	}
+	if v, ok := changes["version"]; ok {
+		n, isNumber := v.(float64)
+		if !isNumber || n != float64(int64(n)) {
+			return nil, productError("version must be a whole number")
+		}
+		if version == 0 {
+			version = int64(n)
+		} else if version != int64(n) {
+			return nil, ErrVersionConflict
+		}
+		delete(changes, "version")
+	}
+	if version <= 0 {
+		return nil, ErrVersionRequired
+	}
+
+	before, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if before == nil {
+		return nil, ErrProductNotFound
+	}
+	if version != before.Version {
+		return nil, ErrVersionConflict
+	}
+	p, err := applyMergePatch(before, changes)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Update(ctx, id, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func applyMergePatch(before *models.Product, changes map[string]interface{}) (*models.Product, error) {
+	current, err := json.Marshal(before)
+	if err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	if err := json.Unmarshal(current, &doc); err != nil {
+		return nil, err
+	}
+	merged, err := json.Marshal(mergePatch(doc, changes))
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(merged))
+	dec.DisallowUnknownFields()
+	var p models.Product
+	if err := dec.Decode(&p); err != nil {
+		return nil, productError(err.Error())
+	}
+	// Identity, history and media are not editable through a patch.
+	p.ID = before.ID
+	p.Version = before.Version
+	p.CreatedAt = before.CreatedAt
+	p.DeletedAt = before.DeletedAt
+	p.Media = before.Media
+	// A category named one way drops the other, which may be stale.
+	_, byName := changes["category"]
+	_, byID := changes["category_id"]
+	if byName && !byID {
+		p.CategoryID = ""
+	} else if byID && !byName {
+		p.Category = ""
+	}
+	// Options changed without variants rebuild the variants from them,
+	// keeping those whose size and color remain, with their stock.
+	if _, ok := changes["variants"]; !ok {
+		for _, key := range []string{"sizes", "colors", "stock_by_size"} {
+			if _, ok := changes[key]; ok {
+				p.Variants = nil
+				break
+			}
+		}
+	}
+	return &p, nil
+}
+
+func mergePatch(target, patch interface{}) interface{} {
+	changes, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	doc, ok := target.(map[string]interface{})
+	if !ok {
+		doc = make(map[string]interface{})
+	}
+	for key, value := range changes {
+		if value == nil {
+			delete(doc, key)
+		} else {
+			doc[key] = mergePatch(doc[key], value)
+		}
+	}
+	return doc
+}
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
+)
+
+// TestMergePatch runs the examples from RFC 7396, appendix A.
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		target, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, tt := range tests {
+		var target, patch interface{}
+		if err := json.Unmarshal([]byte(tt.target), &target); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal([]byte(tt.patch), &patch); err != nil {
+			t.Fatal(err)
+		}
+		got, err := json.Marshal(mergePatch(target, patch))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("merge %s into %s = %s, want %s", tt.patch, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	created := time.Date(2026, 1, 10, 0, 0, 0, 0, time.UTC)
+	before := func() *models.Product {
+		return &models.Product{
+			ID:          "p1",
+			SKU:         "TEE1",
+			Name:        "Tee",
+			Description: "Soft cotton",
+			Category:    "Tops",
+			CategoryID:  "tops",
+			Price:       20,
+			Sizes:       []string{"S", "M"},
+			Colors:      []string{"black"},
+			StockBySize: map[string]int{"S": 1, "M": 2},
+			Images:      []string{},
+			Media:       []models.ProductImage{{Key: "abc"}},
+			Variants: []models.ProductVariant{
+				{ID: "v1", SKU: "TEE1-S-BLACK", Size: "S", Color: "black", Stock: 1},
+				{ID: "v2", SKU: "TEE1-M-BLACK", Size: "M", Color: "black", Stock: 2},
+			},
+			IsActive:  true,
+			Version:   3,
+			CreatedAt: created,
+		}
+	}
+
+	tests := []struct {
+		name    string
+		patch   string
+		wantErr bool
+		check   func(t *testing.T, p *models.Product)
+	}{
+		{
+			name:  "replaces a member",
+			patch: `{"price": 25.5, "name": "Tee 2"}`,
+			check: func(t *testing.T, p *models.Product) {
+				if p.Price != 25.5 || p.Name != "Tee 2" || p.Description != "Soft cotton" {
+					t.Errorf("got price %v, name %q, description %q", p.Price, p.Name, p.Description)
+				}
+				if len(p.Variants) != 2 {
+					t.Errorf("variants were dropped: %v", p.Variants)
+				}
+			},
+		},
+		{
+			name:  "null removes a member",
+			patch: `{"description": null}`,
+			check: func(t *testing.T, p *models.Product) {
+				if p.Description != "" {
+					t.Errorf("description = %q", p.Description)
+				}
+			},
+		},
+		{
+			name:  "stock is merged by size and rebuilds the variants",
+			patch: `{"stock_by_size": {"M": 5}}`,
+			check: func(t *testing.T, p *models.Product) {
+				if want := map[string]int{"S": 1, "M": 5}; !reflect.DeepEqual(p.StockBySize, want) {
+					t.Errorf("stock_by_size = %v, want %v", p.StockBySize, want)
+				}
+				if p.Variants != nil {
+					t.Errorf("variants = %v, want them rebuilt", p.Variants)
+				}
+			},
+		},
+		{
+			name:  "sizes with variants keep the variants",
+			patch: `{"sizes": ["S"], "variants": [{"id": "v1", "sku": "TEE1-S-BLACK", "size": "S", "color": "black", "stock": 4}]}`,
+			check: func(t *testing.T, p *models.Product) {
+				if len(p.Variants) != 1 || p.Variants[0].Stock != 4 {
+					t.Errorf("variants = %v", p.Variants)
+				}
+			},
+		},
+		{
+			name:  "category by name drops the category id",
+			patch: `{"category": "Shirts"}`,
+			check: func(t *testing.T, p *models.Product) {
+				if p.Category != "Shirts" || p.CategoryID != "" {
+					t.Errorf("category %q, category_id %q", p.Category, p.CategoryID)
+				}
+			},
+		},
+		{
+			name:  "category by id drops the category name",
+			patch: `{"category_id": "shirts"}`,
+			check: func(t *testing.T, p *models.Product) {
+				if p.Category != "" || p.CategoryID != "shirts" {
+					t.Errorf("category %q, category_id %q", p.Category, p.CategoryID)
+				}
+			},
+		},
+		{
+			name:  "identity, history and media are kept",
+			patch: `{"id": "p2", "created_at": "2020-01-01T00:00:00Z", "deleted_at": "2020-01-01T00:00:00Z", "media": []}`,
+			check: func(t *testing.T, p *models.Product) {
+				if p.ID != "p1" || p.Version != 3 || !p.CreatedAt.Equal(created) || p.DeletedAt != nil || len(p.Media) != 1 {
+					t.Errorf("id %q, version %d, created %v, deleted %v, media %v", p.ID, p.Version, p.CreatedAt, p.DeletedAt, p.Media)
+				}
+			},
+		},
+		{
+			name:    "unknown member",
+			patch:   `{"colour": "red"}`,
+			wantErr: true,
+		},
+		{
+			name:    "wrong type",
+			patch:   `{"price": "cheap"}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var changes map[string]interface{}
+			if err := json.Unmarshal([]byte(tt.patch), &changes); err != nil {
+				t.Fatal(err)
+			}
+			orig := before()
+			p, err := applyMergePatch(orig, changes)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidProduct) {
+					t.Fatalf("err = %v, want ErrInvalidProduct", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(orig, before()) {
+				t.Error("the stored product was modified")
+			}
+			tt.check(t, p)
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"math"
+	"time"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
+)
+
+// PriceAt resolves what p sells for at the given time, sale included.
+func (s *ProductService) PriceAt(p *models.Product, at time.Time) *models.ProductPricing {
+	return resolvePricing(p, at)
+}
+
+// withPricing returns a copy of p carrying its current pricing, leaving the
+// stored product untouched.
+func withPricing(p *models.Product, at time.Time) *models.Product {
+	if p == nil {
+		return nil
+	}
+	cp := *p
+	cp.Pricing = resolvePricing(p, at)
+	return &cp
+}
+
+func withPricingAll(products []*models.Product, at time.Time) []*models.Product {
+	out := make([]*models.Product, len(products))
+	for i, p := range products {
+		out[i] = withPricing(p, at)
+	}
+	return out
+}
+
+func resolvePricing(p *models.Product, at time.Time) *models.ProductPricing {
+	pr := &models.ProductPricing{Price: p.Price}
+	if saleActive(p, at) {
+		pr.Price = *p.SalePrice
+		pr.OnSale = true
+		pr.CompareAtPrice = p.Price
+		pr.SaleEndsAt = p.SaleEndsAt
+	}
+	if p.CompareAtPrice != nil && *p.CompareAtPrice > pr.Price {
+		pr.CompareAtPrice = *p.CompareAtPrice
+	}
+	if len(p.Variants) > 0 {
+		pr.Variants = make(map[string]float64, len(p.Variants))
+		for i := range p.Variants {
+			pr.Variants[p.Variants[i].ID] = effectivePrice(p, &p.Variants[i], at)
+		}
+	}
+	return pr
+}
+
+// sellingPrice is the product-level price at the given time, the value
+// stored as CurrentPrice.
+func sellingPrice(p *models.Product, at time.Time) float64 {
+	if saleActive(p, at) {
+		return *p.SalePrice
+	}
+	return p.Price
+}
+
+// saleActive reports whether p's sale price applies at the given time. The
+// start is inclusive and the end exclusive.
+func saleActive(p *models.Product, at time.Time) bool {
+	if p.SalePrice == nil {
+		return false
+	}
+	if p.SaleStartsAt != nil && at.Before(*p.SaleStartsAt) {
+		return false
+	}
+	return p.SaleEndsAt == nil || at.Before(*p.SaleEndsAt)
+}
+
+// effectivePrice is what one unit of v sells for at the given time. During a
+// sale a variant's own price gets the product's discount ratio.
+func effectivePrice(p *models.Product, v *models.ProductVariant, at time.Time) float64 {
+	price := variantPrice(p, v)
+	if !saleActive(p, at) {
+		return price
+	}
+	if v == nil || v.Price == nil {
+		return *p.SalePrice
+	}
+	return math.Round(price**p.SalePrice/p.Price*100) / 100
+}
+
+func validatePricing(p *models.Product) error {
+	if p.SalePrice == nil {
+		if p.SaleStartsAt != nil || p.SaleEndsAt != nil {
+			return productError("sale dates need a sale price")
+		}
+	} else if *p.SalePrice <= 0 || *p.SalePrice >= p.Price {
+		return productError("sale price must be greater than 0 and below the price")
+	}
+	if p.SaleStartsAt != nil && p.SaleEndsAt != nil && !p.SaleEndsAt.After(*p.SaleStartsAt) {
+		return productError("sale must end after it starts")
+	}
+	if p.CompareAtPrice != nil && *p.CompareAtPrice <= 0 {
+		return productError("compare-at price must be greater than 0")
+	}
+	return nil
+}
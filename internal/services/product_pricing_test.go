@@ -0,0 +1,142 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
+)
+
+func TestResolvePricing(t *testing.T) {
+	now := time.Date(2026, 11, 27, 9, 0, 0, 0, time.UTC)
+	price := func(v float64) *float64 { return &v }
+	at := func(d time.Duration) *time.Time { t := now.Add(d); return &t }
+
+	tests := []struct {
+		name        string
+		product     models.Product
+		wantPrice   float64
+		wantCompare float64
+		wantOnSale  bool
+		wantEnds    *time.Time
+	}{
+		{
+			name:      "no sale",
+			product:   models.Product{Price: 100},
+			wantPrice: 100,
+		},
+		{
+			name:        "open-ended sale",
+			product:     models.Product{Price: 100, SalePrice: price(80)},
+			wantPrice:   80,
+			wantCompare: 100,
+			wantOnSale:  true,
+		},
+		{
+			name:      "sale not started",
+			product:   models.Product{Price: 100, SalePrice: price(80), SaleStartsAt: at(time.Minute)},
+			wantPrice: 100,
+		},
+		{
+			name:        "sale starts now",
+			product:     models.Product{Price: 100, SalePrice: price(80), SaleStartsAt: at(0), SaleEndsAt: at(time.Hour)},
+			wantPrice:   80,
+			wantCompare: 100,
+			wantOnSale:  true,
+			wantEnds:    at(time.Hour),
+		},
+		{
+			name:      "sale ends now",
+			product:   models.Product{Price: 100, SalePrice: price(80), SaleStartsAt: at(-time.Hour), SaleEndsAt: at(0)},
+			wantPrice: 100,
+		},
+		{
+			name:        "compare-at price without a sale",
+			product:     models.Product{Price: 100, CompareAtPrice: price(150)},
+			wantPrice:   100,
+			wantCompare: 150,
+		},
+		{
+			name:      "compare-at price below the price is not shown",
+			product:   models.Product{Price: 100, CompareAtPrice: price(90)},
+			wantPrice: 100,
+		},
+		{
+			name:        "compare-at price above the regular price during a sale",
+			product:     models.Product{Price: 100, SalePrice: price(80), CompareAtPrice: price(150)},
+			wantPrice:   80,
+			wantCompare: 150,
+			wantOnSale:  true,
+		},
+		{
+			name:        "compare-at price below the sale price during a sale",
+			product:     models.Product{Price: 100, SalePrice: price(80), CompareAtPrice: price(70)},
+			wantPrice:   80,
+			wantCompare: 100,
+			wantOnSale:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := resolvePricing(&tt.product, now)
+			if got.Price != tt.wantPrice || got.CompareAtPrice != tt.wantCompare || got.OnSale != tt.wantOnSale {
+				t.Errorf("pricing = {price %v, compare %v, on sale %v}, want {%v, %v, %v}",
+					got.Price, got.CompareAtPrice, got.OnSale, tt.wantPrice, tt.wantCompare, tt.wantOnSale)
+			}
+			if (got.SaleEndsAt == nil) != (tt.wantEnds == nil) || got.SaleEndsAt != nil && !got.SaleEndsAt.Equal(*tt.wantEnds) {
+				t.Errorf("sale ends at %v, want %v", got.SaleEndsAt, tt.wantEnds)
+			}
+			if sp := sellingPrice(&tt.product, now); sp != tt.wantPrice {
+				t.Errorf("sellingPrice = %v, want %v", sp, tt.wantPrice)
+			}
+		})
+	}
+}
+
+func TestResolvePricingVariants(t *testing.T) {
+	now := time.Date(2026, 11, 27, 9, 0, 0, 0, time.UTC)
+	sale, own := 75.0, 120.0
+	p := &models.Product{
+		Price: 100,
+		Variants: []models.ProductVariant{
+			{ID: "base", Size: "M"},
+			{ID: "own", Size: "XL", Price: &own},
+		},
+	}
+
+	got := resolvePricing(p, now)
+	if got.Variants["base"] != 100 || got.Variants["own"] != 120 {
+		t.Errorf("variant prices without a sale = %v", got.Variants)
+	}
+
+	p.SalePrice = &sale
+	got = resolvePricing(p, now)
+	// A variant's own price gets the product's discount ratio.
+	if got.Variants["base"] != 75 || got.Variants["own"] != 90 {
+		t.Errorf("variant prices during a sale = %v, want base 75 and own 90", got.Variants)
+	}
+}
+
+func TestValidatePricing(t *testing.T) {
+	price := func(v float64) *float64 { return &v }
+	start := time.Date(2026, 11, 27, 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+	tests := []struct {
+		name    string
+		product models.Product
+		ok      bool
+	}{
+		{"no sale", models.Product{Price: 100}, true},
+		{"sale", models.Product{Price: 100, SalePrice: price(80), SaleStartsAt: &start, SaleEndsAt: &end}, true},
+		{"sale price equal to the price", models.Product{Price: 100, SalePrice: price(100)}, false},
+		{"zero sale price", models.Product{Price: 100, SalePrice: price(0)}, false},
+		{"dates without a sale price", models.Product{Price: 100, SaleEndsAt: &end}, false},
+		{"ends before it starts", models.Product{Price: 100, SalePrice: price(80), SaleStartsAt: &end, SaleEndsAt: &start}, false},
+		{"zero compare-at price", models.Product{Price: 100, CompareAtPrice: price(0)}, false},
+	}
+	for _, tt := range tests {
+		if err := validatePricing(&tt.product); (err == nil) != tt.ok {
+			t.Errorf("%s: validatePricing = %v", tt.name, err)
+		}
+	}
+}
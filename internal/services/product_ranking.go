@@ -0,0 +1,26 @@
+package services
+
+import (
+	"context"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
+)
+
+// EnableRanking orders the "recommended" sort of non-text queries by r's
+// scores instead of storage order.
+func (s *ProductService) EnableRanking(r *RankingService) {
+	s.ranking = r
+}
+
+// recommended returns q sorted by the stored rank scores with the shopper's
+// affinity added; ties go to the newest product.
+func (s *ProductService) recommended(ctx context.Context, q *models.ProductQuery) (*models.ProductQuery, error) {
+	affinity, err := s.ranking.Affinity(ctx, q.UserID)
+	if err != nil {
+		return nil, err
+	}
+	ranked := *q
+	ranked.Sort = "recommended"
+	ranked.Affinity = affinity
+	return &ranked, nil
+}
@@ -0,0 +1,240 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/repository"
+)
+
+const (
+	DefaultRevisionPage = 50
+	MaxRevisionPage     = 200
+)
+
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// revisionIgnored are fields every write changes, or that change without a
+// revision; they are left out of diffs.
+var revisionIgnored = map[string]bool{
+	"id": true, "version": true, "created_at": true, "updated_at": true,
+	"rating": true, "review_count": true,
+}
+
+type actorKey struct{}
+
+// WithActor marks ctx as carrying a request made by userID. Product writes
+// record it as the author of their revision.
+func WithActor(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, actorKey{}, userID)
+}
+
+func actorFrom(ctx context.Context) string {
+	id, _ := ctx.Value(actorKey{}).(string)
+	return id
+}
+
+// EnableRevisions stores a snapshot of every product written through the
+// service, with its author and the fields that changed.
+func (s *ProductService) EnableRevisions(revisions repository.ProductRevisionStore) {
+	s.revisions = revisions
+}
+
+// recordRevision completes rev, which carries the action, from the write
+// that turned before into after. It runs in the write's unit of work, so a
+// product is never stored without its revision.
+func (s *ProductService) recordRevision(ctx context.Context, before, after *models.Product, rev *models.ProductRevision) error {
+	if s.revisions == nil {
+		return nil
+	}
+	rev.ProductID = after.ID
+	rev.Version = after.Version
+	rev.Author = actorFrom(ctx)
+	rev.Changes = diffProducts(before, after)
+	rev.Snapshot = *after
+	return s.revisions.Append(ctx, rev)
+}
+
+// Revisions lists a product's revisions, newest first.
+func (s *ProductService) Revisions(ctx context.Context, id string, limit int) ([]*models.ProductRevision, error) {
+	if limit <= 0 {
+		limit = DefaultRevisionPage
+	}
+	if limit > MaxRevisionPage {
+		limit = MaxRevisionPage
+	}
+	if s.revisions == nil {
+		return []*models.ProductRevision{}, nil
+	}
+	revs, err := s.revisions.FindByProduct(ctx, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	if revs == nil {
+		revs = []*models.ProductRevision{}
+	}
+	return revs, nil
+}
+
+// DiffRevisions compares the product at two versions. from may be newer than
+// to; the diff then reads backwards.
+func (s *ProductService) DiffRevisions(ctx context.Context, id string, from, to int64) ([]models.FieldChange, error) {
+	a, err := s.revision(ctx, id, from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := s.revision(ctx, id, to)
+	if err != nil {
+		return nil, err
+	}
+	return diffProducts(&a.Snapshot, &b.Snapshot), nil
+}
+
+// Rollback writes the product as it was at version, recorded as a new
+// revision. Stock is not rolled back: variants keep their current levels,
+// which the inventory ledger accounts for. Uploaded images deleted since
+// that version are left out; their keys are returned and kept on the
+// revision.
+func (s *ProductService) Rollback(ctx context.Context, id string, version int64) (*models.Product, []string, error) {
+	rev, err := s.revision(ctx, id, version)
+	if err != nil {
+		return nil, nil, err
+	}
+	current, err := s.editable(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	p := rev.Snapshot
+	p.ID = id
+	p.Version = current.Version
+	p.CreatedAt = current.CreatedAt
+	if current.Archived() {
+		p.IsActive = false
+	}
+	p.Images = append([]string(nil), rev.Snapshot.Images...)
+	p.Media = append([]models.ProductImage{}, rev.Snapshot.Media...)
+	missing, err := s.dropMissingImages(ctx, &p, current)
+	if err != nil {
+		return nil, nil, err
+	}
+	p.Variants = make([]models.ProductVariant, len(rev.Snapshot.Variants))
+	for i, v := range rev.Snapshot.Variants {
+		v.Stock = 0
+		for _, cur := range current.Variants {
+			if cur.ID == v.ID {
+				v.Stock = cur.Stock
+			}
+		}
+		p.Variants[i] = v
+	}
+	err = s.update(ctx, id, &p, &models.ProductRevision{Action: models.RevisionRollback, RollbackOf: version, MissingImages: missing})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &p, missing, nil
+}
+
+func (s *ProductService) revision(ctx context.Context, id string, version int64) (*models.ProductRevision, error) {
+	if s.revisions == nil {
+		return nil, ErrRevisionNotFound
+	}
+	rev, err := s.revisions.FindVersion(ctx, id, version)
+	if err != nil {
+		return nil, err
+	}
+	if rev == nil {
+		return nil, ErrRevisionNotFound
+	}
+	return rev, nil
+}
+
+// diffProducts lists the JSON fields that differ between two products,
+// sorted by path. Objects are compared member by member, variants by SKU and
+// other arrays as a whole; empty values count as absent. before may be nil for a new product.
+func diffProducts(before, after *models.Product) []models.FieldChange {
+	from, to := flattenProduct(before), flattenProduct(after)
+	changes := []models.FieldChange{}
+	for field, value := range to {
+		if !bytes.Equal(from[field], value) {
+			changes = append(changes, models.FieldChange{Field: field, From: orNull(from[field]), To: value})
+		}
+	}
+	for field, value := range from {
+		if _, ok := to[field]; !ok {
+			changes = append(changes, models.FieldChange{Field: field, From: value, To: orNull(nil)})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes
+}
+
+func flattenProduct(p *models.Product) map[string]json.RawMessage {
+	out := make(map[string]json.RawMessage)
+	if p == nil {
+		return out
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		return out
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return out
+	}
+	for field := range revisionIgnored {
+		delete(doc, field)
+	}
+	if list, ok := doc["variants"].([]interface{}); ok {
+		bySKU := make(map[string]interface{}, len(list))
+		for _, v := range list {
+			if obj, ok := v.(map[string]interface{}); ok {
+				sku, _ := obj["sku"].(string)
+				delete(obj, "sku")
+				bySKU[sku] = obj
+			}
+		}
+		doc["variants"] = bySKU
+	}
+	flatten("", doc, out)
+	return out
+}
+
+func flatten(prefix string, value interface{}, out map[string]json.RawMessage) {
+	switch v := value.(type) {
+	case nil:
+		return
+	case map[string]interface{}:
+		for k, member := range v {
+			path := k
+			if prefix != "" {
+				path = prefix + "." + k
+			}
+			flatten(path, member, out)
+		}
+		return
+	case []interface{}:
+		if len(v) == 0 {
+			return
+		}
+	case string:
+		if v == "" {
+			return
+		}
+	}
+	// Maps marshal with sorted keys, so equal values give equal bytes.
+	data, err := json.Marshal(value)
+	if err == nil {
+		out[prefix] = data
+	}
+}
+
+func orNull(v json.RawMessage) json.RawMessage {
+	if v == nil {
+		return json.RawMessage("null")
+	}
+	return v
+}
@@ -2,46 +2,585 @@ package services
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
 	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/repository"
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/search"
 )
 
+const (
+	DefaultPageSize = 24
+	MaxPageSize     = 100
+	// maxSearchHits caps how many ranked matches a text query considers.
+	maxSearchHits = 500
+)
+
+var (
+	ErrInvalidCursor    = errors.New("invalid cursor")
+	ErrInvalidThreshold = errors.New("low stock threshold must not be negative")
+	ErrDuplicateSKU     = errors.New("sku is already used by another product")
+	ErrNotArchived      = errors.New("only archived products can be purged")
+	ErrVersionConflict  = errors.New("product was changed by someone else; reload and try again")
+	ErrVersionRequired  = errors.New("send the product version in If-Match or as version")
+	ErrInvalidProduct   = errors.New("invalid product")
+)
+
+// productError is a validation failure reported with its own message; it
+// matches ErrInvalidProduct.
+type productError string
+
+func (e productError) Error() string        { return string(e) }
+func (e productError) Is(target error) bool { return target == ErrInvalidProduct }
+
+// ProductHook is notified after a product is written. before is nil for a
+// newly created product and after is nil for a deleted one.
+type ProductHook func(ctx context.Context, before, after *models.Product)
+
 type ProductService struct {
-	repo repository.ProductStore
+	repo       repository.ProductStore
+	hooks      []ProductHook
+	index      *search.Index
+	suggester  *search.Suggester
+	ledger     repository.StockMovementStore
+	revisions  repository.ProductRevisionStore
+	categories repository.CategoryStore
+	ranking    *RankingService
+	// imageStored reports whether an uploaded image's files still exist.
+	imageStored func(ctx context.Context, img models.ProductImage) (bool, error)
+	uow         repository.UnitOfWork
 }
 
 func NewProductService(repo repository.ProductStore) *ProductService {
-	return &ProductService{repo: repo}
+	return &ProductService{repo: repo, uow: repository.NoopUnitOfWork{}}
+}
+
+// EnableTransactions runs writes that span several stores, such as an
+// import, inside uow.
+func (s *ProductService) EnableTransactions(uow repository.UnitOfWork) {
+	s.uow = uow
+}
+
+// OnChange registers a hook run after every create, update and delete.
+// Hooks are registered at startup, before the service handles requests.
+func (s *ProductService) OnChange(h ProductHook) {
+	s.hooks = append(s.hooks, h)
+}
+
+// pendingChangesKey marks a context whose writes have not committed yet:
+// notify queues the change on it instead of running the hooks, which may
+// delete images or update the search index and cannot be rolled back.
+type pendingChangesKey struct{}
+
+type pendingChange struct {
+	before, after *models.Product
+}
+
+func (s *ProductService) notify(ctx context.Context, before, after *models.Product) {
+	if pending, ok := ctx.Value(pendingChangesKey{}).(*[]pendingChange); ok {
+		*pending = append(*pending, pendingChange{before: before, after: after})
+		return
+	}
+	for _, h := range s.hooks {
+		h(ctx, before, after)
+	}
+}
+
+// writeAll runs fn in one unit of work and the change hooks of its writes
+// only once it commits.
+func (s *ProductService) writeAll(ctx context.Context, fn func(ctx context.Context) error) error {
+	var pending []pendingChange
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		// The unit of work may retry fn, so each attempt starts over.
+		pending = pending[:0]
+		return fn(context.WithValue(ctx, pendingChangesKey{}, &pending))
+	})
+	if err != nil {
+		return err
+	}
+	for _, c := range pending {
+		s.notify(ctx, c.before, c.after)
+	}
+	return nil
+}
+
+// EnableLedger records the stock set through Create and Update as ledger
+// movements, so edits in the product form leave an audit trail. The
+// movements are written in the same unit of work as the product.
+func (s *ProductService) EnableLedger(ledger repository.StockMovementStore) {
+	s.ledger = ledger
+}
+
+func (s *ProductService) recordStockChanges(ctx context.Context, productID string, before, after *models.Product, typ, reason string) error {
+	if s.ledger == nil {
+		return nil
+	}
+	return s.ledger.Append(ctx, stockChanges(productID, before, after, typ, reason)...)
+}
+
+// MoveStock applies a signed ledger movement to one variant and appends it
+// to ledger with the resulting balance, in one unit of work. The variant's
+// SKU, size and color are filled in on m.
+func (s *ProductService) MoveStock(ctx context.Context, ledger repository.StockMovementStore, m *models.StockMovement) error {
+	before, err := s.repo.FindByID(ctx, m.ProductID)
+	if err != nil {
+		return err
+	}
+	if before == nil {
+		return ErrProductNotFound
+	}
+	v := variantByID(before.Variants, m.VariantID)
+	if v == nil {
+		return ErrVariantNotFound
+	}
+	m.SKU, m.Size, m.Color = v.SKU, v.Size, v.Color
+	var after *models.Product
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		m.ID = ""
+		var err error
+		if m.Quantity < 0 {
+			err = s.repo.DecrementStock(ctx, m.ProductID, v.ID, -m.Quantity)
+		} else {
+			err = s.repo.IncrementStock(ctx, m.ProductID, v.ID, m.Quantity)
+		}
+		if err != nil {
+			return err
+		}
+		after, err = recordMovements(ctx, s.repo, ledger, []*models.StockMovement{m})
+		return err
+	})
+	switch {
+	case errors.Is(err, repository.ErrInsufficientStock):
+		return ErrInsufficientStock
+	case errors.Is(err, repository.ErrVariantNotFound):
+		return ErrVariantNotFound
+	case err != nil:
+		return err
+	}
+	if after != nil {
+		s.notify(ctx, before, after)
+	}
+	return nil
+}
+
+// EnableSearch loads the catalog into idx, keeps it current through OnChange
+// and answers text queries from it instead of substring matching.
+func (s *ProductService) EnableSearch(ctx context.Context, idx *search.Index) error {
+	products, err := s.repo.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+	idx.Rebuild(products)
+	s.OnChange(idx.ProductChanged)
+	s.index = idx
+	return nil
+}
+
+// EnableSuggestions builds sg from the catalog, schedules a rebuild on every
+// product change and feeds it the text queries that return results. The
+// caller runs sg.Run.
+func (s *ProductService) EnableSuggestions(ctx context.Context, sg *search.Suggester) error {
+	if err := sg.Rebuild(ctx); err != nil {
+		return err
+	}
+	s.OnChange(sg.ProductChanged)
+	s.suggester = sg
+	return nil
+}
+
+func (s *ProductService) Suggest(prefix string, limit int) search.Suggestions {
+	if s.suggester == nil {
+		return search.Suggestions{Products: []search.Suggestion{}, Categories: []search.Suggestion{}, Queries: []search.Suggestion{}}
+	}
+	return s.suggester.Suggest(prefix, limit)
+}
+
+func (s *ProductService) recordQuery(q string, hits int) {
+	if s.suggester != nil && hits > 0 {
+		s.suggester.RecordQuery(q)
+	}
 }
 
 func (s *ProductService) List(ctx context.Context) ([]*models.Product, error) {
-	return s.repo.FindAll(ctx)
+	products, err := s.repo.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return withPricingAll(products, time.Now()), nil
+}
+
+// Search returns one page of products matching q and the cursor of the next
+// page, if any. Text queries are ranked by relevance unless another sort is
+// requested; other queries sorted "recommended" follow the ranking, if
+// enabled.
+func (s *ProductService) Search(ctx context.Context, q *models.ProductQuery) (*models.ProductPage, error) {
+	offset, err := decodeCursor(q.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+	if strings.TrimSpace(q.Text) == "" || s.index == nil {
+		if strings.TrimSpace(q.Text) == "" && s.ranking != nil && (q.Sort == "" || q.Sort == "recommended") {
+			if q, err = s.recommended(ctx, q); err != nil {
+				return nil, err
+			}
+		}
+		page, err := s.queryPage(ctx, q, offset, limit)
+		if err != nil {
+			return nil, err
+		}
+		page.Items = withPricingAll(page.Items, time.Now())
+		return page, nil
+	}
+
+	hits := s.index.Search(q.Text, maxSearchHits)
+	if q.Cursor == "" {
+		s.recordQuery(q.Text, len(hits))
+	}
+	filtered := *q
+	filtered.Text = ""
+	filtered.IDs = make([]string, len(hits))
+	snippets := make(map[string]string, len(hits))
+	for i, h := range hits {
+		filtered.IDs[i] = h.ID
+		snippets[h.ID] = h.Snippet
+	}
+
+	if q.Sort == "" || q.Sort == "recommended" {
+		filtered.Sort = "relevance"
+	}
+	page, err := s.queryPage(ctx, &filtered, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	page.Snippets = make(map[string]string, len(page.Items))
+	for _, p := range page.Items {
+		page.Snippets[p.ID] = snippets[p.ID]
+	}
+	page.Items = withPricingAll(page.Items, time.Now())
+	return page, nil
+}
+
+func (s *ProductService) queryPage(ctx context.Context, q *models.ProductQuery, offset, limit int) (*models.ProductPage, error) {
+	items, err := s.repo.Query(ctx, q, offset, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	total, err := s.repo.Count(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	page := &models.ProductPage{Items: items, Total: total}
+	if len(items) > limit {
+		page.Items = items[:limit]
+		page.NextCursor = encodeCursor(offset + limit)
+	}
+	return page, nil
+}
+
+// SearchText returns the best matches for text with highlighted name and
+// description excerpts.
+func (s *ProductService) SearchText(ctx context.Context, text string, limit int) ([]models.SearchResult, error) {
+	results := []models.SearchResult{}
+	if s.index == nil {
+		return results, nil
+	}
+	hits := s.index.Search(text, limit)
+	s.recordQuery(text, len(hits))
+	if len(hits) == 0 {
+		return results, nil
+	}
+	ids := make([]string, len(hits))
+	for i, h := range hits {
+		ids[i] = h.ID
+	}
+	products, err := s.repo.Query(ctx, &models.ProductQuery{IDs: ids}, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*models.Product, len(products))
+	for _, p := range products {
+		byID[p.ID] = p
+	}
+	now := time.Now()
+	for _, h := range hits {
+		if p, ok := byID[h.ID]; ok {
+			results = append(results, models.SearchResult{Product: withPricing(p, now), Score: h.Score, Name: h.Name, Snippet: h.Snippet})
+		}
+	}
+	return results, nil
+}
+
+func (s *ProductService) Facets(ctx context.Context) (*models.ProductFacets, error) {
+	return s.repo.Facets(ctx)
 }
 
 func (s *ProductService) GetByID(ctx context.Context, id string) (*models.Product, error) {
-	return s.repo.FindByID(ctx, id)
+	p, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return withPricing(p, time.Now()), nil
 }
 
 func (s *ProductService) Create(ctx context.Context, req *models.CreateProductRequest) (*models.Product, error) {
 	now := time.Now()
 	p := &models.Product{
-		Name:        req.Name,
-		Description: req.Description,
-		Category:    req.Category,
-		Gender:      normalizeGender(req.Gender),
-		Price:       req.Price,
-		Sizes:       req.Sizes,
-		Colors:      req.Colors,
-		StockBySize: req.StockBySize,
-		Images:      req.Images,
-		IsActive:    true,
-		CreatedAt:   now,
-		UpdatedAt:   now,
+		SKU:               req.SKU,
+		Name:              req.Name,
+		Description:       req.Description,
+		Category:          req.Category,
+		CategoryID:        strings.TrimSpace(req.CategoryID),
+		Gender:            req.Gender,
+		Price:             req.Price,
+		SalePrice:         req.SalePrice,
+		SaleStartsAt:      req.SaleStartsAt,
+		SaleEndsAt:        req.SaleEndsAt,
+		CompareAtPrice:    req.CompareAtPrice,
+		Sizes:             req.Sizes,
+		Colors:            req.Colors,
+		StockBySize:       req.StockBySize,
+		Images:            req.Images,
+		Media:             req.Media,
+		Variants:          req.Variants,
+		IsActive:          true,
+		LowStockThreshold: req.LowStockThreshold,
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}
+	return s.insert(ctx, p)
+}
+
+func (s *ProductService) insert(ctx context.Context, p *models.Product) (*models.Product, error) {
+	p.Pricing = nil
+	p.Rating, p.ReviewCount = 0, 0
+	fillEmpty(p)
+	if err := validateProduct(p); err != nil {
+		return nil, err
+	}
+	if err := s.assignCategory(ctx, p); err != nil {
+		return nil, err
+	}
+	p.SKU = normalizeSKU(p.SKU)
+	if err := normalizeVariants(p, nil); err != nil {
+		return nil, err
+	}
+	syncMedia(p, nil)
+	p.CurrentPrice = sellingPrice(p, time.Now())
+	var created *models.Product
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		var err error
+		created, err = s.repo.Insert(ctx, p)
+		if err != nil {
+			return err
+		}
+		if err := s.recordStockChanges(ctx, created.ID, nil, created, models.MovementReceipt, "initial stock"); err != nil {
+			return err
+		}
+		return s.recordRevision(ctx, nil, created, &models.ProductRevision{Action: models.RevisionCreate})
+	})
+	if errors.Is(err, repository.ErrDuplicateSKU) {
+		return nil, ErrDuplicateSKU
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.notify(ctx, nil, created)
+	return created, nil
+}
+
+// Update replaces a product, failing with ErrVersionConflict unless
+// p.Version is the stored version. Internal callers that have just loaded the
+// product may pass zero to use the version Update reads; the write still
+// fails if the product changes before it lands.
+func (s *ProductService) Update(ctx context.Context, id string, p *models.Product) error {
+	return s.update(ctx, id, p, &models.ProductRevision{Action: models.RevisionUpdate})
+}
+
+func (s *ProductService) update(ctx context.Context, id string, p *models.Product, rev *models.ProductRevision) error {
+	before, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if before == nil {
+		return ErrProductNotFound
+	}
+	if p.Version == 0 {
+		p.Version = before.Version
+	} else if p.Version != before.Version {
+		return ErrVersionConflict
+	}
+	p.ID = id
+	p.Pricing = nil
+	// Archiving only changes through Delete and Restore, ratings through
+	// reviews.
+	p.DeletedAt = before.DeletedAt
+	p.Rating, p.ReviewCount = before.Rating, before.ReviewCount
+	fillEmpty(p)
+	if err := validateProduct(p); err != nil {
+		return err
+	}
+	if err := s.assignCategory(ctx, p); err != nil {
+		return err
+	}
+	p.SKU = normalizeSKU(p.SKU)
+	if err := normalizeVariants(p, before.Variants); err != nil {
+		return err
+	}
+	syncMedia(p, before)
+	p.UpdatedAt = time.Now()
+	p.CurrentPrice = sellingPrice(p, p.UpdatedAt)
+	version := p.Version
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		// The store bumps p.Version, so a retried attempt starts from the
+		// version that was read.
+		p.Version = version
+		if err := storeError(s.repo.Update(ctx, id, p)); err != nil {
+			return err
+		}
+		if err := s.recordStockChanges(ctx, id, before, p, models.MovementAdjustment, "product edit"); err != nil {
+			return err
+		}
+		return s.recordRevision(ctx, before, p, rev)
+	})
+	if err != nil {
+		return err
+	}
+	s.notify(ctx, before, p)
+	return nil
+}
+
+// Delete archives a product: it leaves the shop but stays resolvable for
+// past orders and analytics until it is purged.
+func (s *ProductService) Delete(ctx context.Context, id string) error {
+	before, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if before == nil || before.Archived() {
+		return nil
+	}
+	now := time.Now()
+	after := *before
+	after.IsActive = false
+	after.DeletedAt = &now
+	return s.replace(ctx, before, &after, models.RevisionArchive)
+}
+
+// Restore brings an archived product back to the shop.
+func (s *ProductService) Restore(ctx context.Context, id string) (*models.Product, error) {
+	before, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if before == nil {
+		return nil, ErrProductNotFound
+	}
+	if !before.Archived() {
+		return before, nil
+	}
+	after := *before
+	after.IsActive = true
+	after.DeletedAt = nil
+	if err := s.replace(ctx, before, &after, models.RevisionRestore); err != nil {
+		return nil, err
+	}
+	return &after, nil
+}
+
+// Purge removes an archived product and, through the hooks, its images.
+func (s *ProductService) Purge(ctx context.Context, id string) error {
+	before, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if before == nil {
+		return ErrProductNotFound
+	}
+	if !before.Archived() {
+		return ErrNotArchived
+	}
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.notify(ctx, before, nil)
+	return nil
+}
+
+// replace stores after without the normalization Update applies, for changes
+// that only touch the archive state.
+func (s *ProductService) replace(ctx context.Context, before, after *models.Product, action string) error {
+	version := after.Version
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		after.Version = version
+		if err := storeError(s.repo.Update(ctx, before.ID, after)); err != nil {
+			return err
+		}
+		return s.recordRevision(ctx, before, after, &models.ProductRevision{Action: action})
+	})
+	if err != nil {
+		return err
+	}
+	s.notify(ctx, before, after)
+	return nil
+}
+
+// storeError maps repository write errors to the service's own.
+func storeError(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrDuplicateSKU):
+		return ErrDuplicateSKU
+	case errors.Is(err, repository.ErrVersionConflict):
+		return ErrVersionConflict
 	}
+	return err
+}
+
+// validateProduct holds the rules every write shares: Create, Update, Patch
+// and Import. It normalizes the gender in place.
+func validateProduct(p *models.Product) error {
+	if strings.TrimSpace(p.Name) == "" {
+		return productError("name is required")
+	}
+	if p.Price <= 0 {
+		return productError("price must be greater than 0")
+	}
+	if err := validatePricing(p); err != nil {
+		return err
+	}
+	if p.LowStockThreshold < 0 {
+		return ErrInvalidThreshold
+	}
+	if len(p.Images) > MaxProductImages {
+		return ErrTooManyImages
+	}
+	p.Gender = normalizeGender(p.Gender)
+	// Variants, when given, define the sizes themselves.
+	if len(p.Variants) == 0 {
+		for size := range p.StockBySize {
+			if size == "" && len(p.Sizes) == 0 {
+				continue
+			}
+			if _, ok := matchOption(p.Sizes, size); !ok {
+				return productError(fmt.Sprintf("stock is given for size %q, which is not one of the sizes", size))
+			}
+		}
+	}
+	return nil
+}
+
+func fillEmpty(p *models.Product) {
 	if p.Sizes == nil {
 		p.Sizes = []string{}
 	}
@@ -54,20 +593,10 @@ func (s *ProductService) Create(ctx context.Context, req *models.CreateProductRe
 	if p.Images == nil {
 		p.Images = []string{}
 	}
-	if p.Price <= 0 {
-		return nil, errors.New("price must be greater than 0")
-	}
-	return s.repo.Insert(ctx, p)
-}
-
-func (s *ProductService) Update(ctx context.Context, id string, p *models.Product) error {
-	p.Gender = normalizeGender(p.Gender)
-	p.UpdatedAt = time.Now()
-	return s.repo.Update(ctx, id, p)
 }
 
-func (s *ProductService) Delete(ctx context.Context, id string) error {
-	return s.repo.Delete(ctx, id)
+func normalizeSKU(value string) string {
+	return strings.ToUpper(strings.TrimSpace(value))
 }
 
 func normalizeGender(value string) string {
@@ -81,3 +610,22 @@ func normalizeGender(value string) string {
 		return ""
 	}
 }
+
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	offset, err := strconv.Atoi(string(raw))
+	if err != nil || offset < 0 {
+		return 0, ErrInvalidCursor
+	}
+	return offset, nil
+}
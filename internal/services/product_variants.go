@@ -0,0 +1,242 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/repository"
+)
+
+var ErrInvalidVariant = errors.New("invalid variant")
+
+// normalizeVariants makes p.Variants the source of truth. Products sent
+// without variants have them exploded from Sizes, Colors and StockBySize,
+// reusing the IDs, SKUs and stock of existing variants with the same options. The
+// derived Sizes, Colors and StockBySize are then rebuilt from the variants.
+func normalizeVariants(p *models.Product, existing []models.ProductVariant) error {
+	if len(p.Variants) == 0 {
+		p.Variants = explodeVariants(p, existing)
+	}
+	prefix := ""
+	seenOptions := make(map[string]bool)
+	seenSKUs := make(map[string]bool)
+	for i := range p.Variants {
+		v := &p.Variants[i]
+		v.Size = strings.TrimSpace(v.Size)
+		v.Color = strings.TrimSpace(v.Color)
+		v.SKU = strings.TrimSpace(v.SKU)
+		key := strings.ToLower(v.Size + "|" + v.Color)
+		if seenOptions[key] {
+			return fmt.Errorf("%w: size %q and color %q listed twice", ErrInvalidVariant, v.Size, v.Color)
+		}
+		seenOptions[key] = true
+		if v.Stock < 0 {
+			return fmt.Errorf("%w: stock for %s cannot be negative", ErrInvalidVariant, variantLabel(v))
+		}
+		if v.Price != nil && *v.Price <= 0 {
+			return fmt.Errorf("%w: price for %s must be greater than 0", ErrInvalidVariant, variantLabel(v))
+		}
+		if v.ID == "" {
+			id, err := newID()
+			if err != nil {
+				return err
+			}
+			v.ID = id
+		}
+		if v.SKU == "" {
+			if prefix == "" {
+				prefix = skuPrefix(p)
+			}
+			v.SKU = variantSKU(prefix, v)
+		}
+		if seenSKUs[v.SKU] {
+			return fmt.Errorf("%w: SKU %q is used twice", ErrInvalidVariant, v.SKU)
+		}
+		seenSKUs[v.SKU] = true
+	}
+	p.Sizes = distinct(p.Variants, func(v models.ProductVariant) string { return v.Size })
+	p.Colors = distinct(p.Variants, func(v models.ProductVariant) string { return v.Color })
+	p.StockBySize = repository.StockBySize(p.Variants)
+	return nil
+}
+
+// explodeVariants creates one variant per size and color. Pairs that
+// already exist keep their stock, and a change to a size's total in
+// StockBySize is applied to them: added units go to the first color, removed
+// units are taken from the first colors that have them. New colors of an
+// existing size start at 0. Only sizes with no existing variants have their
+// stock split evenly across colors, the remainder going to the first ones.
+func explodeVariants(p *models.Product, existing []models.ProductVariant) []models.ProductVariant {
+	sizes := append([]string(nil), p.Sizes...)
+	var extra []string
+	for size := range p.StockBySize {
+		if _, ok := matchOption(sizes, size); !ok {
+			extra = append(extra, size)
+		}
+	}
+	sort.Strings(extra)
+	sizes = append(sizes, extra...)
+	if len(sizes) == 0 {
+		sizes = []string{""}
+	}
+	colors := p.Colors
+	if len(colors) == 0 {
+		colors = []string{""}
+	}
+	out := make([]models.ProductVariant, 0, len(sizes)*len(colors))
+	for _, size := range sizes {
+		row := make([]models.ProductVariant, len(colors))
+		kept, total := false, 0
+		for i, color := range colors {
+			row[i] = models.ProductVariant{Size: size, Color: color}
+			if old := findVariant(existing, size, color); old != nil {
+				v := &row[i]
+				v.ID, v.SKU, v.Price, v.Barcode, v.Images, v.Stock = old.ID, old.SKU, old.Price, old.Barcode, old.Images, old.Stock
+				kept = true
+				total += old.Stock
+			}
+		}
+		stock := p.StockBySize[size]
+		if !kept {
+			for i := range row {
+				row[i].Stock = stock / len(colors)
+				if i < stock%len(colors) {
+					row[i].Stock++
+				}
+			}
+		} else if delta := stock - total; delta > 0 {
+			row[0].Stock += delta
+		} else {
+			for i := range row {
+				take := min(row[i].Stock, -delta)
+				row[i].Stock -= take
+				delta += take
+			}
+		}
+		out = append(out, row...)
+	}
+	return out
+}
+
+// resolveVariant finds the variant a customer picked, by ID or, for clients
+// that only send options, by size and color.
+func resolveVariant(p *models.Product, variantID, size, color string) *models.ProductVariant {
+	if variantID != "" {
+		return variantByID(p.Variants, variantID)
+	}
+	return findVariant(p.Variants, strings.TrimSpace(size), strings.TrimSpace(color))
+}
+
+func variantByID(variants []models.ProductVariant, id string) *models.ProductVariant {
+	for i := range variants {
+		if variants[i].ID == id {
+			return &variants[i]
+		}
+	}
+	return nil
+}
+
+func findVariant(variants []models.ProductVariant, size, color string) *models.ProductVariant {
+	for i := range variants {
+		if strings.EqualFold(variants[i].Size, size) && strings.EqualFold(variants[i].Color, color) {
+			return &variants[i]
+		}
+	}
+	return nil
+}
+
+func variantPrice(p *models.Product, v *models.ProductVariant) float64 {
+	if v != nil && v.Price != nil {
+		return *v.Price
+	}
+	return p.Price
+}
+
+func variantLabel(v *models.ProductVariant) string {
+	parts := []string{}
+	for _, s := range []string{v.Size, v.Color} {
+		if s != "" {
+			parts = append(parts, s)
+		}
+	}
+	if len(parts) == 0 {
+		return "the default variant"
+	}
+	return strings.Join(parts, " / ")
+}
+
+func skuPrefix(p *models.Product) string {
+	if p.SKU != "" {
+		return p.SKU
+	}
+	var b strings.Builder
+	for _, word := range strings.Fields(strings.ToUpper(p.Name)) {
+		for _, r := range word {
+			if r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+				b.WriteRune(r)
+				break
+			}
+		}
+		if b.Len() == 3 {
+			break
+		}
+	}
+	suffix, err := newID()
+	if err != nil {
+		suffix = "000000"
+	}
+	return b.String() + strings.ToUpper(suffix[:6])
+}
+
+func variantSKU(prefix string, v *models.ProductVariant) string {
+	parts := []string{prefix}
+	for _, s := range []string{v.Size, v.Color} {
+		if s = strings.ToUpper(strings.Join(strings.Fields(s), "")); s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
+func distinct(variants []models.ProductVariant, field func(models.ProductVariant) string) []string {
+	out := []string{}
+	seen := make(map[string]bool)
+	for _, v := range variants {
+		value := field(v)
+		key := strings.ToLower(value)
+		if value == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, value)
+	}
+	return out
+}
+
+// MigrateVariants converts products stored before variants existed,
+// splitting their per-size stock across colors. It returns how many products
+// were converted.
+func (s *ProductService) MigrateVariants(ctx context.Context) (int, error) {
+	products, err := s.repo.FindAll(ctx)
+	if err != nil {
+		return 0, err
+	}
+	migrated := 0
+	for _, p := range products {
+		if len(p.Variants) > 0 {
+			continue
+		}
+		if err := normalizeVariants(p, nil); err != nil {
+			return migrated, fmt.Errorf("product %s: %w", p.ID, err)
+		}
+		if err := s.repo.Update(ctx, p.ID, p); err != nil {
+			return migrated, fmt.Errorf("product %s: %w", p.ID, err)
+		}
+		migrated++
+	}
+	return migrated, nil
+}
@@ -0,0 +1,296 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/repository"
+)
+
+var (
+	ErrPromotionNotFound      = errors.New("promotion not found")
+	ErrInvalidPromotion       = errors.New("invalid promotion")
+	ErrDuplicatePromoCode     = errors.New("code is already used by another promotion")
+	ErrPromotionNotApplicable = errors.New("promo code cannot be applied")
+	ErrPromotionUsedUp        = errors.New("promo code has reached its usage limit")
+)
+
+var validPromoCode = regexp.MustCompile(`^[A-Z0-9_-]{3,32}$`)
+
+// PromotionService manages discount codes and works out what they take off
+// an order.
+type PromotionService struct {
+	store      repository.PromotionStore
+	products   repository.ProductStore
+	categories *CategoryService
+}
+
+func NewPromotionService(store repository.PromotionStore, products repository.ProductStore, categories *CategoryService) *PromotionService {
+	return &PromotionService{store: store, products: products, categories: categories}
+}
+
+func (s *PromotionService) List(ctx context.Context) ([]*models.Promotion, error) {
+	return s.store.FindAll(ctx)
+}
+
+func (s *PromotionService) Get(ctx context.Context, id string) (*models.Promotion, error) {
+	p, err := s.store.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, ErrPromotionNotFound
+	}
+	return p, nil
+}
+
+func (s *PromotionService) Create(ctx context.Context, p *models.Promotion) (*models.Promotion, error) {
+	if err := validatePromotion(p); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	p.ID = ""
+	p.UsedCount = 0
+	p.CreatedAt, p.UpdatedAt = now, now
+	if err := s.store.Insert(ctx, p); err != nil {
+		if errors.Is(err, repository.ErrDuplicatePromoCode) {
+			return nil, ErrDuplicatePromoCode
+		}
+		return nil, err
+	}
+	return p, nil
+}
+
+// Update replaces a promotion's settings; its usage count is kept.
+func (s *PromotionService) Update(ctx context.Context, id string, p *models.Promotion) (*models.Promotion, error) {
+	before, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := validatePromotion(p); err != nil {
+		return nil, err
+	}
+	p.ID = id
+	p.UsedCount = before.UsedCount
+	p.CreatedAt = before.CreatedAt
+	p.UpdatedAt = time.Now()
+	if err := s.store.Update(ctx, p); err != nil {
+		if errors.Is(err, repository.ErrDuplicatePromoCode) {
+			return nil, ErrDuplicatePromoCode
+		}
+		return nil, err
+	}
+	return p, nil
+}
+
+// Delete removes a promotion. Orders that used it keep their discount lines.
+func (s *PromotionService) Delete(ctx context.Context, id string) error {
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	return s.store.Delete(ctx, id)
+}
+
+// validatePromotion normalizes p in place.
+func validatePromotion(p *models.Promotion) error {
+	p.Code = strings.ToUpper(strings.TrimSpace(p.Code))
+	if !validPromoCode.MatchString(p.Code) {
+		return fmt.Errorf("%w: code must be 3 to 32 letters, digits, hyphens or underscores", ErrInvalidPromotion)
+	}
+	p.Description = strings.TrimSpace(p.Description)
+	p.Type = strings.ToLower(strings.TrimSpace(p.Type))
+	switch p.Type {
+	case models.PromotionPercent:
+		if !(p.Value > 0 && p.Value <= 100) {
+			return fmt.Errorf("%w: percent value must be above 0 and at most 100", ErrInvalidPromotion)
+		}
+	case models.PromotionFixed:
+		if !(p.Value > 0) || math.IsInf(p.Value, 0) {
+			return fmt.Errorf("%w: fixed value must be greater than 0", ErrInvalidPromotion)
+		}
+	case models.PromotionFreeShipping:
+		p.Value = 0
+	case models.PromotionBuyXGetY:
+		if p.BuyQuantity < 1 || p.GetQuantity < 1 {
+			return fmt.Errorf("%w: buy and get quantities must be at least 1", ErrInvalidPromotion)
+		}
+		p.Value = 0
+	default:
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidPromotion, p.Type)
+	}
+	if p.Type != models.PromotionBuyXGetY {
+		p.BuyQuantity, p.GetQuantity = 0, 0
+	}
+	if math.IsNaN(p.MinSubtotal) || p.MinSubtotal < 0 {
+		return fmt.Errorf("%w: minimum subtotal cannot be negative", ErrInvalidPromotion)
+	}
+	if p.UsageLimit < 0 || p.PerUserLimit < 0 {
+		return fmt.Errorf("%w: usage limits cannot be negative", ErrInvalidPromotion)
+	}
+	if p.StartsAt != nil && p.EndsAt != nil && !p.EndsAt.After(*p.StartsAt) {
+		return fmt.Errorf("%w: ends_at must be after starts_at", ErrInvalidPromotion)
+	}
+	p.CategoryIDs = trimIDs(p.CategoryIDs)
+	p.ProductIDs = trimIDs(p.ProductIDs)
+	return nil
+}
+
+func trimIDs(ids []string) []string {
+	var out []string
+	for _, id := range ids {
+		if id = strings.TrimSpace(id); id != "" {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// evaluate checks that the user may use code on the priced items and
+// returns the promotion with the discount it gives.
+func (s *PromotionService) evaluate(ctx context.Context, code, userID string, items []models.OrderItem, subtotal, deliveryFee float64, now time.Time) (*models.Promotion, *models.OrderDiscount, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	p, err := s.store.FindByCode(ctx, code)
+	if err != nil {
+		return nil, nil, err
+	}
+	if p == nil || !p.Active {
+		return nil, nil, fmt.Errorf("%w: %q is not a valid code", ErrPromotionNotApplicable, code)
+	}
+	if p.StartsAt != nil && now.Before(*p.StartsAt) {
+		return nil, nil, fmt.Errorf("%w: %s is not active yet", ErrPromotionNotApplicable, code)
+	}
+	if p.EndsAt != nil && !now.Before(*p.EndsAt) {
+		return nil, nil, fmt.Errorf("%w: %s has expired", ErrPromotionNotApplicable, code)
+	}
+	if subtotal < p.MinSubtotal {
+		return nil, nil, fmt.Errorf("%w: %s needs a subtotal of at least $%.2f", ErrPromotionNotApplicable, code, p.MinSubtotal)
+	}
+	if p.UsageLimit > 0 && p.UsedCount >= p.UsageLimit {
+		return nil, nil, ErrPromotionUsedUp
+	}
+	if p.PerUserLimit > 0 && userID != "" {
+		used, err := s.store.Uses(ctx, p.ID, userID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if used >= p.PerUserLimit {
+			return nil, nil, ErrPromotionUsedUp
+		}
+	}
+
+	eligible, err := s.eligible(ctx, p, items)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(eligible) == 0 {
+		return nil, nil, fmt.Errorf("%w: %s does not apply to any item in the cart", ErrPromotionNotApplicable, code)
+	}
+	var amount float64
+	switch p.Type {
+	case models.PromotionPercent:
+		amount = lineTotal(eligible) * p.Value / 100
+	case models.PromotionFixed:
+		amount = min(p.Value, lineTotal(eligible))
+	case models.PromotionFreeShipping:
+		if deliveryFee == 0 {
+			return nil, nil, fmt.Errorf("%w: delivery is already free", ErrPromotionNotApplicable)
+		}
+		amount = deliveryFee
+	case models.PromotionBuyXGetY:
+		amount = freeUnitsValue(eligible, p.BuyQuantity, p.GetQuantity)
+		if amount == 0 {
+			return nil, nil, fmt.Errorf("%w: %s needs %d eligible items", ErrPromotionNotApplicable, code, p.BuyQuantity+p.GetQuantity)
+		}
+	}
+	return p, &models.OrderDiscount{
+		PromotionID: p.ID,
+		Code:        p.Code,
+		Type:        p.Type,
+		Description: p.Description,
+		Amount:      roundCents(amount),
+	}, nil
+}
+
+// eligible returns the items p is scoped to: listed products, and products
+// in a listed category or one of its subcategories.
+func (s *PromotionService) eligible(ctx context.Context, p *models.Promotion, items []models.OrderItem) ([]models.OrderItem, error) {
+	if len(p.CategoryIDs) == 0 && len(p.ProductIDs) == 0 {
+		return items, nil
+	}
+	scope := make(map[string]bool)
+	for _, id := range p.ProductIDs {
+		scope[id] = true
+	}
+	categories := make(map[string]bool)
+	for _, id := range p.CategoryIDs {
+		ids, err := s.categories.Subtree(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range ids {
+			categories[c] = true
+		}
+	}
+	var out []models.OrderItem
+	for _, it := range items {
+		ok := scope[it.ProductID]
+		if !ok && len(categories) > 0 {
+			product, err := s.products.FindByID(ctx, it.ProductID)
+			if err != nil {
+				return nil, err
+			}
+			ok = product != nil && categories[product.CategoryID]
+		}
+		if ok {
+			out = append(out, it)
+		}
+	}
+	return out, nil
+}
+
+func lineTotal(items []models.OrderItem) float64 {
+	total := 0.0
+	for _, it := range items {
+		total += it.LineTotal
+	}
+	return total
+}
+
+// freeUnitsValue prices buy-X-get-Y: units are grouped most expensive first
+// and the cheapest get units of every full group of buy+get are free.
+func freeUnitsValue(items []models.OrderItem, buy, get int) float64 {
+	var prices []float64
+	for _, it := range items {
+		for range it.Quantity {
+			prices = append(prices, it.UnitPrice)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(prices)))
+	group := buy + get
+	free := 0.0
+	for i := 0; i+group <= len(prices); i += group {
+		for _, price := range prices[i+buy : i+group] {
+			free += price
+		}
+	}
+	return free
+}
+
+func (s *PromotionService) redeem(ctx context.Context, p *models.Promotion, userID string) error {
+	err := s.store.Redeem(ctx, p, userID)
+	if errors.Is(err, repository.ErrPromotionUsedUp) {
+		return ErrPromotionUsedUp
+	}
+	return err
+}
+
+func (s *PromotionService) release(ctx context.Context, promotionID, userID string) error {
+	return s.store.Release(ctx, promotionID, userID)
+}
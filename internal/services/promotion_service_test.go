@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/repository"
+)
+
+func TestFreeUnitsValue(t *testing.T) {
+	line := func(price float64, qty int) models.OrderItem {
+		return models.OrderItem{UnitPrice: price, Quantity: qty, LineTotal: price * float64(qty)}
+	}
+	tests := []struct {
+		name     string
+		items    []models.OrderItem
+		buy, get int
+		want     float64
+	}{
+		{"no items", nil, 2, 1, 0},
+		{"one short of a group", []models.OrderItem{line(10, 2)}, 2, 1, 0},
+		{"one group", []models.OrderItem{line(10, 3)}, 2, 1, 10},
+		{"cheapest unit of the group is free", []models.OrderItem{line(30, 1), line(10, 1), line(20, 1)}, 2, 1, 10},
+		{"units are grouped most expensive first", []models.OrderItem{line(60, 1), line(40, 2), line(5, 3)}, 2, 1, 45},
+		{"leftover units pay full price", []models.OrderItem{line(10, 4)}, 2, 1, 10},
+		{"buy one get one", []models.OrderItem{line(50, 1), line(20, 1)}, 1, 1, 20},
+		{"get two", []models.OrderItem{line(9, 1), line(8, 1), line(7, 1), line(6, 1)}, 2, 2, 13},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := freeUnitsValue(tt.items, tt.buy, tt.get); got != tt.want {
+				t.Errorf("freeUnitsValue = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// promotionFixture is a catalog with a Tops > Shirts category tree and a
+// cart of two shirts, jeans and three pairs of uncategorized socks.
+func promotionFixture(t *testing.T) (*PromotionService, *repository.PromotionRepositoryMemory, []models.OrderItem) {
+	t.Helper()
+	ctx := context.Background()
+	categories := repository.NewCategoryRepositoryMemory()
+	for _, c := range []*models.Category{
+		{ID: "tops", Name: "Tops", Slug: "tops"},
+		{ID: "shirts", Name: "Shirts", Slug: "shirts", ParentID: "tops"},
+		{ID: "pants", Name: "Pants", Slug: "pants"},
+	} {
+		if _, err := categories.Insert(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	products := repository.NewProductRepositoryMemory()
+	for _, p := range []*models.Product{
+		{ID: "shirt", Name: "Shirt", Price: 40, CategoryID: "shirts"},
+		{ID: "jeans", Name: "Jeans", Price: 60, CategoryID: "pants"},
+		{ID: "socks", Name: "Socks", Price: 5},
+	} {
+		if _, err := products.Insert(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store := repository.NewPromotionRepositoryMemory()
+	categoryService := NewCategoryService(categories, NewProductService(products))
+	items := []models.OrderItem{
+		{ProductID: "shirt", UnitPrice: 40, Quantity: 2, LineTotal: 80},
+		{ProductID: "jeans", UnitPrice: 60, Quantity: 1, LineTotal: 60},
+		{ProductID: "socks", UnitPrice: 5, Quantity: 3, LineTotal: 15},
+	}
+	return NewPromotionService(store, products, categoryService), store, items
+}
+
+func TestPromotionEvaluate(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	hourAgo, inHour := now.Add(-time.Hour), now.Add(time.Hour)
+	const subtotal, fee = 155.0, 20.0
+
+	tests := []struct {
+		name     string
+		promo    models.Promotion
+		inactive bool
+		code     string
+		user     string
+		usedBy   []string
+		fee      float64
+		want     float64
+		wantErr  error
+	}{
+		{name: "percent off everything", promo: models.Promotion{Type: models.PromotionPercent, Value: 10}, want: 15.5},
+		{name: "code is matched ignoring case and spaces", promo: models.Promotion{Type: models.PromotionPercent, Value: 10}, code: "  save10 ", want: 15.5},
+		{name: "percent off listed products", promo: models.Promotion{Type: models.PromotionPercent, Value: 50, ProductIDs: []string{"jeans"}}, want: 30},
+		{name: "category scope includes subcategories", promo: models.Promotion{Type: models.PromotionPercent, Value: 25, CategoryIDs: []string{"tops"}}, want: 20},
+		{name: "fixed amount", promo: models.Promotion{Type: models.PromotionFixed, Value: 30}, want: 30},
+		{name: "fixed amount is capped at the eligible items", promo: models.Promotion{Type: models.PromotionFixed, Value: 100, CategoryIDs: []string{"shirts"}}, want: 80},
+		{name: "free shipping", promo: models.Promotion{Type: models.PromotionFreeShipping}, fee: fee, want: fee},
+		{name: "free shipping when delivery is free", promo: models.Promotion{Type: models.PromotionFreeShipping}, wantErr: ErrPromotionNotApplicable},
+		{name: "buy two get one", promo: models.Promotion{Type: models.PromotionBuyXGetY, BuyQuantity: 2, GetQuantity: 1}, want: 45},
+		{name: "buy two get one without enough eligible units", promo: models.Promotion{Type: models.PromotionBuyXGetY, BuyQuantity: 2, GetQuantity: 1, CategoryIDs: []string{"tops"}}, wantErr: ErrPromotionNotApplicable},
+		{name: "unknown code", promo: models.Promotion{Type: models.PromotionPercent, Value: 10}, code: "OTHER", wantErr: ErrPromotionNotApplicable},
+		{name: "inactive", promo: models.Promotion{Type: models.PromotionPercent, Value: 10}, inactive: true, wantErr: ErrPromotionNotApplicable},
+		{name: "not started", promo: models.Promotion{Type: models.PromotionPercent, Value: 10, StartsAt: &inHour}, wantErr: ErrPromotionNotApplicable},
+		{name: "started", promo: models.Promotion{Type: models.PromotionPercent, Value: 10, StartsAt: &hourAgo}, want: 15.5},
+		{name: "expired", promo: models.Promotion{Type: models.PromotionPercent, Value: 10, EndsAt: &hourAgo}, wantErr: ErrPromotionNotApplicable},
+		{name: "ends exactly now", promo: models.Promotion{Type: models.PromotionPercent, Value: 10, EndsAt: &now}, wantErr: ErrPromotionNotApplicable},
+		{name: "below minimum subtotal", promo: models.Promotion{Type: models.PromotionPercent, Value: 10, MinSubtotal: 200}, wantErr: ErrPromotionNotApplicable},
+		{name: "at minimum subtotal", promo: models.Promotion{Type: models.PromotionPercent, Value: 10, MinSubtotal: subtotal}, want: 15.5},
+		{name: "no eligible items", promo: models.Promotion{Type: models.PromotionPercent, Value: 10, ProductIDs: []string{"hat"}}, wantErr: ErrPromotionNotApplicable},
+		{name: "usage limit reached", promo: models.Promotion{Type: models.PromotionPercent, Value: 10, UsageLimit: 1}, usedBy: []string{"u2"}, user: "u1", wantErr: ErrPromotionUsedUp},
+		{name: "per-user limit reached", promo: models.Promotion{Type: models.PromotionPercent, Value: 10, PerUserLimit: 1}, usedBy: []string{"u1"}, user: "u1", wantErr: ErrPromotionUsedUp},
+		{name: "per-user limit of another user", promo: models.Promotion{Type: models.PromotionPercent, Value: 10, PerUserLimit: 1}, usedBy: []string{"u2"}, user: "u1", want: 15.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s, store, items := promotionFixture(t)
+			promo := tt.promo
+			promo.Code = "SAVE10"
+			promo.Active = !tt.inactive
+			if err := store.Insert(ctx, &promo); err != nil {
+				t.Fatal(err)
+			}
+			for _, user := range tt.usedBy {
+				if err := store.Redeem(ctx, &promo, user); err != nil {
+					t.Fatal(err)
+				}
+			}
+			code := tt.code
+			if code == "" {
+				code = "SAVE10"
+			}
+
+			p, discount, err := s.evaluate(ctx, code, tt.user, items, subtotal, tt.fee, now)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.ID != promo.ID || discount.PromotionID != promo.ID || discount.Code != "SAVE10" || discount.Type != promo.Type {
+				t.Errorf("discount %+v does not describe promotion %s", discount, promo.ID)
+			}
+			if discount.Amount != tt.want {
+				t.Errorf("amount = %v, want %v", discount.Amount, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatePromotion(t *testing.T) {
+	tests := []struct {
+		name  string
+		promo models.Promotion
+		ok    bool
+	}{
+		{"percent", models.Promotion{Code: "spring-10", Type: "Percent", Value: 10}, true},
+		{"percent above 100", models.Promotion{Code: "SPRING", Type: models.PromotionPercent, Value: 101}, false},
+		{"fixed without value", models.Promotion{Code: "SPRING", Type: models.PromotionFixed}, false},
+		{"buy x get y without get", models.Promotion{Code: "SPRING", Type: models.PromotionBuyXGetY, BuyQuantity: 2}, false},
+		{"unknown type", models.Promotion{Code: "SPRING", Type: "bogus"}, false},
+		{"short code", models.Promotion{Code: "AB", Type: models.PromotionFreeShipping}, false},
+		{"code with spaces", models.Promotion{Code: "SPRING SALE", Type: models.PromotionFreeShipping}, false},
+		{"negative limit", models.Promotion{Code: "SPRING", Type: models.PromotionFreeShipping, PerUserLimit: -1}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.promo
+			err := validatePromotion(&p)
+			if tt.ok && err != nil {
+				t.Fatalf("validatePromotion: %v", err)
+			}
+			if tt.ok && (p.Code != strings.ToUpper(strings.TrimSpace(tt.promo.Code)) || p.Type != strings.ToLower(tt.promo.Type)) {
+				t.Errorf("validatePromotion left code %q and type %q", p.Code, p.Type)
+			}
+			if !tt.ok && !errors.Is(err, ErrInvalidPromotion) {
+				t.Fatalf("validatePromotion = %v, want ErrInvalidPromotion", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,362 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/repository"
+)
+
+const (
+	rankingCacheTTL = 5 * time.Minute
+	// popularityWindow is how far back units sold count as popularity.
+	popularityWindow = 30 * 24 * time.Hour
+	// recencyHalfLife is the product age at which recency is worth half.
+	recencyHalfLife  = 30 * 24 * time.Hour
+	maxRankingWeight = 100
+	// maxCachedSegments bounds the per-shopper segment cache.
+	maxCachedSegments = 10000
+	// rankScoreStep is how far a stored score may drift before a refresh
+	// rewrites it, so that recency slowly ageing does not rewrite the
+	// whole catalog every time.
+	rankScoreStep = 0.001
+)
+
+var ErrInvalidRanking = errors.New("invalid ranking weights")
+
+// DefaultRankingWeights apply until an admin saves their own.
+var DefaultRankingWeights = models.RankingWeights{Popularity: 0.4, Recency: 0.2, Stock: 0.2, Affinity: 0.2}
+
+// RankingService scores products for the shop's "recommended" sort. The
+// part of the score that is the same for every shopper is stored on the
+// product by Refresh, so listings are sorted and paged by the store; the
+// shopper's affinity is added in the query.
+type RankingService struct {
+	store    repository.RankingStore
+	orders   repository.OrderStore
+	products repository.ProductStore
+
+	mu sync.Mutex
+	// weights are those the stored scores were computed with; affinity
+	// boosts use the same ones.
+	weights *models.RankingWeights
+	// segments caches each shopper's segment, which takes their order
+	// history and a lookup per product they bought to work out.
+	segments map[string]segmentEntry
+}
+
+// rankingSegment is what personalizes a shopper's order: the category and
+// gender a customer bought most of. Guests and customers with no paid
+// orders have the zero segment.
+type rankingSegment struct {
+	category string
+	gender   string
+}
+
+type segmentEntry struct {
+	segment rankingSegment
+	until   time.Time
+}
+
+func NewRankingService(store repository.RankingStore, orders repository.OrderStore, products repository.ProductStore) *RankingService {
+	return &RankingService{
+		store:    store,
+		orders:   orders,
+		products: products,
+		segments: make(map[string]segmentEntry),
+	}
+}
+
+// Run refreshes the stored scores now and then every interval until ctx is
+// done.
+func (s *RankingService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.Refresh(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			log.Printf("ranking: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Weights returns the saved weights, or the defaults.
+func (s *RankingService) Weights(ctx context.Context) (*models.RankingWeights, error) {
+	w, err := s.store.Weights(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if w == nil {
+		def := DefaultRankingWeights
+		return &def, nil
+	}
+	return w, nil
+}
+
+// SetWeights saves new weights and rescores every product with them.
+func (s *RankingService) SetWeights(ctx context.Context, w *models.RankingWeights, adminID string) (*models.RankingWeights, error) {
+	total := 0.0
+	for _, v := range []float64{w.Popularity, w.Recency, w.Stock, w.Affinity} {
+		if math.IsNaN(v) || v < 0 || v > maxRankingWeight {
+			return nil, fmt.Errorf("%w: weights must be between 0 and %d", ErrInvalidRanking, maxRankingWeight)
+		}
+		total += v
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("%w: at least one weight must be positive", ErrInvalidRanking)
+	}
+	now := time.Now()
+	saved := models.RankingWeights{
+		Popularity: w.Popularity,
+		Recency:    w.Recency,
+		Stock:      w.Stock,
+		Affinity:   w.Affinity,
+		UpdatedBy:  adminID,
+		UpdatedAt:  &now,
+	}
+	if err := s.store.SaveWeights(ctx, &saved); err != nil {
+		return nil, err
+	}
+	// The weights are saved; if rescoring fails the next scheduled refresh
+	// picks them up.
+	if _, err := s.Refresh(ctx, now); err != nil {
+		log.Printf("ranking: %v", err)
+	}
+	return &saved, nil
+}
+
+// segment returns the shopper's segment, cached for rankingCacheTTL.
+func (s *RankingService) segment(ctx context.Context, userID string, now time.Time) (rankingSegment, error) {
+	if userID == "" {
+		return rankingSegment{}, nil
+	}
+	s.mu.Lock()
+	entry, ok := s.segments[userID]
+	s.mu.Unlock()
+	if ok && now.Before(entry.until) {
+		return entry.segment, nil
+	}
+	segment, err := s.findSegment(ctx, userID)
+	if err != nil {
+		return rankingSegment{}, err
+	}
+	s.mu.Lock()
+	if len(s.segments) >= maxCachedSegments {
+		for id, e := range s.segments {
+			if !now.Before(e.until) {
+				delete(s.segments, id)
+			}
+		}
+		if len(s.segments) >= maxCachedSegments {
+			s.segments = make(map[string]segmentEntry)
+		}
+	}
+	s.segments[userID] = segmentEntry{segment: segment, until: now.Add(rankingCacheTTL)}
+	s.mu.Unlock()
+	return segment, nil
+}
+
+func (s *RankingService) findSegment(ctx context.Context, userID string) (rankingSegment, error) {
+	orders, err := s.orders.FindByUser(ctx, userID)
+	if err != nil {
+		return rankingSegment{}, err
+	}
+	units := make(map[string]int)
+	for _, o := range orders {
+		if !purchasedStatuses[o.Status] {
+			continue
+		}
+		for _, it := range o.Items {
+			units[it.ProductID] += it.Quantity
+		}
+	}
+	categories := make(map[string]int)
+	genders := make(map[string]int)
+	for id, n := range units {
+		p, err := s.products.FindByID(ctx, id)
+		if err != nil {
+			return rankingSegment{}, err
+		}
+		if p == nil {
+			continue
+		}
+		if key := rankingCategory(p); key != "" {
+			categories[key] += n
+		}
+		if g := strings.ToLower(p.Gender); g != "" {
+			genders[g] += n
+		}
+	}
+	return rankingSegment{category: mostUnits(categories), gender: mostUnits(genders)}, nil
+}
+
+// Affinity returns what the shopper's favourites add to the stored scores,
+// or nil if nothing does.
+func (s *RankingService) Affinity(ctx context.Context, userID string) (*models.RankAffinity, error) {
+	segment, err := s.segment(ctx, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if segment == (rankingSegment{}) {
+		return nil, nil
+	}
+	s.mu.Lock()
+	w := s.weights
+	s.mu.Unlock()
+	if w == nil {
+		// No refresh has finished yet.
+		if w, err = s.Weights(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if w.Affinity == 0 {
+		return nil, nil
+	}
+	total := w.Popularity + w.Recency + w.Stock + w.Affinity
+	return &models.RankAffinity{Category: segment.category, Gender: segment.gender, Boost: 0.5 * w.Affinity / total}, nil
+}
+
+// Refresh stores every product's score without affinity, each factor
+// scaled to 0..1 and blended by the configured weights, and returns how
+// many scores it rewrote.
+func (s *RankingService) Refresh(ctx context.Context, now time.Time) (int, error) {
+	w, err := s.Weights(ctx)
+	if err != nil {
+		return 0, err
+	}
+	products, err := s.products.FindAll(ctx)
+	if err != nil {
+		return 0, err
+	}
+	sold, err := s.unitsSold(ctx, now.Add(-popularityWindow), len(products))
+	if err != nil {
+		return 0, err
+	}
+	maxSold := 0
+	for _, n := range sold {
+		maxSold = max(maxSold, n)
+	}
+	total := w.Popularity + w.Recency + w.Stock + w.Affinity
+	written := 0
+	for _, p := range products {
+		popularity := 0.0
+		if maxSold > 0 {
+			popularity = math.Log1p(float64(sold[p.ID])) / math.Log1p(float64(maxSold))
+		}
+		age := max(now.Sub(p.CreatedAt), 0)
+		recency := math.Pow(0.5, float64(age)/float64(recencyHalfLife))
+		score := (w.Popularity*popularity + w.Recency*recency + w.Stock*stockAvailability(p)) / total
+		if math.Abs(score-p.RankScore) < rankScoreStep {
+			continue
+		}
+		if err := s.products.SetRankScore(ctx, p.ID, score); err != nil {
+			return written, err
+		}
+		written++
+	}
+	s.mu.Lock()
+	s.weights = w
+	s.mu.Unlock()
+	return written, nil
+}
+
+// unitsSold returns units sold per product in purchased orders placed since
+// the given time, for the limit best sellers by units.
+func (s *RankingService) unitsSold(ctx context.Context, since time.Time, limit int) (map[string]int, error) {
+	out := make(map[string]int)
+	if mongoRepo, ok := s.orders.(*repository.OrderRepositoryMongo); ok {
+		statuses := make([]string, 0, len(purchasedStatuses))
+		for status := range purchasedStatuses {
+			statuses = append(statuses, status)
+		}
+		rows, err := mongoRepo.AggregateUnitsSold(ctx, since, statuses, limit)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			out[row.ProductID] = row.TotalSold
+		}
+		return out, nil
+	}
+
+	orders, err := s.orders.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range orders {
+		if o.CreatedAt.Before(since) || !purchasedStatuses[o.Status] {
+			continue
+		}
+		for _, it := range o.Items {
+			out[it.ProductID] += it.Quantity
+		}
+	}
+	if limit > 0 && len(out) > limit {
+		ids := make([]string, 0, len(out))
+		for id := range out {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool {
+			if out[ids[i]] != out[ids[j]] {
+				return out[ids[i]] > out[ids[j]]
+			}
+			return ids[i] < ids[j]
+		})
+		for _, id := range ids[limit:] {
+			delete(out, id)
+		}
+	}
+	return out, nil
+}
+
+// stockAvailability is the share of the product's sizes that are in stock.
+func stockAvailability(p *models.Product) float64 {
+	stock := sizeStock(p)
+	if len(stock) == 0 {
+		return 0
+	}
+	inStock := 0
+	for _, n := range stock {
+		if n > 0 {
+			inStock++
+		}
+	}
+	return float64(inStock) / float64(len(stock))
+}
+
+// rankingCategory identifies a product's category by ID, or by name for
+// products not assigned to one.
+func rankingCategory(p *models.Product) string {
+	if p.CategoryID != "" {
+		return p.CategoryID
+	}
+	return strings.ToLower(strings.TrimSpace(p.Category))
+}
+
+// mostUnits returns the key with the most units, ties broken by key.
+func mostUnits(units map[string]int) string {
+	keys := make([]string, 0, len(units))
+	for k := range units {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	best := ""
+	for _, k := range keys {
+		if best == "" || units[k] > units[best] {
+			best = k
+		}
+	}
+	return best
+}
@@ -0,0 +1,274 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/repository"
+)
+
+const (
+	DefaultRecommendations = 4
+	MaxRecommendations     = 12
+	// coPurchaseWindow is how far back orders count towards "bought
+	// together"; older baskets say little about the current catalog.
+	coPurchaseWindow  = 180 * 24 * time.Hour
+	maxBoughtTogether = 20
+)
+
+// RecommendationService suggests products to show next to others: ones
+// often bought in the same order first, then similar ones.
+type RecommendationService struct {
+	store    repository.RecommendationStore
+	orders   repository.OrderStore
+	products repository.ProductStore
+}
+
+func NewRecommendationService(store repository.RecommendationStore, orders repository.OrderStore, products repository.ProductStore) *RecommendationService {
+	return &RecommendationService{store: store, orders: orders, products: products}
+}
+
+// Run recomputes co-purchases immediately and then on every tick until ctx
+// is done.
+func (s *RecommendationService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.Compute(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			log.Printf("recommendations: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Compute rebuilds the co-purchase lists from the orders placed in the
+// window before now and returns how many products have one.
+func (s *RecommendationService) Compute(ctx context.Context, now time.Time) (int, error) {
+	pairs, err := s.coPurchases(ctx, now.Add(-coPurchaseWindow))
+	if err != nil {
+		return 0, err
+	}
+	byProduct := make(map[string][]models.CoPurchase)
+	for _, p := range pairs {
+		byProduct[p.ProductID] = append(byProduct[p.ProductID], models.CoPurchase{ProductID: p.WithID, Orders: p.Orders})
+	}
+	recs := make([]*models.ProductRecommendations, 0, len(byProduct))
+	for id, list := range byProduct {
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Orders != list[j].Orders {
+				return list[i].Orders > list[j].Orders
+			}
+			return list[i].ProductID < list[j].ProductID
+		})
+		if len(list) > maxBoughtTogether {
+			list = list[:maxBoughtTogether]
+		}
+		recs = append(recs, &models.ProductRecommendations{ProductID: id, BoughtTogether: list})
+	}
+	if err := s.store.ReplaceAll(ctx, recs, now); err != nil {
+		return 0, err
+	}
+	return len(recs), nil
+}
+
+// coPurchases counts orders containing each ordered pair of products, not
+// counting cancelled or refunded orders.
+func (s *RecommendationService) coPurchases(ctx context.Context, since time.Time) ([]repository.CoPurchaseAgg, error) {
+	if mongoRepo, ok := s.orders.(*repository.OrderRepositoryMongo); ok {
+		return mongoRepo.AggregateCoPurchases(ctx, since)
+	}
+
+	orders, err := s.orders.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[[2]string]int)
+	for _, o := range orders {
+		if o.CreatedAt.Before(since) || o.Status == models.OrderStatusCancelled || o.Status == models.OrderStatusRefunded {
+			continue
+		}
+		seen := make(map[string]bool)
+		var ids []string
+		for _, it := range o.Items {
+			if !seen[it.ProductID] {
+				seen[it.ProductID] = true
+				ids = append(ids, it.ProductID)
+			}
+		}
+		for _, a := range ids {
+			for _, b := range ids {
+				if a != b {
+					counts[[2]string{a, b}]++
+				}
+			}
+		}
+	}
+	out := make([]repository.CoPurchaseAgg, 0, len(counts))
+	for pair, n := range counts {
+		out = append(out, repository.CoPurchaseAgg{ProductID: pair[0], WithID: pair[1], Orders: n})
+	}
+	return out, nil
+}
+
+// ForProduct returns up to limit products to show on the product's page.
+func (s *RecommendationService) ForProduct(ctx context.Context, productID string, limit int) ([]models.Recommendation, error) {
+	p, err := s.products.FindByID(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil || p.Archived() {
+		return nil, ErrProductNotFound
+	}
+	return s.ForProducts(ctx, []string{productID}, limit)
+}
+
+// ForProducts returns up to limit products to suggest alongside all of
+// productIDs, as for a cart. Products bought together with them come first,
+// most orders first; the rest are filled with the most similar products.
+// Products that are archived, inactive or sold out are never suggested.
+func (s *RecommendationService) ForProducts(ctx context.Context, productIDs []string, limit int) ([]models.Recommendation, error) {
+	if limit <= 0 {
+		limit = DefaultRecommendations
+	}
+	if limit > MaxRecommendations {
+		limit = MaxRecommendations
+	}
+	out := []models.Recommendation{}
+	if len(productIDs) == 0 {
+		return out, nil
+	}
+	all, err := s.products.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	catalog := make(map[string]*models.Product, len(all))
+	for _, p := range all {
+		catalog[p.ID] = p
+	}
+	exclude := make(map[string]bool, len(productIDs))
+	var seeds []*models.Product
+	for _, id := range productIDs {
+		exclude[id] = true
+		if p, ok := catalog[id]; ok {
+			seeds = append(seeds, p)
+		}
+	}
+	now := time.Now()
+	add := func(p *models.Product, reason string) {
+		exclude[p.ID] = true
+		out = append(out, models.Recommendation{Product: withPricing(p, now), Reason: reason})
+	}
+
+	recs, err := s.store.FindByProducts(ctx, productIDs)
+	if err != nil {
+		return nil, err
+	}
+	orders := make(map[string]int)
+	for _, rec := range recs {
+		for _, cp := range rec.BoughtTogether {
+			orders[cp.ProductID] += cp.Orders
+		}
+	}
+	together := make([]*models.Product, 0, len(orders))
+	for id := range orders {
+		if p, ok := catalog[id]; ok && !exclude[id] && recommendable(p) {
+			together = append(together, p)
+		}
+	}
+	sort.Slice(together, func(i, j int) bool {
+		a, b := together[i], together[j]
+		if orders[a.ID] != orders[b.ID] {
+			return orders[a.ID] > orders[b.ID]
+		}
+		return a.ID < b.ID
+	})
+	for _, p := range together {
+		if len(out) == limit {
+			return out, nil
+		}
+		add(p, models.RecommendBoughtTogether)
+	}
+
+	scores := make(map[string]int)
+	var similar []*models.Product
+	for _, p := range all {
+		if exclude[p.ID] || !recommendable(p) {
+			continue
+		}
+		best := 0
+		for _, seed := range seeds {
+			if n := similarity(seed, p); n > best {
+				best = n
+			}
+		}
+		if best > 0 {
+			scores[p.ID] = best
+			similar = append(similar, p)
+		}
+	}
+	sort.Slice(similar, func(i, j int) bool {
+		a, b := similar[i], similar[j]
+		if scores[a.ID] != scores[b.ID] {
+			return scores[a.ID] > scores[b.ID]
+		}
+		if a.Rating != b.Rating {
+			return a.Rating > b.Rating
+		}
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		return a.ID < b.ID
+	})
+	for _, p := range similar {
+		if len(out) == limit {
+			break
+		}
+		add(p, models.RecommendSimilar)
+	}
+	return out, nil
+}
+
+func recommendable(p *models.Product) bool {
+	if !p.IsActive || p.Archived() {
+		return false
+	}
+	for _, n := range sizeStock(p) {
+		if n > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// similarity scores how alike two products are: the same category counts
+// most, then the same gender, then each shared color.
+func similarity(a, b *models.Product) int {
+	score := 0
+	if a.CategoryID != "" && b.CategoryID != "" {
+		if a.CategoryID == b.CategoryID {
+			score += 4
+		}
+	} else if a.Category != "" && strings.EqualFold(a.Category, b.Category) {
+		score += 4
+	}
+	if a.Gender != "" && strings.EqualFold(a.Gender, b.Gender) {
+		score += 2
+	}
+	for _, ca := range a.Colors {
+		for _, cb := range b.Colors {
+			if strings.EqualFold(ca, cb) {
+				score++
+				break
+			}
+		}
+	}
+	return score
+}
@@ -0,0 +1,247 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/repository"
+)
+
+const (
+	DefaultReviewQueue = 50
+	MaxReviewQueue     = 200
+	maxReviewTitle     = 120
+	maxReviewBody      = 4000
+)
+
+var (
+	ErrInvalidReview  = errors.New("invalid review")
+	ErrReviewNotFound = errors.New("review not found")
+	ErrNotPurchased   = errors.New("only customers who bought this product can review it")
+)
+
+// purchasedStatuses are the order statuses that verify a purchase: paid and
+// not cancelled or refunded.
+var purchasedStatuses = map[string]bool{
+	models.OrderStatusPaid:       true,
+	models.OrderStatusProcessing: true,
+	models.OrderStatusShipped:    true,
+	models.OrderStatusDelivered:  true,
+}
+
+type ReviewService struct {
+	reviews  repository.ReviewStore
+	orders   repository.OrderStore
+	products repository.ProductStore
+}
+
+func NewReviewService(reviews repository.ReviewStore, orders repository.OrderStore, products repository.ProductStore) *ReviewService {
+	return &ReviewService{reviews: reviews, orders: orders, products: products}
+}
+
+// Submit stores the user's review of a product they bought. A user has one
+// review per product: submitting again replaces it, and either way it waits
+// for moderation before it is shown.
+func (s *ReviewService) Submit(ctx context.Context, userID, userName, productID string, req *models.CreateReviewRequest) (*models.Review, error) {
+	if req.Rating < 1 || req.Rating > 5 {
+		return nil, fmt.Errorf("%w: rating must be between 1 and 5", ErrInvalidReview)
+	}
+	title := strings.TrimSpace(req.Title)
+	body := strings.TrimSpace(req.Body)
+	if body == "" {
+		return nil, fmt.Errorf("%w: review text is required", ErrInvalidReview)
+	}
+	if utf8.RuneCountInString(title) > maxReviewTitle {
+		return nil, fmt.Errorf("%w: title is longer than %d characters", ErrInvalidReview, maxReviewTitle)
+	}
+	if utf8.RuneCountInString(body) > maxReviewBody {
+		return nil, fmt.Errorf("%w: review is longer than %d characters", ErrInvalidReview, maxReviewBody)
+	}
+	p, err := s.products.FindByID(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil || p.Archived() {
+		return nil, ErrProductNotFound
+	}
+	orderID, err := s.VerifiedPurchase(ctx, userID, productID)
+	if err != nil {
+		return nil, err
+	}
+	if orderID == "" {
+		return nil, ErrNotPurchased
+	}
+
+	now := time.Now()
+	existing, err := s.reviews.FindByUserProduct(ctx, userID, productID)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		r := &models.Review{
+			ProductID: productID,
+			UserID:    userID,
+			UserName:  userName,
+			OrderID:   orderID,
+			Rating:    req.Rating,
+			Title:     title,
+			Body:      body,
+			Status:    models.ReviewPending,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		if err := s.reviews.Insert(ctx, r); err != nil {
+			return nil, err
+		}
+		return r, nil
+	}
+	wasApproved := existing.Status == models.ReviewApproved
+	existing.UserName = userName
+	existing.OrderID = orderID
+	existing.Rating = req.Rating
+	existing.Title = title
+	existing.Body = body
+	existing.Status = models.ReviewPending
+	existing.ModerationNote = ""
+	existing.ModeratedBy = ""
+	existing.ModeratedAt = nil
+	existing.UpdatedAt = now
+	if err := s.reviews.Update(ctx, existing); err != nil {
+		return nil, err
+	}
+	if wasApproved {
+		if err := s.refreshRating(ctx, productID); err != nil {
+			return nil, err
+		}
+	}
+	return existing, nil
+}
+
+// VerifiedPurchase returns the latest paid order in which the user bought
+// the product, or "" when there is none.
+func (s *ReviewService) VerifiedPurchase(ctx context.Context, userID, productID string) (string, error) {
+	if userID == "" {
+		return "", nil
+	}
+	orders, err := s.orders.FindByUser(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	var latest *models.Order
+	for _, o := range orders {
+		if !purchasedStatuses[o.Status] || latest != nil && !o.CreatedAt.After(latest.CreatedAt) {
+			continue
+		}
+		for _, it := range o.Items {
+			if it.ProductID == productID {
+				latest = o
+				break
+			}
+		}
+	}
+	if latest == nil {
+		return "", nil
+	}
+	return latest.ID, nil
+}
+
+// Approved returns a product's published reviews, newest first.
+func (s *ReviewService) Approved(ctx context.Context, productID string) ([]*models.Review, error) {
+	return s.reviews.FindByProduct(ctx, productID, models.ReviewApproved)
+}
+
+// Mine returns the user's review of a product in any status, or nil.
+func (s *ReviewService) Mine(ctx context.Context, userID, productID string) (*models.Review, error) {
+	if userID == "" {
+		return nil, nil
+	}
+	return s.reviews.FindByUserProduct(ctx, userID, productID)
+}
+
+// Queue returns reviews in status, oldest first; pending by default.
+func (s *ReviewService) Queue(ctx context.Context, status string, limit int) ([]*models.Review, error) {
+	if status == "" {
+		status = models.ReviewPending
+	}
+	if status != models.ReviewPending && status != models.ReviewApproved && status != models.ReviewRejected {
+		return nil, fmt.Errorf("%w: status must be pending, approved or rejected", ErrInvalidReview)
+	}
+	if limit <= 0 {
+		limit = DefaultReviewQueue
+	}
+	if limit > MaxReviewQueue {
+		limit = MaxReviewQueue
+	}
+	return s.reviews.FindByStatus(ctx, status, limit)
+}
+
+// Moderate approves or rejects a review and updates the product's rating.
+// note is shown to the author.
+func (s *ReviewService) Moderate(ctx context.Context, id, status, note, moderatorID string) (*models.Review, error) {
+	if status != models.ReviewApproved && status != models.ReviewRejected {
+		return nil, fmt.Errorf("%w: status must be approved or rejected", ErrInvalidReview)
+	}
+	r, err := s.reviews.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if r == nil {
+		return nil, ErrReviewNotFound
+	}
+	changed := r.Status != status
+	now := time.Now()
+	r.Status = status
+	r.ModerationNote = strings.TrimSpace(note)
+	r.ModeratedBy = moderatorID
+	r.ModeratedAt = &now
+	r.UpdatedAt = now
+	if err := s.reviews.Update(ctx, r); err != nil {
+		return nil, err
+	}
+	if changed {
+		if err := s.refreshRating(ctx, r.ProductID); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+func (s *ReviewService) Delete(ctx context.Context, id string) error {
+	r, err := s.reviews.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if r == nil {
+		return ErrReviewNotFound
+	}
+	if err := s.reviews.Delete(ctx, id); err != nil {
+		return err
+	}
+	if r.Status == models.ReviewApproved {
+		return s.refreshRating(ctx, r.ProductID)
+	}
+	return nil
+}
+
+// refreshRating recomputes the product's rating from its approved reviews.
+func (s *ReviewService) refreshRating(ctx context.Context, productID string) error {
+	approved, err := s.reviews.FindByProduct(ctx, productID, models.ReviewApproved)
+	if err != nil {
+		return err
+	}
+	rating := 0.0
+	if len(approved) > 0 {
+		sum := 0
+		for _, r := range approved {
+			sum += r.Rating
+		}
+		rating = math.Round(float64(sum)/float64(len(approved))*100) / 100
+	}
+	return s.products.SetRating(ctx, productID, rating, len(approved))
+}
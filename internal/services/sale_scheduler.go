@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/repository"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	SaleStarted = "started"
+	SaleEnded   = "ended"
+)
+
+var (
+	saleTransitions = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "product_sale_transitions_total",
+			Help: "Scheduled sales that went live or expired",
+		},
+		[]string{"event"},
+	)
+
+	productsOnSale = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "products_on_sale",
+		Help: "Active products whose sale price currently applies",
+	})
+)
+
+// SaleEvent is a sale going live or expiring at its scheduled time.
+type SaleEvent struct {
+	ProductID string
+	Product   string
+	Event     string
+	// Price is what the product sells for from At on.
+	Price float64
+	At    time.Time
+}
+
+// SaleScheduler reports scheduled sales as they start and end and keeps each
+// product's stored CurrentPrice, which price filters and sorting use, in
+// step with its sale window. Prices shown and charged do not depend on it:
+// they are resolved from the sale window on every read.
+type SaleScheduler struct {
+	products repository.ProductStore
+	mu       sync.Mutex
+	last     time.Time
+}
+
+func NewSaleScheduler(products repository.ProductStore) *SaleScheduler {
+	return &SaleScheduler{products: products}
+}
+
+// Run checks immediately and then on every tick until ctx is done.
+// Transitions that happened before the first check are not reported.
+func (s *SaleScheduler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.Check(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			log.Printf("sale scheduler: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check returns and logs the sales that started or ended since the previous
+// check, oldest first, refreshes the sale gauge and stores the current price
+// of every product whose price changed.
+func (s *SaleScheduler) Check(ctx context.Context, now time.Time) ([]SaleEvent, error) {
+	products, err := s.products.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	since := s.last
+	s.last = now
+	s.mu.Unlock()
+
+	var events []SaleEvent
+	onSale := 0
+	for _, p := range products {
+		if price := sellingPrice(p, now); p.CurrentPrice != price {
+			if err := s.products.SetCurrentPrice(ctx, p.ID, price); err != nil {
+				log.Printf("sale scheduler: price of %s: %v", p.ID, err)
+			}
+		}
+		if !p.IsActive || p.Archived() || p.SalePrice == nil {
+			continue
+		}
+		if saleActive(p, now) {
+			onSale++
+		}
+		if since.IsZero() {
+			continue
+		}
+		if start := p.SaleStartsAt; start != nil && start.After(since) && !start.After(now) && saleActive(p, *start) {
+			events = append(events, SaleEvent{ProductID: p.ID, Product: p.Name, Event: SaleStarted, Price: *p.SalePrice, At: *start})
+		}
+		if end := p.SaleEndsAt; end != nil && end.After(since) && !end.After(now) {
+			events = append(events, SaleEvent{ProductID: p.ID, Product: p.Name, Event: SaleEnded, Price: p.Price, At: *end})
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].At.Before(events[j].At) })
+	for _, e := range events {
+		saleTransitions.WithLabelValues(e.Event).Inc()
+		log.Printf("sale %s for %s (%s) at %s: now %.2f", e.Event, e.Product, e.ProductID, e.At.Format(time.RFC3339), e.Price)
+	}
+	productsOnSale.Set(float64(onSale))
+	return events, nil
+}
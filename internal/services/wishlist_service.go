@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/models"
+	"github.com/Tedra-ez/AdvancedProgramming_Final/internal/repository"
+)
+
+type WishlistService struct {
+	wishlists     repository.WishlistStore
+	notifications repository.NotificationStore
+	products      repository.ProductStore
+}
+
+func NewWishlistService(wishlists repository.WishlistStore, notifications repository.NotificationStore, products repository.ProductStore) *WishlistService {
+	return &WishlistService{
+		wishlists:     wishlists,
+		notifications: notifications,
+		products:      products,
+	}
+}
+
+// List returns the user's wishlist with live prices and per-size stock.
+func (s *WishlistService) List(ctx context.Context, userID string) ([]models.WishlistEntry, error) {
+	w, err := s.wishlists.FindByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	entries := []models.WishlistEntry{}
+	if w == nil {
+		return entries, nil
+	}
+	for _, it := range w.Items {
+		entry := models.WishlistEntry{ProductID: it.ProductID, AddedAt: it.AddedAt, Sizes: []models.SizeAvailability{}}
+		p, err := s.products.FindByID(ctx, it.ProductID)
+		if err != nil {
+			return nil, err
+		}
+		if p != nil && !p.Archived() {
+			entry.Available = true
+			entry.Name = p.Name
+			entry.Price = effectivePrice(p, nil, time.Now())
+			if len(p.Images) > 0 {
+				entry.Image = p.Images[0]
+			}
+			for _, size := range p.Sizes {
+				stock := p.StockBySize[size]
+				entry.Sizes = append(entry.Sizes, models.SizeAvailability{Size: size, Stock: stock, InStock: stock > 0})
+				if stock > 0 {
+					entry.InStock = true
+				}
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (s *WishlistService) ProductIDs(ctx context.Context, userID string) ([]string, error) {
+	w, err := s.wishlists.FindByUser(ctx, userID)
+	if err != nil || w == nil {
+		return []string{}, err
+	}
+	ids := make([]string, 0, len(w.Items))
+	for _, it := range w.Items {
+		ids = append(ids, it.ProductID)
+	}
+	return ids, nil
+}
+
+func (s *WishlistService) Add(ctx context.Context, userID, productID string) error {
+	p, err := s.products.FindByID(ctx, productID)
+	if err != nil {
+		return err
+	}
+	if p == nil || p.Archived() {
+		return ErrProductNotFound
+	}
+	w, err := s.wishlists.FindByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if w == nil {
+		w = &models.Wishlist{UserID: userID}
+	}
+	for _, it := range w.Items {
+		if it.ProductID == p.ID {
+			return nil
+		}
+	}
+	w.Items = append(w.Items, models.WishlistItem{ProductID: p.ID, AddedAt: time.Now()})
+	return s.wishlists.Save(ctx, w)
+}
+
+func (s *WishlistService) Remove(ctx context.Context, userID, productID string) error {
+	w, err := s.wishlists.FindByUser(ctx, userID)
+	if err != nil || w == nil {
+		return err
+	}
+	items := w.Items[:0]
+	for _, it := range w.Items {
+		if it.ProductID != productID {
+			items = append(items, it)
+		}
+	}
+	w.Items = items
+	return s.wishlists.Save(ctx, w)
+}
+
+func (s *WishlistService) Notifications(ctx context.Context, userID string, unreadOnly bool) ([]*models.RestockNotification, error) {
+	out, err := s.notifications.FindByUser(ctx, userID, unreadOnly)
+	if err != nil {
+		return nil, err
+	}
+	if out == nil {
+		out = []*models.RestockNotification{}
+	}
+	return out, nil
+}
+
+func (s *WishlistService) MarkNotificationsRead(ctx context.Context, userID string) error {
+	return s.notifications.MarkRead(ctx, userID)
+}
+
+// ProductChanged is a ProductHook: for every size that went from sold out to
+// in stock it records a notification for each user wishlisting the product.
+func (s *WishlistService) ProductChanged(ctx context.Context, before, after *models.Product) {
+	if before == nil || after == nil || after.Archived() {
+		return
+	}
+	var restocked []string
+	for _, size := range after.Sizes {
+		if before.StockBySize[size] <= 0 && after.StockBySize[size] > 0 {
+			restocked = append(restocked, size)
+		}
+	}
+	if len(restocked) == 0 {
+		return
+	}
+	userIDs, err := s.wishlists.FindUserIDsByProduct(ctx, after.ID)
+	if err != nil {
+		log.Printf("restock notifications for %s: %v", after.ID, err)
+		return
+	}
+	for _, userID := range userIDs {
+		for _, size := range restocked {
+			n := &models.RestockNotification{
+				UserID:      userID,
+				ProductID:   after.ID,
+				ProductName: after.Name,
+				Size:        size,
+			}
+			if err := s.notifications.Create(ctx, n); err != nil {
+				log.Printf("restock notification for user %s: %v", userID, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// MediaPath is where the server redirects to signed URLs for objects in a
+// private bucket. Stores without a public URL link there.
+const MediaPath = "/media/"
+
+var ErrInvalidKey = errors.New("invalid object key")
+
+// BlobStore keeps media objects under slash separated keys such as
+// "products/<hash>-large.jpg".
+type BlobStore interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Delete succeeds when the object does not exist.
+	Delete(ctx context.Context, key string) error
+	Exists(ctx context.Context, key string) (bool, error)
+	// URL is the stable link to an object, safe to store in documents.
+	URL(key string) string
+	// SignedURL grants temporary read access to an object.
+	SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error)
+}
+
+// ValidKey rejects keys that are empty, absolute or step outside the store.
+func ValidKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return false
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}
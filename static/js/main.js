@@ -17,6 +17,51 @@ document.addEventListener('DOMContentLoaded', () => {
         });
     }
 
+    const searchInput = searchOverlay?.querySelector('.search-input');
+    if (searchInput) {
+        const list = document.createElement('div');
+        list.className = 'search-suggestions';
+        searchInput.insertAdjacentElement('afterend', list);
+        searchInput.setAttribute('autocomplete', 'off');
+
+        let timer = null;
+        let seq = 0;
+        const escapeHTML = s => s.replace(/[&<>"']/g, c => ({ '&': '&amp;', '<': '&lt;', '>': '&gt;', '"': '&quot;', "'": '&#39;' }[c]));
+
+        function renderSuggestions(data) {
+            const rows = [
+                ...(data.queries || []).map(s => ({ icon: 'search', text: s.text, href: '/shop?q=' + encodeURIComponent(s.text) })),
+                ...(data.categories || []).map(s => ({ icon: 'tag', text: s.text, href: '/shop?category=' + encodeURIComponent(s.text) })),
+                ...(data.products || []).map(s => ({ icon: 'shirt', text: s.text, href: '/product/' + encodeURIComponent(s.product_id) }))
+            ];
+            list.innerHTML = rows.map(r =>
+                `<a class="search-suggestion" href="${r.href}"><i data-lucide="${r.icon}"></i><span>${escapeHTML(r.text)}</span></a>`
+            ).join('');
+            list.classList.toggle('active', rows.length > 0);
+            lucide.createIcons();
+        }
+
+        searchInput.addEventListener('input', () => {
+            clearTimeout(timer);
+            const q = searchInput.value;
+            if (!q.trim()) {
+                list.classList.remove('active');
+                return;
+            }
+            timer = setTimeout(async () => {
+                const mine = ++seq;
+                try {
+                    const res = await fetch('/api/search/suggest?q=' + encodeURIComponent(q));
+                    if (!res.ok || mine !== seq) return;
+                    renderSuggestions(await res.json());
+                } catch { }
+            }, 150);
+        });
+        searchInput.addEventListener('keydown', e => {
+            if (e.key === 'Escape') list.classList.remove('active');
+        });
+    }
+
     function toggleSidebar() {
         sidebar?.classList.toggle('active');
         sidebarOverlay?.classList.toggle('active');
@@ -32,54 +77,69 @@ document.addEventListener('DOMContentLoaded', () => {
     });
 
     window.Cart = {
-        _key: 'clothes_store_cart',
-
-        _read() {
-            try { return JSON.parse(localStorage.getItem(this._key)) || []; }
-            catch { return []; }
-        },
-        _write(items) { localStorage.setItem(this._key, JSON.stringify(items)); },
-
-        getAll() { return this._read(); },
-        getCount() { return this._read().reduce((s, i) => s + i.qty, 0); },
-        getTotal() { return this._read().reduce((s, i) => s + i.qty * i.price, 0); },
-
-        add(product) {
-            const items = this._read();
-            const key = product.id + '|' + (product.size || '') + '|' + (product.color || '');
-            const existing = items.find(i => (i.id + '|' + (i.size || '') + '|' + (i.color || '')) === key);
-            if (existing) {
-                existing.qty += 1;
-            } else {
-                items.push({ ...product, qty: 1 });
+        _legacyKey: 'clothes_store_cart',
+        _view: { items: [], count: 0, subtotal: 0 },
+
+        async _request(method, url, body) {
+            const opts = { method, credentials: 'same-origin', headers: {} };
+            if (body !== undefined) {
+                opts.headers['Content-Type'] = 'application/json';
+                opts.body = JSON.stringify(body);
             }
-            this._write(items);
+            const res = await fetch(url, opts);
+            const data = await res.json().catch(() => ({}));
+            if (!res.ok) throw new Error(data.error || 'Cart request failed');
+            this._view = data;
             this.updateBadge();
-            Cart.showToast(`${product.name} added to cart`);
+            return data;
         },
 
-        remove(id, size, color) {
-            let items = this._read();
-            const key = id + '|' + (size || '') + '|' + (color || '');
-            items = items.filter(i => (i.id + '|' + (i.size || '') + '|' + (i.color || '')) !== key);
-            this._write(items);
-            this.updateBadge();
+        load() { return this._request('GET', '/api/cart/items'); },
+
+        getAll() { return this._view.items || []; },
+        getCount() { return this._view.count || 0; },
+        getTotal() { return this._view.subtotal || 0; },
+
+        async add(product) {
+            try {
+                await this._request('POST', '/api/cart/items', {
+                    product_id: product.id,
+                    variant_id: product.variantId || '',
+                    selected_size: product.size || '',
+                    selected_color: product.color || '',
+                    quantity: 1
+                });
+                Cart.showToast(`${product.name} added to cart`);
+            } catch (err) {
+                Cart.showToast(err.message);
+            }
         },
 
-        updateQty(id, size, color, qty) {
-            const items = this._read();
-            const key = id + '|' + (size || '') + '|' + (color || '');
-            const item = items.find(i => (i.id + '|' + (i.size || '') + '|' + (i.color || '')) === key);
-            if (item) {
-                item.qty = Math.max(1, qty);
-                this._write(items);
-                this.updateBadge();
-            }
+        remove(itemId) { return this._request('DELETE', '/api/cart/items/' + itemId); },
+
+        updateQty(itemId, qty) {
+            return this._request('PATCH', '/api/cart/items/' + itemId, { quantity: Math.max(1, qty) });
         },
 
-        clear() {
-            localStorage.removeItem(this._key);
-            this.updateBadge();
+        clear() { return this._request('DELETE', '/api/cart/items'); },
+
+        // Carts used to live in localStorage; push any leftover items to the
+        // server once so they are not lost.
+        async migrateLegacy() {
+            let items = [];
+            try { items = JSON.parse(localStorage.getItem(this._legacyKey)) || []; }
+            catch { items = []; }
+            localStorage.removeItem(this._legacyKey);
+            for (const i of items) {
+                try {
+                    await this._request('POST', '/api/cart/items', {
+                        product_id: i.id,
+                        selected_size: i.size || '',
+                        selected_color: i.color || '',
+                        quantity: i.qty || 1
+                    });
+                } catch { }
+            }
         },
 
         updateBadge() {
@@ -106,38 +166,69 @@ document.addEventListener('DOMContentLoaded', () => {
     };
 
     window.Wishlist = {
-        _key: 'clothes_store_wishlist',
-
-        _read() {
-            try { return JSON.parse(localStorage.getItem(this._key)) || []; }
-            catch { return []; }
+        _legacyKey: 'clothes_store_wishlist',
+        _ids: new Set(),
+
+        async _request(method, url, body) {
+            const opts = { method, credentials: 'same-origin', headers: {} };
+            if (body !== undefined) {
+                opts.headers['Content-Type'] = 'application/json';
+                opts.body = JSON.stringify(body);
+            }
+            const res = await fetch(url, opts);
+            const data = await res.json().catch(() => ({}));
+            if (res.status === 401) {
+                const err = new Error('Log in to use your wishlist');
+                err.unauthorized = true;
+                throw err;
+            }
+            if (!res.ok) throw new Error(data.error || 'Wishlist request failed');
+            this._ids = new Set((data.items || []).map(i => i.product_id));
+            return data;
         },
-        _write(items) { localStorage.setItem(this._key, JSON.stringify(items)); },
-
-        getAll() { return this._read(); },
-        has(id) { return this._read().some(i => i.id === id); },
-
-        toggle(product) {
-            let items = this._read();
-            const idx = items.findIndex(i => i.id === product.id);
-            if (idx >= 0) {
-                items.splice(idx, 1);
-                Cart.showToast(`${product.name} removed from wishlist`);
-            } else {
-                items.push(product);
-                Cart.showToast(`${product.name} added to wishlist`);
+
+        load() { return this._request('GET', '/api/wishlist'); },
+
+        has(id) { return this._ids.has(id); },
+
+        async toggle(product) {
+            try {
+                if (this.has(product.id)) {
+                    await this.remove(product.id);
+                    Cart.showToast(`${product.name} removed from wishlist`);
+                } else {
+                    await this._request('POST', '/api/wishlist/items', { product_id: product.id });
+                    Cart.showToast(`${product.name} added to wishlist`);
+                }
+            } catch (err) {
+                Cart.showToast(err.message);
+                if (err.unauthorized) setTimeout(() => { window.location.href = '/login'; }, 1200);
             }
-            this._write(items);
         },
 
-        remove(id) {
-            let items = this._read();
-            items = items.filter(i => i.id !== id);
-            this._write(items);
+        remove(id) { return this._request('DELETE', '/api/wishlist/items/' + encodeURIComponent(id)); },
+
+        // Wishlists used to live in localStorage; move them to the account once
+        // the user is logged in.
+        async migrateLegacy() {
+            let items = [];
+            try { items = JSON.parse(localStorage.getItem(this._legacyKey)) || []; }
+            catch { items = []; }
+            if (items.length === 0) return;
+            for (const i of items) {
+                await this._request('POST', '/api/wishlist/items', { product_id: i.id }).catch(err => {
+                    if (err.unauthorized) throw err;
+                });
+            }
+            localStorage.removeItem(this._legacyKey);
         }
     };
 
-    Cart.updateBadge();
+    if (document.body.dataset.loggedIn === 'true') {
+        Wishlist.migrateLegacy().then(() => Wishlist.load()).catch(() => { });
+    }
+
+    const cartReady = Cart.migrateLegacy().then(() => Cart.load()).catch(() => Cart._view);
 
     function requireSizeAndColor(size, color, message) {
         if (!size || !color) {
@@ -188,7 +279,8 @@ document.addEventListener('DOMContentLoaded', () => {
             if (!requireSizeAndColor(size, color)) {
                 return;
             }
-            Cart.add({ id, name, price, image, size, color });
+            const variantId = productAddCartBtn.dataset.variantId || '';
+            Cart.add({ id, name, price, image, size, color, variantId });
         });
     }
 
@@ -203,88 +295,41 @@ document.addEventListener('DOMContentLoaded', () => {
         });
     }
 
-    const wishlistContainer = document.getElementById('wishlist-items');
-    const wishlistEmpty = document.getElementById('wishlist-empty');
-
-    if (wishlistContainer) {
-        renderWishlist();
-    }
-
-    function renderWishlist() {
-        const items = Wishlist.getAll();
-        wishlistContainer.innerHTML = '';
-
-        if (items.length === 0) {
-            wishlistContainer.style.display = 'none';
-            if (wishlistEmpty) wishlistEmpty.style.display = 'block';
-            return;
-        }
-
-        wishlistContainer.style.display = 'grid';
-        if (wishlistEmpty) wishlistEmpty.style.display = 'none';
-
-        items.forEach(item => {
-            const card = document.createElement('div');
-            card.className = 'product-card';
-            card.innerHTML = `
-                <a href="/product/${item.id}">
-                    <div class="product-image-container">
-                        <img src="${item.image}" alt="${item.name}" class="product-image"
-                             onerror="this.parentElement.style.background='#f5f5f5'">
-                        <div class="product-actions" style="opacity:1;transform:none;">
-                            <button class="action-btn btn-wish-cart" title="Add to Cart">
-                                <i data-lucide="shopping-bag" size="18"></i>
-                            </button>
-                            <button class="action-btn btn-wish-remove" title="Remove"
-                                    style="background:var(--color-danger);color:white;">
-                                <i data-lucide="trash-2" size="18"></i>
-                            </button>
-                        </div>
-                    </div>
-                    <div class="product-info">
-                        <div>
-                            <div class="product-title">${item.name}</div>
-                            <div class="product-price">$${parseFloat(item.price).toFixed(2)}</div>
-                        </div>
-                    </div>
-                </a>
-            `;
-
-            card.querySelector('.btn-wish-cart').addEventListener('click', e => {
-                e.preventDefault();
-                e.stopPropagation();
-                if (!requireSizeAndColor('', '', 'Select size and color on the product page')) {
-                    return;
-                }
-                Cart.add({ id: item.id, name: item.name, price: item.price, image: item.image });
-            });
-
-            card.querySelector('.btn-wish-remove').addEventListener('click', e => {
-                e.preventDefault();
-                e.stopPropagation();
-                Wishlist.remove(item.id);
-                renderWishlist();
-                lucide.createIcons();
-            });
-
-            wishlistContainer.appendChild(card);
+    document.querySelectorAll('.wishlist-card[data-product-id]').forEach(card => {
+        const id = card.dataset.productId;
+        card.querySelector('.btn-wish-remove')?.addEventListener('click', async e => {
+            e.preventDefault();
+            e.stopPropagation();
+            try {
+                await Wishlist.remove(id);
+                card.remove();
+                if (!document.querySelector('.wishlist-card')) window.location.reload();
+            } catch (err) {
+                Cart.showToast(err.message);
+            }
         });
+    });
 
-        lucide.createIcons();
-    }
+    const wishlistNotices = document.getElementById('wishlist-notifications');
+    wishlistNotices?.querySelector('.btn-dismiss-notices')?.addEventListener('click', async () => {
+        await fetch('/api/wishlist/notifications/read', { method: 'POST', credentials: 'same-origin' }).catch(() => { });
+        wishlistNotices.remove();
+    });
 
     const cartItemsEl = document.getElementById('cart-items');
     const cartEmptyEl = document.getElementById('cart-empty');
     const cartSummaryEl = document.getElementById('cart-summary');
 
     if (cartItemsEl) {
-        renderCart();
+        cartReady.then(renderCart);
     }
 
     function renderCart() {
         const items = Cart.getAll();
         cartItemsEl.innerHTML = '';
 
+        renderCartRecommendations(items);
+
         if (items.length === 0) {
             cartItemsEl.style.display = 'none';
             if (cartSummaryEl) cartSummaryEl.style.display = 'none';
@@ -299,44 +344,40 @@ document.addEventListener('DOMContentLoaded', () => {
         items.forEach(item => {
             const row = document.createElement('div');
             row.className = 'cart-item';
-            const variant = [item.size, item.color].filter(Boolean).join(' / ') || 'Standard';
+            const variant = [item.selected_size, item.selected_color].filter(Boolean).join(' / ') || 'Standard';
             row.innerHTML = `
                 <div class="cart-item-image">
-                    <img src="${item.image}" alt="${item.name}"
+                    <img src="${item.image}" alt="${item.product_name}"
                          onerror="this.parentElement.style.background='#f5f5f5'">
                 </div>
                 <div class="cart-item-details">
-                    <a href="/product/${item.id}" class="cart-item-name">${item.name}</a>
+                    <a href="/product/${item.product_id}" class="cart-item-name">${item.product_name}</a>
                     <div class="cart-item-variant">${variant}</div>
-                    <div class="cart-item-price">$${parseFloat(item.price).toFixed(2)}</div>
+                    <div class="cart-item-price">$${item.unit_price.toFixed(2)}</div>
+                    ${item.available ? '' : `<div class="cart-item-variant" style="color:var(--color-danger);">${item.issue}</div>`}
                 </div>
                 <div class="cart-item-qty">
                     <button class="qty-btn qty-minus">−</button>
-                    <span class="qty-value">${item.qty}</span>
+                    <span class="qty-value">${item.quantity}</span>
                     <button class="qty-btn qty-plus">+</button>
                 </div>
-                <div class="cart-item-total">$${(item.price * item.qty).toFixed(2)}</div>
+                <div class="cart-item-total">$${item.line_total.toFixed(2)}</div>
                 <button class="cart-item-remove" title="Remove">
                     <i data-lucide="x" size="18"></i>
                 </button>
             `;
 
+            const rerender = p => p.then(renderCart).catch(err => Cart.showToast(err.message));
             row.querySelector('.qty-minus').addEventListener('click', () => {
-                if (item.qty > 1) {
-                    Cart.updateQty(item.id, item.size, item.color, item.qty - 1);
-                    renderCart();
-                    lucide.createIcons();
+                if (item.quantity > 1) {
+                    rerender(Cart.updateQty(item.id, item.quantity - 1));
                 }
             });
             row.querySelector('.qty-plus').addEventListener('click', () => {
-                Cart.updateQty(item.id, item.size, item.color, item.qty + 1);
-                renderCart();
-                lucide.createIcons();
+                rerender(Cart.updateQty(item.id, item.quantity + 1));
             });
             row.querySelector('.cart-item-remove').addEventListener('click', () => {
-                Cart.remove(item.id, item.size, item.color);
-                renderCart();
-                lucide.createIcons();
+                rerender(Cart.remove(item.id));
             });
 
             cartItemsEl.appendChild(row);
@@ -353,4 +394,46 @@ document.addEventListener('DOMContentLoaded', () => {
 
         lucide.createIcons();
     }
+
+    // Suggestions for the products in the cart: ones often bought with them
+    // first, then similar ones.
+    async function renderCartRecommendations(items) {
+        const section = document.getElementById('cart-recommendations');
+        if (!section) return;
+        const ids = [...new Set(items.map(item => item.product_id))];
+        if (ids.length === 0) {
+            section.style.display = 'none';
+            return;
+        }
+        const params = new URLSearchParams();
+        ids.forEach(id => params.append('product_id', id));
+        params.set('limit', '4');
+        let recs = [];
+        try {
+            const res = await fetch('/api/recommendations?' + params, { credentials: 'same-origin' });
+            if (res.ok) recs = (await res.json()).recommendations || [];
+        } catch (err) { }
+        const grid = section.querySelector('.product-grid');
+        grid.innerHTML = '';
+        recs.forEach(({ product }) => {
+            const price = product.pricing ? product.pricing.price : product.price;
+            const image = (product.images && product.images[0]) || '';
+            const card = document.createElement('div');
+            card.className = 'product-card';
+            card.innerHTML = `
+                <a href="/product/${product.id}">
+                    <div class="product-image-container">
+                        ${image ? `<img src="${image}" alt="${product.name}" class="product-image"
+                             onerror="this.parentElement.style.background='#f5f5f5'">` : ''}
+                    </div>
+                    <div class="product-info">
+                        <div><div class="product-title">${product.name}</div></div>
+                        <div class="product-price">$${price.toFixed(2)}</div>
+                    </div>
+                </a>
+            `;
+            grid.appendChild(card);
+        });
+        section.style.display = recs.length ? 'block' : 'none';
+    }
 });